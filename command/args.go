@@ -0,0 +1,129 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// spaceCharSet 参数分隔符，\u00A0 是 &nbsp; 的 unicode 编码，参考 dto/message
+const spaceCharSet = " \u00A0\t\n"
+
+// mentionRE at 用户的内嵌格式，<@id> 或者 <@!id>
+var mentionRE = regexp.MustCompile(`^<@!?([0-9A-Za-z_-]+)>$`)
+
+// ArgError 参数不存在或者类型不正确
+type ArgError struct {
+	Index  int    // 参数下标
+	Value  string // 参数原始值
+	Reason string
+}
+
+// Error 输出错误信息
+func (e *ArgError) Error() string {
+	return fmt.Sprintf("command: arg %d %q %s", e.Index, e.Value, e.Reason)
+}
+
+// Args 指令参数，使用空格分隔，支持使用单引号或者双引号包含空格，引号内可以使用 \ 转义
+type Args []string
+
+// Len 参数个数
+func (a Args) Len() int {
+	return len(a)
+}
+
+// String 返回第 i 个参数
+func (a Args) String(i int) (string, error) {
+	if i < 0 || i >= len(a) {
+		return "", &ArgError{Index: i, Reason: "is missing"}
+	}
+	return a[i], nil
+}
+
+// StringOr 返回第 i 个参数，不存在时返回 def
+func (a Args) StringOr(i int, def string) string {
+	if s, err := a.String(i); err == nil {
+		return s
+	}
+	return def
+}
+
+// Int 将第 i 个参数解析为整数
+func (a Args) Int(i int) (int, error) {
+	s, err := a.String(i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &ArgError{Index: i, Value: s, Reason: "is not an integer"}
+	}
+	return n, nil
+}
+
+// IntOr 将第 i 个参数解析为整数，不存在时返回 def，格式错误时返回错误
+func (a Args) IntOr(i int, def int) (int, error) {
+	if i >= len(a) {
+		return def, nil
+	}
+	return a.Int(i)
+}
+
+// Mention 将第 i 个参数解析为 at 用户，返回用户 id
+func (a Args) Mention(i int) (string, error) {
+	s, err := a.String(i)
+	if err != nil {
+		return "", err
+	}
+	m := mentionRE.FindStringSubmatch(s)
+	if m == nil {
+		return "", &ArgError{Index: i, Value: s, Reason: "is not a mention"}
+	}
+	return m[1], nil
+}
+
+// Rest 返回从第 i 个参数开始的所有参数，使用空格连接，i 越界时返回空字符串
+func (a Args) Rest(i int) string {
+	if i < 0 || i >= len(a) {
+		return ""
+	}
+	return strings.Join(a[i:], " ")
+}
+
+// Split 按照 Args 的规则切分输入，未闭合的引号会包含到最后一个参数中
+func Split(input string) Args {
+	var (
+		args    Args
+		current strings.Builder
+		quote   rune
+		escaped bool
+		inArg   bool
+	)
+	for _, r := range input {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != 0:
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote, inArg = r, true
+		case quote == 0 && strings.ContainsRune(spaceCharSet, r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
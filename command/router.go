@@ -0,0 +1,308 @@
+// Package command 提供基于消息内容的指令路由。
+//
+// Router 支持指令前缀、别名、子指令以及按场景生成帮助文本，频道 at 消息、频道私信、群 at 消息与单聊消息使用相同的路由规则。
+//
+//	r := command.NewRouter()
+//	r.Command("ping", func(c *command.Context) error { ... }, command.Description("测试连通性"))
+//	admin := r.Command("admin", nil, command.Scenes(builder.SceneGuild))
+//	admin.Command("ban", ban, command.Usage("<@用户> [天数]"), command.Aliases("b"))
+//	_ = event.RegisterHandlers(r.Handlers()...)
+package command
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/dto/builder"
+	"github.com/tencent-connect/botgo/event"
+)
+
+// DefaultPrefixes 默认的指令前缀，支持 /ping 与 ping 两种写法
+// 因为包含空前缀，所有未匹配到指令的消息（包括普通聊天内容）都会交给 fallback 处理，
+// 只希望 fallback 处理 / 开头的消息时使用 WithPrefixes("/")
+var DefaultPrefixes = []string{"/", ""}
+
+// HandlerFunc 指令处理函数
+type HandlerFunc func(c *Context) error
+
+// Context 一次指令调用的上下文
+type Context struct {
+	Scene   builder.Scene
+	Payload *dto.WSPayload
+	Message *dto.Message
+	Command *Command // 匹配到的指令，未匹配到任何指令时为空
+	Path    []string // 匹配到的指令路径，使用指令名称，不受别名影响
+	Args    Args     // 指令之后的参数
+	router  *Router
+}
+
+// Help 返回当前指令在当前场景下的帮助文本，未匹配到指令时返回所有指令的帮助
+func (c *Context) Help() string {
+	if c.Command != nil && len(c.Command.subs) > 0 {
+		return c.router.help(c.Scene, c.Command)
+	}
+	return c.router.Help(c.Scene)
+}
+
+// Option 指令的配置项
+type Option func(cmd *Command)
+
+// Aliases 设置指令别名
+func Aliases(aliases ...string) Option {
+	return func(cmd *Command) {
+		cmd.aliases = append(cmd.aliases, aliases...)
+	}
+}
+
+// Description 设置指令说明，用于生成帮助文本
+func Description(desc string) Option {
+	return func(cmd *Command) {
+		cmd.description = desc
+	}
+}
+
+// Usage 设置参数说明，用于生成帮助文本，比如 `<@用户> [天数]`
+func Usage(usage string) Option {
+	return func(cmd *Command) {
+		cmd.usage = usage
+	}
+}
+
+// Scenes 限制指令可用的场景，不设置时所有场景可用，子指令同时受父指令的限制
+func Scenes(scenes ...builder.Scene) Option {
+	return func(cmd *Command) {
+		cmd.scenes = scenes
+	}
+}
+
+// Command 指令，可以包含子指令
+type Command struct {
+	name        string
+	aliases     []string
+	description string
+	usage       string
+	scenes      []builder.Scene
+	handler     HandlerFunc
+	subs        []*Command
+	parent      *Command
+}
+
+// Name 指令名称
+func (cmd *Command) Name() string {
+	return cmd.name
+}
+
+// Command 注册子指令，handler 为空时只用于对子指令分组，调用时会执行 fallback
+func (cmd *Command) Command(name string, handler HandlerFunc, opts ...Option) *Command {
+	sub := &Command{name: name, handler: handler, parent: cmd}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	cmd.subs = append(cmd.subs, sub)
+	return sub
+}
+
+func (cmd *Command) match(name string) bool {
+	if strings.EqualFold(cmd.name, name) {
+		return true
+	}
+	for _, alias := range cmd.aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmd *Command) availableIn(scene builder.Scene) bool {
+	if len(cmd.scenes) == 0 {
+		return true
+	}
+	for _, s := range cmd.scenes {
+		if s == scene {
+			return true
+		}
+	}
+	return false
+}
+
+// find 查找在 scene 下可用的子指令
+func (cmd *Command) find(scene builder.Scene, name string) *Command {
+	for _, sub := range cmd.subs {
+		if sub.match(name) && sub.availableIn(scene) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// RouterOption 路由的配置项
+type RouterOption func(r *Router)
+
+// WithPrefixes 设置指令前缀，空字符串表示不需要前缀，较长的前缀优先匹配，帮助文本中使用第一个非空前缀
+func WithPrefixes(prefixes ...string) RouterOption {
+	return func(r *Router) {
+		r.prefixes = prefixes
+	}
+}
+
+// WithFallback 设置未匹配到指令时的处理函数，比如回复帮助文本
+// 只有带指令前缀或者内容为空的消息会执行 fallback，前缀中包含空字符串时（默认配置），所有未匹配到指令的消息都会执行 fallback
+func WithFallback(handler HandlerFunc) RouterOption {
+	return func(r *Router) {
+		r.fallback = handler
+	}
+}
+
+// Router 指令路由
+type Router struct {
+	root     Command
+	prefixes []string
+	fallback HandlerFunc
+}
+
+// NewRouter 创建指令路由
+func NewRouter(opts ...RouterOption) *Router {
+	r := &Router{prefixes: DefaultPrefixes}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.prefixes) == 0 {
+		r.prefixes = []string{""}
+	}
+	// 较长的前缀优先匹配，避免被空前缀提前匹配
+	r.prefixes = append([]string(nil), r.prefixes...)
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i]) > len(r.prefixes[j])
+	})
+	return r
+}
+
+// Command 注册指令
+func (r *Router) Command(name string, handler HandlerFunc, opts ...Option) *Command {
+	return r.root.Command(name, handler, opts...)
+}
+
+// Dispatch 解析消息内容并执行匹配到的指令，未匹配到指令时执行 fallback，没有设置 fallback 或者消息不带前缀时返回 nil
+func (r *Router) Dispatch(payload *dto.WSPayload, scene builder.Scene, msg *dto.Message) error {
+	c := &Context{Scene: scene, Payload: payload, Message: msg, router: r}
+	args := Split(msg.Content)
+	// 去掉开头 at 机器人的内容
+	for len(args) > 0 && mentionRE.MatchString(args[0]) {
+		args = args[1:]
+	}
+	cmd := &r.root
+	prefixed := len(args) == 0
+	if len(args) > 0 {
+		if name, ok := r.trimPrefix(args[0]); ok {
+			prefixed = true
+			if found := cmd.find(scene, name); found != nil {
+				cmd, args = found, args[1:]
+				c.Path = append(c.Path, found.name)
+				for len(args) > 0 {
+					sub := cmd.find(scene, args[0])
+					if sub == nil {
+						break
+					}
+					cmd, args = sub, args[1:]
+					c.Path = append(c.Path, sub.name)
+				}
+			}
+		}
+	}
+	c.Args = args
+	if cmd != &r.root {
+		c.Command = cmd
+		if cmd.handler != nil {
+			return cmd.handler(c)
+		}
+	}
+	if r.fallback != nil && prefixed {
+		return r.fallback(c)
+	}
+	return nil
+}
+
+func (r *Router) trimPrefix(s string) (string, bool) {
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(s, prefix) && len(s) > len(prefix) {
+			return s[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+// Handlers 返回频道 at 消息、频道私信、群 at 消息与单聊消息的 handler，可以传给 event.RegisterHandlers 或者逐个订阅
+func (r *Router) Handlers() []interface{} {
+	return []interface{}{
+		event.ATMessageEventHandler(func(p *dto.WSPayload, data *dto.WSATMessageData) error {
+			return r.Dispatch(p, builder.SceneGuild, (*dto.Message)(data))
+		}),
+		event.DirectMessageEventHandler(func(p *dto.WSPayload, data *dto.WSDirectMessageData) error {
+			return r.Dispatch(p, builder.SceneDirect, (*dto.Message)(data))
+		}),
+		event.GroupATMessageEventHandler(func(p *dto.WSPayload, data *dto.WSGroupATMessageData) error {
+			return r.Dispatch(p, builder.SceneGroup, (*dto.Message)(data))
+		}),
+		event.C2CMessageEventHandler(func(p *dto.WSPayload, data *dto.WSC2CMessageData) error {
+			return r.Dispatch(p, builder.SceneC2C, (*dto.Message)(data))
+		}),
+	}
+}
+
+// Help 返回指定场景下所有可用指令的帮助文本
+func (r *Router) Help(scene builder.Scene) string {
+	return r.help(scene, &r.root)
+}
+
+func (r *Router) help(scene builder.Scene, cmd *Command) string {
+	var lines []string
+	var walk func(cmd *Command, path string)
+	walk = func(cmd *Command, path string) {
+		for _, sub := range cmd.subs {
+			if !sub.availableIn(scene) {
+				continue
+			}
+			subPath := strings.TrimSpace(path + " " + sub.name)
+			if sub.handler != nil || len(sub.subs) == 0 {
+				lines = append(lines, r.helpLine(sub, subPath))
+			}
+			walk(sub, subPath)
+		}
+	}
+	walk(cmd, strings.Join(cmdPath(cmd), " "))
+	if len(lines) == 0 {
+		return ""
+	}
+	return "可用指令：\n" + strings.Join(lines, "\n")
+}
+
+func (r *Router) helpLine(cmd *Command, path string) string {
+	var line string
+	for _, prefix := range r.prefixes {
+		if prefix != "" {
+			line = prefix
+			break
+		}
+	}
+	line += path
+	if cmd.usage != "" {
+		line += " " + cmd.usage
+	}
+	if len(cmd.aliases) > 0 {
+		line += " (" + strings.Join(cmd.aliases, ", ") + ")"
+	}
+	if cmd.description != "" {
+		line += " - " + cmd.description
+	}
+	return line
+}
+
+func cmdPath(cmd *Command) []string {
+	var path []string
+	for ; cmd != nil && cmd.parent != nil; cmd = cmd.parent {
+		path = append([]string{cmd.name}, path...)
+	}
+	return path
+}
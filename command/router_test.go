@@ -0,0 +1,118 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/dto/builder"
+	"github.com/tencent-connect/botgo/event"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Args
+	}{
+		{"", nil},
+		{" ban  <@!123> 7 ", Args{"ban", "<@!123>", "7"}},
+		{`say "hello world" 'it\'s' x`, Args{"say", "hello world", "it's", "x"}},
+		{`say "" end`, Args{"say", "", "end"}},
+		{`say "unclosed quote`, Args{"say", "unclosed quote"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Split(tt.input), tt.input)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args := Split("<@!123> <@u_456> 7 x")
+	id, err := args.Mention(0)
+	require.NoError(t, err)
+	assert.Equal(t, "123", id)
+	id, err = args.Mention(1)
+	require.NoError(t, err)
+	assert.Equal(t, "u_456", id)
+	n, err := args.Int(2)
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	_, err = args.Int(3)
+	assert.EqualError(t, err, `command: arg 3 "x" is not an integer`)
+	_, err = args.Mention(2)
+	assert.Error(t, err)
+	n, err = args.IntOr(4, 30)
+	require.NoError(t, err)
+	assert.Equal(t, 30, n)
+	assert.Equal(t, "fallback", args.StringOr(9, "fallback"))
+	assert.Equal(t, "7 x", args.Rest(2))
+	assert.Equal(t, "", args.Rest(-1))
+	assert.Equal(t, "", args.Rest(9))
+}
+
+func TestRouter(t *testing.T) {
+	var got []string
+	record := func(c *Context) error {
+		assert.Equal(t, "u1", c.Message.Author.ID)
+		got = append(got, c.Scene.String()+" "+strings.Join(c.Path, " ")+" "+c.Args.Rest(0))
+		return nil
+	}
+	r := NewRouter(WithFallback(func(c *Context) error {
+		if c.Command != nil {
+			assert.Equal(t, "可用指令：\n/admin ban <@用户> [天数] (b) - 禁言", c.Help())
+		}
+		got = append(got, "fallback "+strings.Join(c.Path, " ")+" "+c.Args.Rest(0))
+		return nil
+	}))
+	r.Command("ping", record, Aliases("p"), Description("测试连通性"))
+	admin := r.Command("admin", nil, Scenes(builder.SceneGuild, builder.SceneGroup), Description("管理"))
+	admin.Command("ban", record, Usage("<@用户> [天数]"), Aliases("b"), Description("禁言"))
+
+	d := event.NewDispatcher()
+	d.RegisterHandlers(r.Handlers()...)
+	send := func(eventType dto.EventType, content string) {
+		raw := `{"op":0,"d":{"author":{"id":"u1"},"content":"` + content + `"}}`
+		payload := &dto.WSPayload{
+			WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: eventType},
+			RawMessage:    []byte(raw),
+		}
+		require.NoError(t, d.ParseAndHandle(payload))
+	}
+	send(dto.EventAtMessageCreate, "<@!bot> /ping now")
+	send(dto.EventDirectMessageCreate, "P")
+	send(dto.EventGroupAtMessageCreate, " /admin b <@!123> 7")
+	send(dto.EventC2CMessageCreate, "/admin ban <@!123>")
+	send(dto.EventGroupAtMessageCreate, "/admin")
+	send(dto.EventC2CMessageCreate, "hello there")
+	assert.Equal(t, []string{
+		"guild ping now",
+		"direct ping ",
+		"group admin ban <@!123> 7",
+		"fallback  /admin ban <@!123>",
+		"fallback admin ",
+		"fallback  hello there",
+	}, got)
+
+	assert.Equal(t, "可用指令：\n/ping (p) - 测试连通性\n/admin ban <@用户> [天数] (b) - 禁言", r.Help(builder.SceneGroup))
+	assert.Equal(t, "可用指令：\n/ping (p) - 测试连通性", r.Help(builder.SceneC2C))
+}
+
+func TestRouter_Prefixes(t *testing.T) {
+	var got []string
+	r := NewRouter(WithPrefixes("/"), WithFallback(func(c *Context) error {
+		got = append(got, c.Args.Rest(0))
+		return nil
+	}))
+	r.Command("ping", func(c *Context) error { return nil })
+	msg := func(content string) *dto.Message {
+		return &dto.Message{Author: &dto.User{ID: "u1"}, Content: content}
+	}
+	// 不带前缀的普通消息不会执行 fallback
+	require.NoError(t, r.Dispatch(nil, builder.SceneC2C, msg("hello there")))
+	require.NoError(t, r.Dispatch(nil, builder.SceneC2C, msg("ping")))
+	require.NoError(t, r.Dispatch(nil, builder.SceneC2C, msg("/unknown x")))
+	require.NoError(t, r.Dispatch(nil, builder.SceneC2C, msg("<@!bot>")))
+	assert.Equal(t, []string{"/unknown x", ""}, got)
+}
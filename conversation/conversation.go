@@ -0,0 +1,317 @@
+// Package conversation 提供按用户维护的多轮会话状态，用于表单、向导、二次确认等多轮交互。
+//
+// 会话状态以 场景 + 群/子频道 + 用户 为 key 保存在 Store 中，超过 TTL 未继续交互时自动失效。
+// 通过 Manager.Middleware 接入事件分发后，处于会话中的用户发送的消息会交给当前步骤处理，不再投递给其他 handler。
+//
+//	m := conversation.NewManager(conversation.NewMemoryStore())
+//	m.Flow("feedback").
+//		Step("content", func(c *conversation.Context) error {
+//			c.Set("content", c.Message.Content)
+//			c.Next("confirm")
+//			return reply(c, "确认提交吗？")
+//		}).
+//		Step("confirm", func(c *conversation.Context) error {
+//			c.End()
+//			return submit(c.Get("content"))
+//		})
+//	event.Use(m.Middleware())
+//	// 在指令中开始会话
+//	_ = m.Start(ctx, conversation.KeyFromMessage(scene, msg), "feedback")
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/dto/builder"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/log"
+)
+
+// DefaultTTL 会话状态默认的有效期
+const DefaultTTL = 10 * time.Minute
+
+// Key 会话的唯一标识
+// 群消息中的 group_id 与 author.id 分别与互动事件中的 group_openid 与 group_member_openid 相同，
+// 所以消息与互动事件可以得到相同的 key
+type Key struct {
+	Scene   builder.Scene
+	GroupID string // 群 openid，频道场景下为子频道 id，私聊场景下为空
+	UserID  string // 用户 openid，频道场景下为用户 id
+}
+
+// String 用于存储的 key
+func (k Key) String() string {
+	return fmt.Sprintf("%s:%s:%s", k.Scene, k.GroupID, k.UserID)
+}
+
+// KeyFromMessage 根据消息生成会话 key
+func KeyFromMessage(scene builder.Scene, msg *dto.Message) Key {
+	k := Key{Scene: scene}
+	if msg.Author != nil {
+		k.UserID = msg.Author.ID
+	}
+	switch scene {
+	case builder.SceneGuild:
+		k.GroupID = msg.ChannelID
+	case builder.SceneGroup:
+		k.GroupID = msg.GroupID
+	}
+	return k
+}
+
+// State 会话状态
+type State struct {
+	Flow string            `json:"flow"`           // 流程名称
+	Step string            `json:"step"`           // 当前步骤
+	Data map[string]string `json:"data,omitempty"` // 各个步骤收集的数据
+}
+
+func (s *State) clone() *State {
+	c := *s
+	if s.Data != nil {
+		c.Data = make(map[string]string, len(s.Data))
+		for k, v := range s.Data {
+			c.Data[k] = v
+		}
+	}
+	return &c
+}
+
+// StepHandler 处理会话中某一步骤收到的消息
+type StepHandler func(c *Context) error
+
+// Flow 多轮会话流程，由多个步骤组成
+type Flow struct {
+	first string
+	steps map[string]StepHandler
+}
+
+// Step 添加步骤，第一个添加的步骤为流程的起始步骤
+func (f *Flow) Step(name string, handler StepHandler) *Flow {
+	if f.first == "" {
+		f.first = name
+	}
+	f.steps[name] = handler
+	return f
+}
+
+// Context 会话步骤的上下文
+type Context struct {
+	context.Context
+	Key     Key
+	State   *State
+	Payload *dto.WSPayload
+	Message *dto.Message
+
+	next  string
+	ended bool
+}
+
+// Next 处理完成后进入 step 步骤，不调用时停留在当前步骤，比如输入不合法需要重新输入
+func (c *Context) Next(step string) {
+	c.next = step
+}
+
+// End 处理完成后结束会话并清理状态
+func (c *Context) End() {
+	c.ended = true
+}
+
+// Get 获取会话中保存的数据
+func (c *Context) Get(key string) string {
+	return c.State.Data[key]
+}
+
+// Set 在会话中保存数据
+func (c *Context) Set(key, value string) {
+	if c.State.Data == nil {
+		c.State.Data = map[string]string{}
+	}
+	c.State.Data[key] = value
+}
+
+// Option 会话管理器的配置项
+type Option func(m *Manager)
+
+// WithTTL 设置会话状态的有效期，每次处理消息后会重新计时
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.ttl = ttl
+	}
+}
+
+// Manager 会话管理器
+// 同一个进程中相同 key 的消息串行处理；多实例部署时需要保证同一个用户的事件由同一个实例处理，
+// 或者由 Store 的实现保证读取与写入的原子性，否则并发的消息可能覆盖彼此的状态
+type Manager struct {
+	store Store
+	ttl   time.Duration
+	flows map[string]*Flow
+
+	mu    sync.Mutex
+	locks map[string]*keyLock // 正在处理中的 key
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// NewManager 创建会话管理器
+func NewManager(store Store, opts ...Option) *Manager {
+	m := &Manager{store: store, ttl: DefaultTTL, flows: map[string]*Flow{}, locks: map[string]*keyLock{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Flow 创建或者获取流程，需要在处理事件之前完成注册
+func (m *Manager) Flow(name string) *Flow {
+	f, ok := m.flows[name]
+	if !ok {
+		f = &Flow{steps: map[string]StepHandler{}}
+		m.flows[name] = f
+	}
+	return f
+}
+
+// Start 为 key 开始一个流程，进入流程的起始步骤，已有的会话会被覆盖
+func (m *Manager) Start(ctx context.Context, key Key, flow string) error {
+	f, ok := m.flows[flow]
+	if !ok || f.first == "" {
+		return fmt.Errorf("conversation: flow %q not found", flow)
+	}
+	return m.store.Set(ctx, key.String(), &State{Flow: flow, Step: f.first}, m.ttl)
+}
+
+// Get 获取 key 当前的会话状态，不在会话中时返回 nil
+func (m *Manager) Get(ctx context.Context, key Key) (*State, error) {
+	return m.store.Get(ctx, key.String())
+}
+
+// Clear 结束 key 当前的会话
+func (m *Manager) Clear(ctx context.Context, key Key) error {
+	return m.store.Delete(ctx, key.String())
+}
+
+// Handle 如果消息的发送者处于会话中，则交给当前步骤处理，返回消息是否被处理
+func (m *Manager) Handle(ctx context.Context, payload *dto.WSPayload, scene builder.Scene, msg *dto.Message) (
+	bool, error) {
+	key := KeyFromMessage(scene, msg)
+	// 读取、处理、写回状态期间，相同 key 的其他消息需要等待
+	defer m.lock(key.String())()
+	state, err := m.store.Get(ctx, key.String())
+	if err != nil || state == nil {
+		return false, err
+	}
+	handler := m.step(state)
+	if handler == nil {
+		// 流程或者步骤已经不存在，比如升级后删除了流程，清理掉无效的状态
+		log.Warnf("[conversation] %s step %s/%s not found, clear it", key, state.Flow, state.Step)
+		return false, m.store.Delete(ctx, key.String())
+	}
+	c := &Context{Context: ctx, Key: key, State: state, Payload: payload, Message: msg}
+	if err = handler(c); err != nil {
+		return true, err
+	}
+	if c.ended {
+		return true, m.store.Delete(ctx, key.String())
+	}
+	if c.next != "" {
+		state.Step = c.next
+	}
+	return true, m.store.Set(ctx, key.String(), state, m.ttl)
+}
+
+// lock 锁定 key，返回解锁函数，没有等待者时删除锁
+func (m *Manager) lock(key string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
+
+func (m *Manager) step(state *State) StepHandler {
+	if f, ok := m.flows[state.Flow]; ok {
+		return f.steps[state.Step]
+	}
+	return nil
+}
+
+// messageScenes 会话处理的消息事件
+var messageScenes = map[dto.EventType]builder.Scene{
+	dto.EventAtMessageCreate:      builder.SceneGuild,
+	dto.EventDirectMessageCreate:  builder.SceneDirect,
+	dto.EventGroupAtMessageCreate: builder.SceneGroup,
+	dto.EventC2CMessageCreate:     builder.SceneC2C,
+}
+
+// Middleware 返回接入事件分发的中间件
+// 处于会话中的用户发送的消息交给当前步骤处理，不再继续分发；点击清空会话按钮时清理会话状态
+func (m *Manager) Middleware() event.Middleware {
+	return func(next event.Handler) event.Handler {
+		return func(payload *dto.WSPayload) error {
+			if payload.OPCode != dto.WSDispatchEvent {
+				return next(payload)
+			}
+			if payload.Type == dto.EventInteractionCreate {
+				m.clearOnInteraction(payload)
+				return next(payload)
+			}
+			scene, ok := messageScenes[payload.Type]
+			if !ok {
+				return next(payload)
+			}
+			msg := &dto.Message{}
+			if err := event.ParseData(payload.RawMessage, msg); err != nil {
+				return next(payload)
+			}
+			handled, err := m.Handle(context.Background(), payload, scene, msg)
+			if handled {
+				return err
+			}
+			if err != nil {
+				log.Errorf("[conversation] get state failed: %v", err)
+			}
+			return next(payload)
+		}
+	}
+}
+
+func (m *Manager) clearOnInteraction(payload *dto.WSPayload) {
+	data := &dto.WSInteractionData{}
+	if err := event.ParseData(payload.RawMessage, data); err != nil || data.Data == nil ||
+		data.Data.Type != dto.InteractionDataTypeClearSessionClick {
+		return
+	}
+	var key Key
+	switch data.ChatType {
+	case 1:
+		key = Key{Scene: builder.SceneGroup, GroupID: data.GroupOpenID, UserID: data.GroupMemberOpenID}
+	case 2:
+		key = Key{Scene: builder.SceneC2C, UserID: data.UserOpenID}
+	default:
+		return
+	}
+	if err := m.Clear(context.Background(), key); err != nil {
+		log.Errorf("[conversation] clear %s failed: %v", key, err)
+	}
+}
@@ -0,0 +1,148 @@
+package conversation
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/dto/builder"
+	"github.com/tencent-connect/botgo/event"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	state := &State{Flow: "f", Step: "s", Data: map[string]string{"k": "v"}}
+	require.NoError(t, s.Set(ctx, "a", state, 50*time.Millisecond))
+	state.Data["k"] = "changed"
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "v", got.Data["k"])
+
+	time.Sleep(60 * time.Millisecond)
+	got, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// 没有再读取的过期状态在 Set 时定期清理
+	require.NoError(t, s.Set(ctx, "b", state, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	s.sweptAt = time.Time{}
+	require.NoError(t, s.Set(ctx, "c", state, time.Minute))
+	assert.Len(t, s.items, 1)
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryStore())
+	var replies []string
+	m.Flow("feedback").
+		Step("content", func(c *Context) error {
+			c.Set("content", c.Message.Content)
+			c.Next("confirm")
+			replies = append(replies, "confirm?")
+			return nil
+		}).
+		Step("confirm", func(c *Context) error {
+			if c.Message.Content != "yes" {
+				replies = append(replies, "please answer yes")
+				return nil
+			}
+			c.End()
+			replies = append(replies, "submitted "+c.Get("content"))
+			return nil
+		})
+	assert.Error(t, m.Start(ctx, Key{}, "unknown"))
+
+	d := event.NewDispatcher()
+	d.Use(m.Middleware())
+	d.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSC2CMessageData) error {
+		replies = append(replies, "handler "+data.Content)
+		if data.Content == "/feedback" {
+			return m.Start(ctx, KeyFromMessage(builder.SceneC2C, (*dto.Message)(data)), "feedback")
+		}
+		return nil
+	}))
+	send := func(eventType dto.EventType, data string) {
+		payload := &dto.WSPayload{
+			WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: eventType},
+			RawMessage:    []byte(`{"op":0,"d":` + data + `}`),
+		}
+		require.NoError(t, d.ParseAndHandle(payload))
+	}
+	c2c := func(content string) {
+		send(dto.EventC2CMessageCreate, `{"author":{"id":"u1"},"content":"`+content+`"}`)
+	}
+
+	c2c("/feedback")
+	c2c("slow reply")
+	c2c("no")
+	c2c("yes")
+	c2c("hello")
+	assert.Equal(t, []string{
+		"handler /feedback", "confirm?", "please answer yes", "submitted slow reply", "handler hello",
+	}, replies)
+
+	// 点击清空会话按钮后结束会话
+	replies = nil
+	c2c("/feedback")
+	key := Key{Scene: builder.SceneC2C, UserID: "u1"}
+	state, err := m.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "content", state.Step)
+	send(dto.EventInteractionCreate, `{"chat_type":2,"user_openid":"u1","data":{"type":14}}`)
+	state, err = m.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+	c2c("hello")
+	assert.Equal(t, []string{"handler /feedback", "handler hello"}, replies)
+
+	// 群场景中，互动事件清理的是群消息开始的会话
+	d.RegisterHandlers(event.GroupATMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSGroupATMessageData) error {
+		return m.Start(ctx, KeyFromMessage(builder.SceneGroup, (*dto.Message)(data)), "feedback")
+	}))
+	send(dto.EventGroupAtMessageCreate, `{"author":{"id":"m1"},"group_id":"g1","content":"/feedback"}`)
+	key = Key{Scene: builder.SceneGroup, GroupID: "g1", UserID: "m1"}
+	state, err = m.Get(ctx, key)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	send(dto.EventInteractionCreate,
+		`{"chat_type":1,"group_openid":"g1","group_member_openid":"m1","data":{"type":14}}`)
+	state, err = m.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryStore())
+	m.Flow("count").Step("add", func(c *Context) error {
+		n, _ := strconv.Atoi(c.Get("n"))
+		c.Set("n", strconv.Itoa(n+1))
+		return nil
+	})
+	msg := &dto.Message{Author: &dto.User{ID: "u1"}}
+	key := KeyFromMessage(builder.SceneC2C, msg)
+	require.NoError(t, m.Start(ctx, key, "count"))
+
+	// 相同 key 的消息串行处理，不会覆盖彼此的状态
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = m.Handle(ctx, nil, builder.SceneC2C, msg)
+		}()
+	}
+	wg.Wait()
+	state, err := m.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "20", state.Data["n"])
+	assert.Empty(t, m.locks)
+}
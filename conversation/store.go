@@ -0,0 +1,141 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Store 会话状态存储，状态不存在或者已经过期时 Get 返回 nil, nil
+type Store interface {
+	Get(ctx context.Context, key string) (*State, error)
+	Set(ctx context.Context, key string, state *State, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type memoryItem struct {
+	state    State
+	expireAt time.Time
+}
+
+// memorySweepInterval 内存存储清理过期状态的最小间隔
+const memorySweepInterval = time.Minute
+
+// MemoryStore 基于内存的会话状态存储，仅适用于单实例部署
+// 过期的状态在 Get 时删除，没有再读取的状态在 Set 时定期清理
+type MemoryStore struct {
+	mu      sync.Mutex
+	items   map[string]*memoryItem
+	sweptAt time.Time // 上次清理过期状态的时间
+}
+
+// NewMemoryStore 创建基于内存的会话状态存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: map[string]*memoryItem{}}
+}
+
+// Get 获取会话状态
+func (s *MemoryStore) Get(_ context.Context, key string) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(item.expireAt) {
+		delete(s.items, key)
+		return nil, nil
+	}
+	return item.state.clone(), nil
+}
+
+// Set 保存会话状态
+func (s *MemoryStore) Set(_ context.Context, key string, state *State, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.sweptAt) >= memorySweepInterval {
+		s.sweep(now)
+	}
+	s.items[key] = &memoryItem{state: *state.clone(), expireAt: now.Add(ttl)}
+	return nil
+}
+
+// Delete 删除会话状态
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// sweep 删除所有过期的状态，避免写入频繁时每次都遍历
+func (s *MemoryStore) sweep(now time.Time) {
+	for k, item := range s.items {
+		if now.After(item.expireAt) {
+			delete(s.items, k)
+		}
+	}
+	s.sweptAt = now
+}
+
+// defaultRedisKeyPrefix redis 中会话状态 key 的默认前缀
+const defaultRedisKeyPrefix = "botgo:conversation:"
+
+// RedisOption redis 存储的配置项
+type RedisOption func(s *RedisStore)
+
+// WithKeyPrefix 自定义 redis key 的前缀，多个机器人共用 redis 时需要区分
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
+}
+
+// RedisStore 基于 redis 的会话状态存储，适用于多实例部署
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore 创建基于 redis 的会话状态存储，支持单机、哨兵以及集群模式，超时时间请在创建 client 时设置
+func NewRedisStore(client redis.UniversalClient, opts ...RedisOption) *RedisStore {
+	s := &RedisStore{client: client, prefix: defaultRedisKeyPrefix}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Get 获取会话状态
+func (s *RedisStore) Get(ctx context.Context, key string) (*State, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Set 保存会话状态
+func (s *RedisStore) Set(ctx context.Context, key string, state *State, ttl time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+// Delete 删除会话状态
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
@@ -0,0 +1,189 @@
+// Package builder 提供 dto.MessageToCreate 的链式构造与校验能力。
+//
+// 不同场景（频道、频道私信、群、单聊）支持的消息字段并不相同，Builder 会在 Build 时根据场景校验字段的组合与长度限制，
+// 并根据消息内容自动设置 MsgType，避免构造出只有在调用接口时才会被拒绝的消息。
+//
+//	msg, err := builder.New(builder.SceneGroup).
+//		ReplyTo(data.ID).
+//		MarkdownText("**hello**").
+//		Keyboard(builder.NewKeyboard().Row(builder.NewButton("1", "确认").Callback("ok").Build()).Build()).
+//		Build()
+package builder
+
+import (
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/dto/keyboard"
+)
+
+// Scene 消息发送的场景
+type Scene int
+
+const (
+	SceneGuild  Scene = iota + 1 // 频道子频道
+	SceneDirect                  // 频道私信
+	SceneGroup                   // 群聊
+	SceneC2C                     // 单聊
+)
+
+// String 场景名称
+func (s Scene) String() string {
+	switch s {
+	case SceneGuild:
+		return "guild"
+	case SceneDirect:
+		return "direct"
+	case SceneGroup:
+		return "group"
+	case SceneC2C:
+		return "c2c"
+	default:
+		return "unknown"
+	}
+}
+
+// Builder 消息构造器
+type Builder struct {
+	scene Scene
+	msg   dto.MessageToCreate
+}
+
+// New 创建指定场景的消息构造器
+func New(scene Scene) *Builder {
+	return &Builder{scene: scene}
+}
+
+// Content 设置文本内容
+func (b *Builder) Content(content string) *Builder {
+	b.msg.Content = content
+	return b
+}
+
+// ReplyTo 设置要回复的消息 id，设置后为被动消息
+func (b *Builder) ReplyTo(msgID string) *Builder {
+	b.msg.MsgID = msgID
+	return b
+}
+
+// ReplyEvent 设置要回复的事件 id，设置后为被动消息
+func (b *Builder) ReplyEvent(eventID string) *Builder {
+	b.msg.EventID = eventID
+	return b
+}
+
+// Seq 设置回复同一条消息时的序号，服务端根据 msg_id/event_id 与 msg_seq 去重
+func (b *Builder) Seq(seq uint32) *Builder {
+	b.msg.MsgSeq = seq
+	return b
+}
+
+// Reference 引用一条消息，仅频道与频道私信支持
+func (b *Builder) Reference(messageID string, ignoreGetMessageError bool) *Builder {
+	b.msg.MessageReference = &dto.MessageReference{
+		MessageID:             messageID,
+		IgnoreGetMessageError: ignoreGetMessageError,
+	}
+	return b
+}
+
+// Image 设置图片链接，仅频道与频道私信支持，群与单聊请使用 Media
+func (b *Builder) Image(url string) *Builder {
+	b.msg.Image = url
+	return b
+}
+
+// Embed 设置 embed 消息，仅频道与频道私信支持
+func (b *Builder) Embed(embed *dto.Embed) *Builder {
+	b.msg.Embed = embed
+	return b
+}
+
+// Ark 设置 ark 消息
+func (b *Builder) Ark(ark *dto.Ark) *Builder {
+	b.msg.Ark = ark
+	return b
+}
+
+// Markdown 设置 markdown 消息
+func (b *Builder) Markdown(md *dto.Markdown) *Builder {
+	b.msg.Markdown = md
+	return b
+}
+
+// MarkdownText 设置原生 markdown 内容
+func (b *Builder) MarkdownText(content string) *Builder {
+	return b.Markdown(&dto.Markdown{Content: content})
+}
+
+// MarkdownTemplate 使用自定义模板发送 markdown，params 为模板参数
+func (b *Builder) MarkdownTemplate(customTemplateID string, params ...*dto.MarkdownParams) *Builder {
+	return b.Markdown(&dto.Markdown{CustomTemplateID: customTemplateID, Params: params})
+}
+
+// Keyboard 设置消息按钮，需要与 markdown 一起使用
+func (b *Builder) Keyboard(kb *keyboard.MessageKeyboard) *Builder {
+	b.msg.Keyboard = kb
+	return b
+}
+
+// KeyboardTemplate 使用按钮模板
+func (b *Builder) KeyboardTemplate(id string) *Builder {
+	return b.Keyboard(&keyboard.MessageKeyboard{ID: id})
+}
+
+// Media 设置富媒体消息，fileInfo 通过富媒体上传接口获得，仅群与单聊支持
+func (b *Builder) Media(fileInfo []byte) *Builder {
+	b.msg.Media = &dto.MediaInfo{FileInfo: fileInfo}
+	return b
+}
+
+// InputNotify 设置输入状态消息，仅单聊支持
+func (b *Builder) InputNotify(inputType int, seconds int32) *Builder {
+	b.msg.InputNotify = &dto.InputNotify{InputType: inputType, InputSecond: seconds}
+	return b
+}
+
+// Stream 设置流式消息分片信息，仅群与单聊支持
+func (b *Builder) Stream(stream *dto.Stream) *Builder {
+	b.msg.Stream = stream
+	return b
+}
+
+// PromptKeyboard 设置交互区按钮，仅群与单聊支持
+func (b *Builder) PromptKeyboard(kb *keyboard.MessageKeyboard) *Builder {
+	b.msg.PromptKeyboard = &dto.PromptKeyboard{Keyboard: kb}
+	return b
+}
+
+// ActionButton 设置消息操作栏，仅群与单聊支持
+func (b *Builder) ActionButton(button *dto.ActionButton) *Builder {
+	b.msg.ActionButton = button
+	return b
+}
+
+// Build 设置 MsgType 并校验，返回一个新的消息对象
+func (b *Builder) Build() (*dto.MessageToCreate, error) {
+	msg := b.msg
+	msg.MsgType = MsgType(&msg)
+	if err := Validate(b.scene, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MsgType 根据消息内容推断消息类型
+func MsgType(msg *dto.MessageToCreate) dto.MessageType {
+	switch {
+	case msg.Media != nil:
+		return dto.RichMediaMsg
+	case msg.Markdown != nil:
+		return dto.MarkdownMsg
+	case msg.Ark != nil:
+		return dto.ArkMsg
+	case msg.Embed != nil:
+		return dto.EmbedMsg
+	case msg.InputNotify != nil:
+		return dto.InputNotifyMsg
+	default:
+		return dto.TextMsg
+	}
+}
@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	kb := NewKeyboard().Row(
+		NewButton("1", "确认").Callback("ok").Modal("确认执行吗", "是", "否").Build(),
+		NewButton("2", "文档").Link("https://bot.q.qq.com").Build(),
+	).Build()
+	msg, err := New(SceneGroup).ReplyTo("m1").Seq(2).MarkdownText("**hi**").Keyboard(kb).Build()
+	require.NoError(t, err)
+	assert.Equal(t, dto.MarkdownMsg, msg.MsgType)
+	assert.Equal(t, "m1", msg.MsgID)
+	assert.Equal(t, uint32(2), msg.MsgSeq)
+	assert.Len(t, msg.Keyboard.Content.Rows[0].Buttons, 2)
+
+	msg, err = New(SceneC2C).Media([]byte("file")).Build()
+	require.NoError(t, err)
+	assert.Equal(t, dto.RichMediaMsg, msg.MsgType)
+
+	msg, err = New(SceneGuild).Content("hello").Image("https://a/b.png").Reference("m0", true).Build()
+	require.NoError(t, err)
+	assert.Equal(t, dto.TextMsg, msg.MsgType)
+}
+
+func TestValidate(t *testing.T) {
+	longModal := NewKeyboard().Row(
+		NewButton("1", "删除").Callback("del").Modal("这是一段超过四十个字符的二次确认提示文本，用于验证长度限制是否生效，这段文本确实已经很长了", "确认要删除", "取消").Build(),
+	).Build()
+	tests := []struct {
+		name    string
+		builder *Builder
+		fields  []string
+	}{
+		{"empty", New(SceneGroup), []string{"content"}},
+		{"image in group", New(SceneGroup).Content("hi").Image("https://a/b.png"), []string{"image"}},
+		{"media in guild", New(SceneGuild).Media([]byte("file")), []string{"media"}},
+		{"input notify in group", New(SceneGroup).InputNotify(1, 10), []string{"input_notify"}},
+		{"conflicting bodies", New(SceneGuild).MarkdownText("md").Ark(&dto.Ark{TemplateID: 23}), []string{"msg_type"}},
+		{"keyboard without markdown", New(SceneGroup).Content("hi").KeyboardTemplate("kb"), []string{"keyboard"}},
+		{"markdown without content", New(SceneGroup).Markdown(&dto.Markdown{}), []string{"markdown"}},
+		{"modal too long", New(SceneC2C).MarkdownText("md").Keyboard(longModal), []string{
+			"keyboard.content.rows[0].buttons[0].action.modal.content",
+			"keyboard.content.rows[0].buttons[0].action.modal.confirm_text",
+		}},
+		{"empty keyboard", New(SceneC2C).MarkdownText("md").Keyboard(NewKeyboard().Build()), []string{
+			"keyboard.content.rows",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			var verrs ValidationErrors
+			require.True(t, errors.As(err, &verrs), "err: %v", err)
+			fields := make([]string, 0, len(verrs))
+			for _, e := range verrs {
+				fields = append(fields, e.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+
+	// 手工构造的消息，MsgType 与内容不一致
+	err := Validate(SceneGroup, &dto.MessageToCreate{Markdown: &dto.Markdown{Content: "md"}})
+	assert.EqualError(t, err, "builder: invalid message, msg_type: is 0, want 2")
+}
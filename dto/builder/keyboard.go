@@ -0,0 +1,115 @@
+package builder
+
+import (
+	"github.com/tencent-connect/botgo/dto/keyboard"
+)
+
+// KeyboardBuilder 自定义按钮组件构造器
+type KeyboardBuilder struct {
+	kb keyboard.CustomKeyboard
+}
+
+// NewKeyboard 创建自定义按钮组件
+func NewKeyboard() *KeyboardBuilder {
+	return &KeyboardBuilder{}
+}
+
+// Row 追加一行按钮
+func (k *KeyboardBuilder) Row(buttons ...*keyboard.Button) *KeyboardBuilder {
+	k.kb.Rows = append(k.kb.Rows, &keyboard.Row{Buttons: buttons})
+	return k
+}
+
+// FontSize 设置按钮字体大小
+func (k *KeyboardBuilder) FontSize(size string) *KeyboardBuilder {
+	k.kb.Style = &keyboard.KeyboardStyle{FontSize: size}
+	return k
+}
+
+// Build 生成按钮组件，校验在消息 Build 时进行
+func (k *KeyboardBuilder) Build() *keyboard.MessageKeyboard {
+	kb := k.kb
+	return &keyboard.MessageKeyboard{Content: &kb}
+}
+
+// ButtonBuilder 按钮构造器，默认所有人可操作
+type ButtonBuilder struct {
+	button keyboard.Button
+}
+
+// NewButton 创建按钮
+func NewButton(id, label string) *ButtonBuilder {
+	return &ButtonBuilder{button: keyboard.Button{
+		ID:         id,
+		RenderData: &keyboard.RenderData{Label: label, VisitedLabel: label},
+		Action: &keyboard.Action{
+			Permission: &keyboard.Permission{Type: keyboard.PermissionTypAll},
+		},
+	}}
+}
+
+// Callback 点击后回调给机器人，data 会在互动事件中返回
+func (b *ButtonBuilder) Callback(data string) *ButtonBuilder {
+	b.button.Action.Type = keyboard.ActionTypeCallback
+	b.button.Action.Data = data
+	return b
+}
+
+// Link 点击后跳转链接
+func (b *ButtonBuilder) Link(url string) *ButtonBuilder {
+	b.button.Action.Type = keyboard.ActionTypeURL
+	b.button.Action.Data = url
+	return b
+}
+
+// Command 点击后在输入框 @机器人 并填入指令，enter 为 true 时直接发送
+func (b *ButtonBuilder) Command(cmd string, enter bool) *ButtonBuilder {
+	b.button.Action.Type = keyboard.ActionTypeAtBot
+	b.button.Action.Data = cmd
+	b.button.Action.Enter = enter
+	return b
+}
+
+// VisitedLabel 设置点击后按钮上的文字
+func (b *ButtonBuilder) VisitedLabel(label string) *ButtonBuilder {
+	b.button.RenderData.VisitedLabel = label
+	return b
+}
+
+// Style 设置按钮样式，0：灰色线框，1：蓝色线框
+func (b *ButtonBuilder) Style(style int) *ButtonBuilder {
+	b.button.RenderData.Style = style
+	return b
+}
+
+// Group 设置按钮分组，同组内一个按钮操作后，其他按钮不可点击
+func (b *ButtonBuilder) Group(groupID string) *ButtonBuilder {
+	b.button.GroupID = groupID
+	return b
+}
+
+// ClickLimit 设置可点击的次数
+func (b *ButtonBuilder) ClickLimit(limit uint32) *ButtonBuilder {
+	b.button.Action.ClickLimit = limit
+	return b
+}
+
+// Permission 设置按钮的操作权限
+func (b *ButtonBuilder) Permission(p *keyboard.Permission) *ButtonBuilder {
+	b.button.Action.Permission = p
+	return b
+}
+
+// Modal 点击后进行二次确认
+func (b *ButtonBuilder) Modal(content, confirmText, cancelText string) *ButtonBuilder {
+	b.button.Action.Modal = &keyboard.Modal{Content: content, ConfirmText: confirmText, CancelText: cancelText}
+	return b
+}
+
+// Build 生成按钮
+func (b *ButtonBuilder) Build() *keyboard.Button {
+	button := b.button
+	render, action := *b.button.RenderData, *b.button.Action
+	button.RenderData, button.Action = &render, &action
+	return &button
+}
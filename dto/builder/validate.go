@@ -0,0 +1,211 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/dto/keyboard"
+)
+
+// 字段长度与数量限制，参考 dto/keyboard 与 dto/message_create.go 中的字段说明
+const (
+	MaxModalContentLen      = 40  // 二次确认提示文本最多 40 个字符
+	MaxModalButtonTextLen   = 4   // 二次确认按钮文字最多 4 个字符
+	MaxActionCallbackLen    = 128 // 消息操作栏回调数据最多 128 个字符
+	MaxKeyboardRows         = 5   // 自定义按钮最多 5 行
+	MaxKeyboardButtonPerRow = 5   // 每行最多 5 个按钮
+)
+
+// ValidationError 单个字段的校验错误
+type ValidationError struct {
+	Field  string // 字段路径，比如 keyboard.content.rows[0].buttons[1].action.modal.content
+	Reason string // 失败原因
+}
+
+// Error 输出错误信息
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidationErrors 消息校验失败时返回的所有错误
+type ValidationErrors []*ValidationError
+
+// Error 输出错误信息
+func (e ValidationErrors) Error() string {
+	s := make([]string, 0, len(e))
+	for _, err := range e {
+		s = append(s, err.Error())
+	}
+	return "builder: invalid message, " + strings.Join(s, "; ")
+}
+
+// sceneFields 仅部分场景支持的字段
+var sceneFields = map[string][]Scene{
+	"image":             {SceneGuild, SceneDirect},
+	"embed":             {SceneGuild, SceneDirect},
+	"message_reference": {SceneGuild, SceneDirect},
+	"media":             {SceneGroup, SceneC2C},
+	"prompt_keyboard":   {SceneGroup, SceneC2C},
+	"action_button":     {SceneGroup, SceneC2C},
+	"input_notify":      {SceneC2C},
+	"stream":            {SceneGroup, SceneC2C},
+}
+
+// Validate 校验消息在指定场景下是否合法，可以用于校验非 Builder 构造的消息
+func Validate(scene Scene, msg *dto.MessageToCreate) error {
+	v := &validator{}
+	v.scene(scene, msg)
+	v.body(msg)
+	if msg.Markdown != nil {
+		v.markdown(msg.Markdown)
+	}
+	if msg.Keyboard != nil {
+		if msg.Markdown == nil {
+			v.add("keyboard", "must be used with markdown")
+		}
+		v.keyboard("keyboard", msg.Keyboard)
+	}
+	if msg.PromptKeyboard != nil && msg.PromptKeyboard.Keyboard != nil {
+		v.keyboard("prompt_keyboard.keyboard", msg.PromptKeyboard.Keyboard)
+	}
+	if msg.ActionButton != nil && utf8.RuneCountInString(msg.ActionButton.CallbackData) > MaxActionCallbackLen {
+		v.add("action_button.callback_data", fmt.Sprintf("longer than %d characters", MaxActionCallbackLen))
+	}
+	if msg.InputNotify != nil && msg.InputNotify.InputType != 1 && msg.InputNotify.InputType != 2 {
+		v.add("input_notify.input_type", "must be 1 or 2")
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+// optional 消息中的一个可选字段，以及是否被设置
+type optional struct {
+	name string
+	set  bool
+}
+
+func (v *validator) add(field, reason string) {
+	v.errs = append(v.errs, &ValidationError{Field: field, Reason: reason})
+}
+
+func (v *validator) scene(scene Scene, msg *dto.MessageToCreate) {
+	for _, f := range []optional{
+		{"image", msg.Image != ""},
+		{"embed", msg.Embed != nil},
+		{"message_reference", msg.MessageReference != nil},
+		{"media", msg.Media != nil},
+		{"prompt_keyboard", msg.PromptKeyboard != nil},
+		{"action_button", msg.ActionButton != nil},
+		{"input_notify", msg.InputNotify != nil},
+		{"stream", msg.Stream != nil},
+	} {
+		if f.set && !sceneSupported(scene, sceneFields[f.name]) {
+			v.add(f.name, fmt.Sprintf("not supported in %s scene", scene))
+		}
+	}
+}
+
+func sceneSupported(scene Scene, scenes []Scene) bool {
+	for _, s := range scenes {
+		if s == scene {
+			return true
+		}
+	}
+	return false
+}
+
+// body 消息主体互斥，并且 MsgType 需要与主体一致
+func (v *validator) body(msg *dto.MessageToCreate) {
+	var bodies []string
+	for _, f := range []optional{
+		{"markdown", msg.Markdown != nil},
+		{"ark", msg.Ark != nil},
+		{"embed", msg.Embed != nil},
+		{"media", msg.Media != nil},
+		{"input_notify", msg.InputNotify != nil},
+	} {
+		if f.set {
+			bodies = append(bodies, f.name)
+		}
+	}
+	if len(bodies) > 1 {
+		v.add("msg_type", "conflicting message bodies: "+strings.Join(bodies, ", "))
+		return
+	}
+	if want := MsgType(msg); msg.MsgType != want && !(want == dto.TextMsg && msg.MsgType == dto.ATMsg) {
+		v.add("msg_type", fmt.Sprintf("is %d, want %d", msg.MsgType, want))
+	}
+	if len(bodies) == 0 && msg.Content == "" && msg.Image == "" {
+		v.add("content", "message is empty")
+	}
+	if msg.Media != nil && len(msg.Media.FileInfo) == 0 {
+		v.add("media.file_info", "is empty")
+	}
+}
+
+func (v *validator) markdown(md *dto.Markdown) {
+	template := md.TemplateID != 0 || md.CustomTemplateID != ""
+	if template == (md.Content != "") {
+		v.add("markdown", "exactly one of content and template id is required")
+	}
+}
+
+func (v *validator) keyboard(field string, kb *keyboard.MessageKeyboard) {
+	if (kb.ID != "") == (kb.Content != nil) {
+		v.add(field, "exactly one of id and content is required")
+		return
+	}
+	if kb.Content == nil {
+		return
+	}
+	rows := kb.Content.Rows
+	if len(rows) == 0 || len(rows) > MaxKeyboardRows {
+		v.add(field+".content.rows", fmt.Sprintf("must have 1 to %d rows", MaxKeyboardRows))
+	}
+	for i, row := range rows {
+		rowField := fmt.Sprintf("%s.content.rows[%d]", field, i)
+		if row == nil || len(row.Buttons) == 0 || len(row.Buttons) > MaxKeyboardButtonPerRow {
+			v.add(rowField, fmt.Sprintf("must have 1 to %d buttons", MaxKeyboardButtonPerRow))
+			continue
+		}
+		for j, button := range row.Buttons {
+			v.button(fmt.Sprintf("%s.buttons[%d]", rowField, j), button)
+		}
+	}
+}
+
+func (v *validator) button(field string, button *keyboard.Button) {
+	if button == nil {
+		v.add(field, "is nil")
+		return
+	}
+	if button.RenderData == nil || button.RenderData.Label == "" {
+		v.add(field+".render_data.label", "is empty")
+	}
+	if button.Action == nil {
+		v.add(field+".action", "is nil")
+		return
+	}
+	if m := button.Action.Modal; m != nil {
+		if utf8.RuneCountInString(m.Content) > MaxModalContentLen {
+			v.add(field+".action.modal.content", fmt.Sprintf("longer than %d characters", MaxModalContentLen))
+		}
+		if strings.Contains(m.Content, "://") {
+			v.add(field+".action.modal.content", "must not contain url")
+		}
+		if utf8.RuneCountInString(m.ConfirmText) > MaxModalButtonTextLen {
+			v.add(field+".action.modal.confirm_text", fmt.Sprintf("longer than %d characters", MaxModalButtonTextLen))
+		}
+		if utf8.RuneCountInString(m.CancelText) > MaxModalButtonTextLen {
+			v.add(field+".action.modal.cancel_text", fmt.Sprintf("longer than %d characters", MaxModalButtonTextLen))
+		}
+	}
+}
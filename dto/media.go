@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"io"
+	"os"
+)
+
+// 富媒体文件类型
+const (
+	MediaFileTypeImage uint64 = 1 // 图片，png/jpg
+	MediaFileTypeVideo uint64 = 2 // 视频，mp4
+	MediaFileTypeVoice uint64 = 3 // 语音，silk
+	MediaFileTypeFile  uint64 = 4 // 文件
+)
+
+// MediaToUpload 要上传的富媒体文件，URL 与 Data 二选一
+type MediaToUpload struct {
+	FileType uint64 // 文件类型，参考 MediaFileType*
+	URL      string // 文件的 HTTP 或者 HTTPS 链接
+	Data     []byte // 文件内容
+}
+
+// MediaFromURL 上传公网可访问的文件
+func MediaFromURL(fileType uint64, url string) *MediaToUpload {
+	return &MediaToUpload{FileType: fileType, URL: url}
+}
+
+// MediaFromBytes 上传文件内容
+func MediaFromBytes(fileType uint64, data []byte) *MediaToUpload {
+	return &MediaToUpload{FileType: fileType, Data: data}
+}
+
+// MediaFromReader 读取 r 中的全部内容作为文件内容上传
+func MediaFromReader(fileType uint64, r io.Reader) (*MediaToUpload, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return MediaFromBytes(fileType, data), nil
+}
+
+// MediaFromFile 上传本地文件
+func MediaFromFile(fileType uint64, path string) (*MediaToUpload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return MediaFromBytes(fileType, data), nil
+}
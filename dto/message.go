@@ -39,6 +39,8 @@ type Message struct {
 	MessageReference *MessageReference `json:"message_reference,omitempty"`
 	// 私信场景下，该字段用来标识从哪个频道发起的私信
 	SrcGuildID string `json:"src_guild_id"`
+	// 上传富媒体文件后返回的文件 id
+	FileUUID string `json:"file_uuid,omitempty"`
 	// 上传富媒体文件后返回的文件信息。 注意以群或者C2C消息上传后， 同类型可以重复使用，不同类型需要不能使用。
 	FileInfo []byte `json:"file_info,omitempty"`
 	// 上传富媒体文件后的有效期, 单位:秒, 在有效期内可以重复使用。
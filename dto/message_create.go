@@ -22,6 +22,7 @@ type RichMediaMessage struct {
 	EventID    string `json:"event_id,omitempty"`     // 已经废弃：要回复的事件id, 逻辑同MsgID
 	FileType   uint64 `json:"file_type,omitempty"`    // 业务类型，图片，文件，语音，视频 文件类型，取值:1图片,2视频,3语音(目前语音只支持silk格式)
 	URL        string `json:"url,omitempty"`          // 需发送的富媒体文件，HTTP或者HTTPS链接
+	FileData   []byte `json:"file_data,omitempty"`    // 需发送的富媒体文件内容，与 URL 二选一，序列化为 base64
 	SrvSendMsg bool   `json:"srv_send_msg,omitempty"` // 为true时会直接发送到群/C2C，且会占用主动消息频率, 为false为上传富媒体文件
 	Content    string `json:"content,omitempty"`
 	MsgSeq     int64  `json:"msg_seq,omitempty"` // 机器人对于回复一个msg_id或者event_id的消息序号，指定后根据这个字段和msg_id或者event_id进行去重
@@ -78,10 +79,18 @@ type MessageToCreate struct {
 type Stream struct {
 	State int32  `json:"state,omitempty"` // 流式消息状态 1正文生成中，10：正文生成结束， 11：引志消息生成中， 20：引导消息生成结束。
 	ID    string `json:"id,omitempty"`    // 流式消息ID，流式消息第一条不用填写，第二条需要填写第一个分片返回的msgID.
-	Index int32  `json:"index,omitempty"` // 流式消息的序号， 从1开始
+	Index int32  `json:"index"`           // 流式消息的序号， 从1开始，reset 时为 0，需要序列化 0
 	Reset bool   `json:"reset,omitempty"` // 重新生成流式消息标记，此参数只能使用于流式消息分片还没有发送完成时，reset时Index需要从0开始，需要填写流式ID。
 }
 
+// 流式消息状态
+const (
+	StreamStateGenerating      int32 = 1  // 正文生成中
+	StreamStateDone            int32 = 10 // 正文生成结束
+	StreamStateGuideGenerating int32 = 11 // 引导消息生成中
+	StreamStateGuideDone       int32 = 20 // 引导消息生成结束
+)
+
 // PromptKeyboard 交互区操作
 type PromptKeyboard struct {
 	Keyboard *keyboard.MessageKeyboard `json:"keyboard,omitempty"` // 消息按钮组件
@@ -165,5 +174,7 @@ type InputNotify struct {
 
 // MediaInfo 富媒体信息
 type MediaInfo struct {
+	FileUUID string `json:"file_uuid,omitempty"` // 文件 id，通过上传接口取得
 	FileInfo []byte `json:"file_info,omitempty"` // 富媒体文件信息，通过上传接口取得
+	TTL      uint   `json:"ttl,omitempty"`       // 有效期，单位：秒，0 表示长期有效，通过上传接口取得
 }
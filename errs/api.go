@@ -0,0 +1,124 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// openapi 业务错误码，参考 https://bot.q.qq.com/wiki/develop/api/openapi/error/error.html
+const (
+	APICodeUnknownAccount          = 10001  // 账号异常
+	APICodeUnknownChannel          = 10003  // 子频道不存在
+	APICodeUnknownGuild            = 10004  // 频道不存在，或者机器人不在该频道中
+	APICodeTokenMissing            = 11241  // 缺少 token
+	APICodeCheckTokenFailed        = 11242  // 校验 token 失败
+	APICodeTokenNotPass            = 11243  // token 校验未通过
+	APICodeAppPrivilegeNotPass     = 11253  // 机器人没有调用该接口的权限
+	APICodeInterfaceForbidden      = 11254  // 接口被封禁
+	APICodeGuildAuthNotPass        = 11264  // 频道管理员没有授予该接口权限
+	APICodeRobotBanned             = 11265  // 机器人已经被封禁
+	APICodeUserAuthNotPass         = 11274  // 用户没有授予该接口权限
+	APICodeCheckAdminNotPass       = 11282  // 需要管理员权限
+	APICodeChannelWriteRateLimited = 20028  // 子频道消息触发限频
+	APICodeMsgLimitExceed          = 22009  // 消息发送超频
+	APICodeEmptyMessage            = 50006  // 消息为空
+	APICodeURLNotAllowed           = 304003 // url 未报备
+	APICodeArkNotAllowed           = 304004 // 没有发送 ark 消息的权限
+	APICodePushMsgAuditing         = 304023 // 主动消息提交成功，正在审核
+	APICodeReplyMsgAuditing        = 304024 // 回复消息提交成功，正在审核
+)
+
+// openapi 错误分类，可以配合 errors.Is 使用
+var (
+	// ErrAPITokenInvalid token 缺失、过期或者校验不通过
+	ErrAPITokenInvalid = errors.New("api: token invalid")
+	// ErrAPINoPermission 没有调用接口的权限
+	ErrAPINoPermission = errors.New("api: no permission")
+	// ErrAPIMessageAuditing 消息已经提交，正在审核
+	ErrAPIMessageAuditing = errors.New("api: message auditing")
+	// ErrAPIRateLimited 请求触发了频率限制
+	ErrAPIRateLimited = errors.New("api: rate limited")
+	// ErrAPIBotNotInGuild 频道不存在，或者机器人不在频道中
+	ErrAPIBotNotInGuild = errors.New("api: bot not in guild")
+)
+
+// apiCodeKinds 错误码对应的错误分类
+var apiCodeKinds = map[int]error{
+	APICodeTokenMissing:            ErrAPITokenInvalid,
+	APICodeCheckTokenFailed:        ErrAPITokenInvalid,
+	APICodeTokenNotPass:            ErrAPITokenInvalid,
+	APICodeTokenExpireOrNotExist:   ErrAPITokenInvalid,
+	APICodeAppPrivilegeNotPass:     ErrAPINoPermission,
+	APICodeInterfaceForbidden:      ErrAPINoPermission,
+	APICodeGuildAuthNotPass:        ErrAPINoPermission,
+	APICodeUserAuthNotPass:         ErrAPINoPermission,
+	APICodeCheckAdminNotPass:       ErrAPINoPermission,
+	APICodeArkNotAllowed:           ErrAPINoPermission,
+	APICodePushMsgAuditing:         ErrAPIMessageAuditing,
+	APICodeReplyMsgAuditing:        ErrAPIMessageAuditing,
+	APICodeChannelWriteRateLimited: ErrAPIRateLimited,
+	APICodeMsgLimitExceed:          ErrAPIRateLimited,
+	APICodeUnknownGuild:            ErrAPIBotNotInGuild,
+}
+
+// APIError openapi 调用失败时返回的错误
+type APIError struct {
+	HTTPStatus int    // http 状态码
+	Code       int    // 业务错误码，优先取 err_code，其次取 code
+	Message    string // 错误原因
+	TraceID    string // 服务端 traceID，用于问题排查
+	Body       string // 原始的返回内容
+}
+
+// NewAPIError 创建 openapi 错误
+func NewAPIError(httpStatus, code int, message, traceID, body string) *APIError {
+	return &APIError{
+		HTTPStatus: httpStatus,
+		Code:       code,
+		Message:    message,
+		TraceID:    traceID,
+		Body:       body,
+	}
+}
+
+// Error 输出错误信息，与 Err 的格式保持一致
+func (e *APIError) Error() string {
+	return fmt.Sprintf("code:%v, text:%v, traceID:%s", e.HTTPStatus, e.Body, e.TraceID)
+}
+
+// Is 判断错误是否属于某个错误分类，比如 errors.Is(err, errs.ErrAPITokenInvalid)
+func (e *APIError) Is(target error) bool {
+	if kind, ok := apiCodeKinds[e.Code]; ok && kind == target {
+		return true
+	}
+	return target == ErrAPIRateLimited && e.HTTPStatus == http.StatusTooManyRequests
+}
+
+// Kind 返回错误码对应的错误分类，未知的错误码返回 nil
+func (e *APIError) Kind() error {
+	if kind, ok := apiCodeKinds[e.Code]; ok {
+		return kind
+	}
+	if e.HTTPStatus == http.StatusTooManyRequests {
+		return ErrAPIRateLimited
+	}
+	return nil
+}
+
+// AsAPIError 从错误链中取出 openapi 错误
+func AsAPIError(err error) (*APIError, bool) {
+	var e *APIError
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// APICode 返回错误中的业务错误码，不是 openapi 错误时返回 0
+func APICode(err error) int {
+	if e, ok := AsAPIError(err); ok {
+		return e.Code
+	}
+	return 0
+}
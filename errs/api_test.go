@@ -0,0 +1,50 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *APIError
+		target error
+		want   bool
+	}{
+		{"token expired", NewAPIError(401, APICodeTokenExpireOrNotExist, "", "", ""), ErrAPITokenInvalid, true},
+		{"no permission", NewAPIError(403, APICodeAppPrivilegeNotPass, "", "", ""), ErrAPINoPermission, true},
+		{"auditing", NewAPIError(400, APICodePushMsgAuditing, "", "", ""), ErrAPIMessageAuditing, true},
+		{"msg limit", NewAPIError(400, APICodeMsgLimitExceed, "", "", ""), ErrAPIRateLimited, true},
+		{"http 429", NewAPIError(http.StatusTooManyRequests, 0, "", "", ""), ErrAPIRateLimited, true},
+		{"not in guild", NewAPIError(404, APICodeUnknownGuild, "", "", ""), ErrAPIBotNotInGuild, true},
+		{"other kind", NewAPIError(404, APICodeUnknownGuild, "", "", ""), ErrAPITokenInvalid, false},
+		{"unknown code", NewAPIError(500, 12345, "", "", ""), ErrAPIRateLimited, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", tt.err)
+			assert.Equal(t, tt.want, errors.Is(err, tt.target))
+		})
+	}
+}
+
+func TestAPIError_Compatible(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NewAPIError(404, APICodeUnknownGuild, "guild not found", "trace", `{"code":10004}`))
+
+	apiErr, ok := AsAPIError(err)
+	assert.True(t, ok)
+	assert.Equal(t, "guild not found", apiErr.Message)
+	assert.Equal(t, APICodeUnknownGuild, APICode(err))
+	assert.Equal(t, 0, APICode(errors.New("other")))
+
+	// 兼容原有的 errs.Error 用法
+	e := Error(err)
+	assert.Equal(t, 404, e.Code())
+	assert.Equal(t, "trace", e.Trace())
+	assert.Equal(t, CodeNeedReConnect, Error(fmt.Errorf("wrapped: %w", ErrNeedReConnect)).Code())
+}
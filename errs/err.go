@@ -2,6 +2,7 @@
 package errs
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -14,11 +15,15 @@ var (
 	ErrURLInvalid = New(CodeConnCloseCantIdentify, "ws ap url is invalid")
 	// ErrSessionLimit session 数量受到限制
 	ErrSessionLimit = New(CodeConnCloseCantIdentify, "session num limit")
+	// ErrHeartbeatTimeout 连续多次没有收到心跳 ack，连接可能已经断开，需要 resume
+	ErrHeartbeatTimeout = New(CodeHeartbeatTimeout, "heartbeat ack timeout")
 
 	// ErrNotFoundOpenAPI 未找到对应版本的openapi实现
 	ErrNotFoundOpenAPI = New(CodeNotFoundOpenAPI, "not found openapi version")
 	// ErrPagerIsNil 分页器为空
 	ErrPagerIsNil = New(CodePagerIsNil, "pager is nil")
+	// ErrRateLimitWaitTooLong 限频排队时间超过上限
+	ErrRateLimitWaitTooLong = New(CodeRateLimitWaitTooLong, "rate limit wait too long")
 )
 
 // sdk 错误码
@@ -35,6 +40,10 @@ const (
 	CodeConnCloseCantIdentify = 9006
 	// CodePagerIsNil 分页器为空
 	CodePagerIsNil = 9007
+	// CodeRateLimitWaitTooLong 限频排队时间超过上限
+	CodeRateLimitWaitTooLong = 9008
+	// CodeHeartbeatTimeout 心跳 ack 超时
+	CodeHeartbeatTimeout = 9009
 )
 
 // websocket错误码
@@ -84,9 +93,19 @@ func New(code int, text string, trace ...string) error {
 
 // Error 将错误转换为 sdk 的错误类型
 func Error(err error) *Err {
-	if e, ok := err.(*Err); ok {
+	var e *Err
+	if errors.As(err, &e) {
 		return e
 	}
+	// openapi 错误的 code 为 http 状态码，与之前的行为保持一致
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &Err{
+			code:  apiErr.HTTPStatus,
+			text:  apiErr.Body,
+			trace: apiErr.TraceID,
+		}
+	}
 	return &Err{
 		code: 9999,
 		text: err.Error(),
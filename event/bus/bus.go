@@ -0,0 +1,61 @@
+// Package bus 在持有连接的进程与处理事件的进程之间传递事件。
+//
+// 分片数量有限，持有连接的进程数量也有限，把事件发布到持久化的事件流之后，
+// 无状态的消费者进程可以按照消费组并发处理事件，handler 的处理能力与分片数量解耦。
+//
+//	// 持有连接的进程，事件不再在本地处理，而是发布到事件流
+//	stream := bus.NewRedisStream(client)
+//	event.Use(bus.Forward(stream))
+//
+//	// 消费者进程，注册 handler 后消费事件
+//	event.RegisterHandlers(...)
+//	bus.NewConsumer(stream, "handlers", hostname).Run(ctx)
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// Message 从事件流中读取的事件
+type Message struct {
+	ID         string // 事件在事件流中的 id，Ack 时使用
+	Payload    *dto.WSPayload
+	Deliveries int // 投递的次数，第一次投递为 1，超时未 ack 被重新投递时增加
+}
+
+// EventSink 发布事件到事件流
+type EventSink interface {
+	// Publish 发布事件，事件以 RawMessage 原样保存
+	Publish(ctx context.Context, payload *dto.WSPayload) error
+}
+
+// EventSource 以消费组的方式从事件流中读取事件
+// 同一个消费组中的事件只会投递给其中一个消费者，消费者处理完成后需要 Ack，超时未 Ack 的事件会重新投递
+type EventSource interface {
+	// Read 读取最多 count 个事件，没有事件时最多等待 block，超时返回空
+	Read(ctx context.Context, group, consumer string, count int, block time.Duration) ([]*Message, error)
+	// Ack 确认事件处理完成
+	Ack(ctx context.Context, group string, ids ...string) error
+}
+
+// encode 返回事件的原始数据，没有原始数据时重新序列化
+func encode(payload *dto.WSPayload) ([]byte, error) {
+	if len(payload.RawMessage) > 0 {
+		return payload.RawMessage, nil
+	}
+	return json.Marshal(payload)
+}
+
+// decode 解析事件，与 websocket 连接收到事件时的解析方式相同
+func decode(data []byte) (*dto.WSPayload, error) {
+	payload := &dto.WSPayload{}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+	payload.RawMessage = data
+	return payload, nil
+}
@@ -0,0 +1,113 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+)
+
+func newPayload(eventType dto.EventType, data string) *dto.WSPayload {
+	return &dto.WSPayload{
+		WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: eventType},
+		RawMessage:    []byte(`{"op":0,"t":"` + string(eventType) + `","d":` + data + `}`),
+	}
+}
+
+func TestMemoryStream(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStream(WithMemoryClaimIdle(50 * time.Millisecond))
+	for _, content := range []string{"1", "2", "3"} {
+		require.NoError(t, s.Publish(ctx, newPayload(dto.EventC2CMessageCreate, `{"content":"`+content+`"}`)))
+	}
+
+	// 同一个消费组中的事件只投递给一个消费者
+	a, err := s.Read(ctx, "g", "a", 2, time.Second)
+	require.NoError(t, err)
+	require.Len(t, a, 2)
+	b, err := s.Read(ctx, "g", "b", 2, time.Second)
+	require.NoError(t, err)
+	require.Len(t, b, 1)
+	assert.Equal(t, dto.EventC2CMessageCreate, b[0].Payload.Type)
+	assert.Contains(t, string(b[0].Payload.RawMessage), `"content":"3"`)
+	// 不同的消费组各自消费
+	other, err := s.Read(ctx, "other", "a", 10, time.Second)
+	require.NoError(t, err)
+	require.Len(t, other, 3)
+	require.NoError(t, s.Ack(ctx, "other", other[0].ID, other[1].ID, other[2].ID))
+
+	// 超时未 ack 的事件重新投递给其他消费者
+	require.NoError(t, s.Ack(ctx, "g", a[0].ID, b[0].ID))
+	assert.Equal(t, 1, s.Pending("g"))
+	retry, err := s.Read(ctx, "g", "b", 10, time.Second)
+	require.NoError(t, err)
+	require.Len(t, retry, 1)
+	assert.Equal(t, a[1].ID, retry[0].ID)
+	assert.Equal(t, 2, retry[0].Deliveries)
+
+	// 没有事件时等待新的事件
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = s.Publish(ctx, newPayload(dto.EventC2CMessageCreate, `{"content":"4"}`))
+	}()
+	msgs, err := s.Read(ctx, "other", "a", 10, time.Second)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, 1, msgs[0].Deliveries)
+}
+
+func TestConsumer(t *testing.T) {
+	s := NewMemoryStream(WithMemoryClaimIdle(10 * time.Millisecond))
+	// 持有连接的进程，事件转发到事件流，不在本地处理
+	gateway := event.NewDispatcher()
+	gateway.Use(Forward(s))
+	gateway.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, _ *dto.WSC2CMessageData) error {
+		t.Error("event handled by gateway")
+		return nil
+	}))
+
+	var mu sync.Mutex
+	var handled []string
+	errFailed := errors.New("failed")
+	worker := event.NewDispatcher()
+	worker.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSC2CMessageData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, data.Content)
+		if data.Content == "fail" {
+			return errFailed
+		}
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewConsumer(s, "handlers", "worker-1", WithDispatcher(worker), WithMaxDeliveries(2)).Run(ctx)
+	}()
+
+	require.NoError(t, gateway.ParseAndHandle(newPayload(dto.EventC2CMessageCreate, `{"content":"hello"}`)))
+	require.NoError(t, gateway.ParseAndHandle(newPayload(dto.EventC2CMessageCreate, `{"content":"fail"}`)))
+
+	// 处理失败的事件重试到最大投递次数后丢弃
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 3 && s.Pending("handlers") == 0
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"hello", "fail", "fail"}, handled)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("consumer not stopped")
+	}
+}
@@ -0,0 +1,125 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/log"
+)
+
+const (
+	// DefaultBatchSize 每次读取的默认事件数量
+	DefaultBatchSize = 16
+	// DefaultMaxDeliveries 默认的最大投递次数，超过之后不再重试
+	DefaultMaxDeliveries = 3
+	// readBlock 每次读取最多等待的时间，用于及时响应 ctx 结束
+	readBlock = 5 * time.Second
+	// retryInterval 读取失败时的重试间隔
+	retryInterval = time.Second
+)
+
+// ConsumerOption 消费者的配置项
+type ConsumerOption func(c *Consumer)
+
+// WithDispatcher 指定处理事件的分发器，默认使用 event.DefaultDispatcher
+func WithDispatcher(d *event.Dispatcher) ConsumerOption {
+	return func(c *Consumer) {
+		c.dispatcher = d
+	}
+}
+
+// WithBatchSize 设置每次读取的事件数量
+func WithBatchSize(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.batchSize = n
+	}
+}
+
+// WithMaxDeliveries 设置最大投递次数，handler 返回错误时事件不会 ack，等待重新投递，达到次数之后丢弃
+func WithMaxDeliveries(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.maxDeliveries = n
+	}
+}
+
+// Consumer 从事件流中读取事件，并交给分发器处理
+type Consumer struct {
+	source        EventSource
+	group         string
+	name          string
+	dispatcher    *event.Dispatcher
+	batchSize     int
+	maxDeliveries int
+}
+
+// NewConsumer 创建消费者，group 相同的消费者共同消费事件流，name 在消费组中需要唯一，比如使用主机名
+func NewConsumer(source EventSource, group, name string, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		source:        source,
+		group:         group,
+		name:          name,
+		dispatcher:    event.DefaultDispatcher,
+		batchSize:     DefaultBatchSize,
+		maxDeliveries: DefaultMaxDeliveries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = DefaultBatchSize
+	}
+	if c.maxDeliveries <= 0 {
+		c.maxDeliveries = DefaultMaxDeliveries
+	}
+	return c
+}
+
+// Run 持续消费事件，阻塞直到 ctx 结束，正在处理的事件处理完成后返回
+func (c *Consumer) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		msgs, err := c.source.Read(ctx, c.group, c.name, c.batchSize, readBlock)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Errorf("[bus] read events failed, group %s, consumer %s, err: %v", c.group, c.name, err)
+			select {
+			case <-time.After(retryInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			c.handle(msg)
+		}
+	}
+	return nil
+}
+
+// handle 处理一个事件，处理成功或者达到最大投递次数时 ack
+func (c *Consumer) handle(msg *Message) {
+	err := c.dispatch(msg)
+	if err != nil {
+		if msg.Deliveries < c.maxDeliveries {
+			log.Errorf("[bus] handle event %s failed, delivery %d, wait for retry, err: %v",
+				msg.ID, msg.Deliveries, err)
+			return
+		}
+		log.Errorf("[bus] handle event %s failed, delivery %d, drop it, err: %v", msg.ID, msg.Deliveries, err)
+	}
+	// 退出过程中仍然需要 ack 已经处理完成的事件
+	if err := c.source.Ack(context.Background(), c.group, msg.ID); err != nil {
+		log.Errorf("[bus] ack event %s failed, err: %v", msg.ID, err)
+	}
+}
+
+func (c *Consumer) dispatch(msg *Message) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return c.dispatcher.ParseAndHandle(msg.Payload)
+}
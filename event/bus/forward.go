@@ -0,0 +1,21 @@
+package bus
+
+import (
+	"context"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+)
+
+// Forward 返回把事件发布到 sink 的中间件，注册之后事件不再投递给本地的 handler，由消费者进程处理
+// 非 dispatch 事件以及 RESUMED 事件与连接相关，仍然在本地处理
+func Forward(sink EventSink) event.Middleware {
+	return func(next event.Handler) event.Handler {
+		return func(payload *dto.WSPayload) error {
+			if payload.OPCode != dto.WSDispatchEvent || payload.Type == "RESUMED" {
+				return next(payload)
+			}
+			return sink.Publish(context.Background(), payload)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package bus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+const (
+	// DefaultMaxLen 事件流默认保留的最大事件数量，超过时删除最早的事件
+	DefaultMaxLen = 100000
+	// DefaultClaimIdle 默认超过这个时间未 ack 的事件会重新投递给其他消费者
+	DefaultClaimIdle = time.Minute
+)
+
+// MemoryOption 内存事件流的配置项
+type MemoryOption func(s *MemoryStream)
+
+// WithMemoryMaxLen 设置保留的最大事件数量
+func WithMemoryMaxLen(n int) MemoryOption {
+	return func(s *MemoryStream) {
+		s.maxLen = n
+	}
+}
+
+// WithMemoryClaimIdle 设置未 ack 的事件重新投递的时间
+func WithMemoryClaimIdle(d time.Duration) MemoryOption {
+	return func(s *MemoryStream) {
+		s.claimIdle = d
+	}
+}
+
+// MemoryStream 基于内存的事件流，只能在同一个进程内使用，用于测试以及单进程部署
+type MemoryStream struct {
+	maxLen    int
+	claimIdle time.Duration
+
+	mu      sync.Mutex
+	entries []memoryEntry // 按发布顺序保存，超过 maxLen 时删除最早的事件
+	lastID  uint64
+	groups  map[string]*memoryGroup
+	notify  chan struct{} // 发布事件时关闭并替换，唤醒等待中的 Read
+}
+
+type memoryEntry struct {
+	id   uint64
+	data []byte
+}
+
+type memoryGroup struct {
+	lastID  uint64 // 已经投递的最后一个事件
+	pending map[uint64]*memoryPending
+}
+
+type memoryPending struct {
+	consumer    string
+	deliveredAt time.Time
+	deliveries  int
+}
+
+// NewMemoryStream 创建基于内存的事件流
+func NewMemoryStream(opts ...MemoryOption) *MemoryStream {
+	s := &MemoryStream{
+		maxLen:    DefaultMaxLen,
+		claimIdle: DefaultClaimIdle,
+		groups:    map[string]*memoryGroup{},
+		notify:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish 发布事件
+func (s *MemoryStream) Publish(_ context.Context, payload *dto.WSPayload) error {
+	data, err := encode(payload)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	s.entries = append(s.entries, memoryEntry{id: s.lastID, data: append([]byte(nil), data...)})
+	if s.maxLen > 0 && len(s.entries) > s.maxLen {
+		s.entries = s.entries[len(s.entries)-s.maxLen:]
+	}
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return nil
+}
+
+// Read 读取事件，优先重新投递超时未 ack 的事件，消费组在第一次读取时创建，从保留的最早的事件开始消费
+func (s *MemoryStream) Read(ctx context.Context, group, consumer string, count int,
+	block time.Duration) ([]*Message, error) {
+	timer := time.NewTimer(block)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		msgs := s.readLocked(group, consumer, count)
+		notify := s.notify
+		s.mu.Unlock()
+		if len(msgs) > 0 {
+			return msgs, nil
+		}
+		// 等待期间可能有事件超时未 ack，需要重新检查
+		var recheck <-chan time.Time
+		if s.claimIdle > 0 {
+			recheck = time.After(s.claimIdle)
+		}
+		select {
+		case <-notify:
+		case <-recheck:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *MemoryStream) readLocked(group, consumer string, count int) []*Message {
+	g, ok := s.groups[group]
+	if !ok {
+		g = &memoryGroup{pending: map[uint64]*memoryPending{}}
+		s.groups[group] = g
+	}
+	now := time.Now()
+	var msgs []*Message
+	for _, e := range s.entries {
+		if len(msgs) >= count {
+			break
+		}
+		p, ok := g.pending[e.id]
+		switch {
+		case ok && s.claimIdle > 0 && now.Sub(p.deliveredAt) >= s.claimIdle:
+			// 超时未 ack，重新投递
+		case !ok && e.id > g.lastID:
+			p = &memoryPending{}
+			g.pending[e.id] = p
+			g.lastID = e.id
+		default:
+			continue
+		}
+		p.consumer = consumer
+		p.deliveredAt = now
+		p.deliveries++
+		msg, err := s.message(e, p.deliveries)
+		if err != nil {
+			// 无法解析的事件不再投递
+			delete(g.pending, e.id)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func (s *MemoryStream) message(e memoryEntry, deliveries int) (*Message, error) {
+	payload, err := decode(e.data)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{ID: strconv.FormatUint(e.id, 10), Payload: payload, Deliveries: deliveries}, nil
+}
+
+// Ack 确认事件处理完成
+func (s *MemoryStream) Ack(_ context.Context, group string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return nil
+	}
+	for _, id := range ids {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		delete(g.pending, n)
+	}
+	return nil
+}
+
+// Pending 消费组中已经投递但是还没有 ack 的事件数量
+func (s *MemoryStream) Pending(group string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.groups[group]; ok {
+		return len(g.pending)
+	}
+	return 0
+}
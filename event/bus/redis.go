@@ -0,0 +1,187 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/log"
+)
+
+// defaultRedisStream redis 中事件流的默认 key
+const defaultRedisStream = "botgo:events"
+
+// redisDataField 事件原始数据在 stream entry 中的字段名
+const redisDataField = "data"
+
+// RedisOption redis 事件流的配置项
+type RedisOption func(s *RedisStream)
+
+// WithStream 自定义 stream 的 key，多个机器人共用 redis 时需要区分
+func WithStream(key string) RedisOption {
+	return func(s *RedisStream) {
+		s.stream = key
+	}
+}
+
+// WithMaxLen 设置 stream 保留的最大事件数量，近似裁剪，小于等于 0 时不裁剪
+func WithMaxLen(n int64) RedisOption {
+	return func(s *RedisStream) {
+		s.maxLen = n
+	}
+}
+
+// WithClaimIdle 设置未 ack 的事件重新投递的时间，小于等于 0 时不重新投递
+func WithClaimIdle(d time.Duration) RedisOption {
+	return func(s *RedisStream) {
+		s.claimIdle = d
+	}
+}
+
+// RedisStream 基于 redis stream 的事件流，支持单机、集群以及哨兵模式
+type RedisStream struct {
+	client    redis.UniversalClient
+	stream    string
+	maxLen    int64
+	claimIdle time.Duration
+	groups    sync.Map // 已经创建的消费组
+}
+
+// NewRedisStream 创建基于 redis stream 的事件流，超时时间请在 NewClient 时候设置，读取时阻塞的时间不受 ReadTimeout 影响
+func NewRedisStream(client redis.UniversalClient, opts ...RedisOption) *RedisStream {
+	s := &RedisStream{
+		client:    client,
+		stream:    defaultRedisStream,
+		maxLen:    DefaultMaxLen,
+		claimIdle: DefaultClaimIdle,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish 发布事件
+func (s *RedisStream) Publish(ctx context.Context, payload *dto.WSPayload) error {
+	data, err := encode(payload)
+	if err != nil {
+		return err
+	}
+	args := &redis.XAddArgs{Stream: s.stream, Values: map[string]interface{}{redisDataField: data}}
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+	return s.client.XAdd(ctx, args).Err()
+}
+
+// Read 读取事件，优先重新投递超时未 ack 的事件，消费组在第一次读取时创建，从保留的最早的事件开始消费
+func (s *RedisStream) Read(ctx context.Context, group, consumer string, count int,
+	block time.Duration) ([]*Message, error) {
+	if err := s.createGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	msgs, err := s.claim(ctx, group, consumer, count)
+	if err != nil || len(msgs) > 0 {
+		return msgs, err
+	}
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{s.stream, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range streams {
+		msgs = append(msgs, s.messages(ctx, group, stream.Messages, nil)...)
+	}
+	return msgs, nil
+}
+
+// claim 把超时未 ack 的事件转移给当前消费者
+func (s *RedisStream) claim(ctx context.Context, group, consumer string, count int) ([]*Message, error) {
+	if s.claimIdle <= 0 {
+		return nil, nil
+	}
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: s.stream,
+		Group:  group,
+		Idle:   s.claimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(count),
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	ids := make([]string, 0, len(pending))
+	deliveries := make(map[string]int, len(pending))
+	for _, p := range pending {
+		ids = append(ids, p.ID)
+		// 转移之后投递次数会再加一
+		deliveries[p.ID] = int(p.RetryCount) + 1
+	}
+	claimed, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   s.stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  s.claimIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.messages(ctx, group, claimed, deliveries), nil
+}
+
+// messages 解析事件，无法解析的事件直接 ack，不再投递
+func (s *RedisStream) messages(ctx context.Context, group string, entries []redis.XMessage,
+	deliveries map[string]int) []*Message {
+	msgs := make([]*Message, 0, len(entries))
+	for _, entry := range entries {
+		data, _ := entry.Values[redisDataField].(string)
+		payload, err := decode([]byte(data))
+		if err != nil {
+			log.Errorf("[bus] decode event %s failed, drop it, err: %v", entry.ID, err)
+			if err := s.Ack(ctx, group, entry.ID); err != nil {
+				log.Errorf("[bus] ack event %s failed, err: %v", entry.ID, err)
+			}
+			continue
+		}
+		n := 1
+		if d, ok := deliveries[entry.ID]; ok {
+			n = d
+		}
+		msgs = append(msgs, &Message{ID: entry.ID, Payload: payload, Deliveries: n})
+	}
+	return msgs
+}
+
+// Ack 确认事件处理完成
+func (s *RedisStream) Ack(ctx context.Context, group string, ids ...string) error {
+	return s.client.XAck(ctx, s.stream, group, ids...).Err()
+}
+
+// createGroup 创建消费组，已经存在时忽略
+func (s *RedisStream) createGroup(ctx context.Context, group string) error {
+	if _, ok := s.groups.Load(group); ok {
+		return nil
+	}
+	err := s.client.XGroupCreateMkStream(ctx, s.stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create consumer group %s failed: %w", group, err)
+	}
+	s.groups.Store(group, struct{}{})
+	return nil
+}
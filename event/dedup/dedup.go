@@ -0,0 +1,124 @@
+// Package dedup 提供事件去重的中间件。
+//
+// websocket resume 之后网关可能重放事件，webhook 在回包失败或者丢失时也会重试投递，
+// 接入去重中间件后，同一个事件在去重窗口内只会被处理一次。
+//
+//	d := dedup.New(dedup.NewMemoryStore(0))
+//	event.Use(d.Middleware())
+package dedup
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/log"
+)
+
+// DefaultWindow 默认的去重窗口
+const DefaultWindow = 5 * time.Minute
+
+// KeyFunc 生成事件的去重 key，返回空字符串时不去重
+type KeyFunc func(payload *dto.WSPayload) string
+
+// DefaultKey 默认使用事件 id 去重，事件 id 为空时使用事件数据中的 id，比如消息 id
+func DefaultKey(payload *dto.WSPayload) string {
+	id := payload.EventID
+	if id == "" {
+		id = gjson.GetBytes(payload.RawMessage, "d.id").String()
+	}
+	if id == "" {
+		return ""
+	}
+	return string(payload.Type) + ":" + id
+}
+
+// Option 去重的配置项
+type Option func(d *Deduper)
+
+// WithWindow 设置去重窗口
+func WithWindow(window time.Duration) Option {
+	return func(d *Deduper) {
+		d.window = window
+	}
+}
+
+// WithKeyFunc 自定义去重 key
+func WithKeyFunc(f KeyFunc) Option {
+	return func(d *Deduper) {
+		d.keyFunc = f
+	}
+}
+
+// Stats 去重统计
+type Stats struct {
+	Total  int64 // 参与去重的事件数量
+	Hits   int64 // 重复而被丢弃的事件数量
+	Errors int64 // 存储出错的次数，出错时事件会继续处理
+}
+
+// Deduper 事件去重
+type Deduper struct {
+	store   Store
+	window  time.Duration
+	keyFunc KeyFunc
+
+	total, hits, errors int64
+}
+
+// New 创建事件去重
+func New(store Store, opts ...Option) *Deduper {
+	d := &Deduper{store: store, window: DefaultWindow, keyFunc: DefaultKey}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Stats 返回去重统计
+func (d *Deduper) Stats() Stats {
+	return Stats{
+		Total:  atomic.LoadInt64(&d.total),
+		Hits:   atomic.LoadInt64(&d.hits),
+		Errors: atomic.LoadInt64(&d.errors),
+	}
+}
+
+// Middleware 返回去重中间件，重复的事件直接返回 nil；处理失败时会删除记录，使得重试的事件可以被再次处理
+func (d *Deduper) Middleware() event.Middleware {
+	return func(next event.Handler) event.Handler {
+		return func(payload *dto.WSPayload) error {
+			if payload.OPCode != dto.WSDispatchEvent {
+				return next(payload)
+			}
+			key := d.keyFunc(payload)
+			if key == "" {
+				return next(payload)
+			}
+			atomic.AddInt64(&d.total, 1)
+			ctx := context.Background()
+			added, err := d.store.Add(ctx, key, d.window)
+			if err != nil {
+				atomic.AddInt64(&d.errors, 1)
+				log.Errorf("[dedup] add %s failed: %v", key, err)
+				return next(payload)
+			}
+			if !added {
+				atomic.AddInt64(&d.hits, 1)
+				log.Infof("[dedup] drop duplicate event %s", key)
+				return nil
+			}
+			if err = next(payload); err != nil {
+				if rmErr := d.store.Remove(ctx, key); rmErr != nil {
+					atomic.AddInt64(&d.errors, 1)
+					log.Errorf("[dedup] remove %s failed: %v", key, rmErr)
+				}
+			}
+			return err
+		}
+	}
+}
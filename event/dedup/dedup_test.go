@@ -0,0 +1,67 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(2)
+	add := func(key string, window time.Duration) bool {
+		ok, err := s.Add(ctx, key, window)
+		assert.NoError(t, err)
+		return ok
+	}
+	assert.True(t, add("a", time.Minute))
+	assert.False(t, add("a", time.Minute))
+	assert.True(t, add("b", time.Minute))
+	assert.True(t, add("c", time.Minute)) // 超过容量，淘汰 a
+	assert.True(t, add("a", time.Minute))
+	assert.False(t, add("c", time.Minute))
+
+	assert.True(t, add("d", time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, add("d", time.Millisecond))
+	assert.NoError(t, s.Remove(ctx, "d"))
+	assert.True(t, add("d", time.Minute))
+}
+
+func TestDeduper(t *testing.T) {
+	d := New(NewMemoryStore(0))
+	dispatcher := event.NewDispatcher()
+	dispatcher.Use(d.Middleware())
+	var handled []string
+	errFailed := errors.New("failed")
+	dispatcher.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSC2CMessageData) error {
+		handled = append(handled, data.ID)
+		if data.Content == "fail" {
+			return errFailed
+		}
+		return nil
+	}))
+	send := func(eventID, msgID, content string) error {
+		return dispatcher.ParseAndHandle(&dto.WSPayload{
+			WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: dto.EventC2CMessageCreate, EventID: eventID},
+			RawMessage:    []byte(`{"op":0,"d":{"id":"` + msgID + `","content":"` + content + `"}}`),
+		})
+	}
+
+	assert.NoError(t, send("e1", "m1", "hi"))
+	assert.NoError(t, send("e1", "m1", "hi")) // resume 重放
+	assert.NoError(t, send("", "m2", "hi"))
+	assert.NoError(t, send("", "m2", "hi")) // 没有事件 id 时使用消息 id
+	// 处理失败的事件，重试时可以再次处理
+	assert.Equal(t, errFailed, send("e3", "m3", "fail"))
+	assert.Equal(t, errFailed, send("e3", "m3", "fail"))
+
+	assert.Equal(t, []string{"m1", "m2", "m3", "m3"}, handled)
+	assert.Equal(t, Stats{Total: 6, Hits: 2}, d.Stats())
+}
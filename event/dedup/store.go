@@ -0,0 +1,113 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Store 记录已经处理过的事件
+type Store interface {
+	// Add 记录 key，key 在 window 内已经存在时返回 false
+	Add(ctx context.Context, key string, window time.Duration) (bool, error)
+	// Remove 删除 key，处理失败时调用，使得重试的事件可以被再次处理
+	Remove(ctx context.Context, key string) error
+}
+
+// DefaultMemoryCapacity 内存存储默认最多记录的事件数量
+const DefaultMemoryCapacity = 100000
+
+type memoryItem struct {
+	key      string
+	expireAt time.Time
+}
+
+// MemoryStore 基于 LRU 的内存存储，超过容量时淘汰最久未写入的事件，仅适用于单实例部署
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List // 按写入时间排序，front 为最新写入
+	items    map[string]*list.Element
+}
+
+// NewMemoryStore 创建内存存储，capacity 小于等于 0 时使用 DefaultMemoryCapacity
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+	return &MemoryStore{capacity: capacity, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+// Add 记录 key
+func (s *MemoryStore) Add(_ context.Context, key string, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if e, ok := s.items[key]; ok {
+		if now.Before(e.Value.(*memoryItem).expireAt) {
+			return false, nil
+		}
+		s.remove(e)
+	}
+	s.items[key] = s.ll.PushFront(&memoryItem{key: key, expireAt: now.Add(window)})
+	for s.ll.Len() > s.capacity {
+		s.remove(s.ll.Back())
+	}
+	return true, nil
+}
+
+// Remove 删除 key
+func (s *MemoryStore) Remove(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+func (s *MemoryStore) remove(e *list.Element) {
+	s.ll.Remove(e)
+	delete(s.items, e.Value.(*memoryItem).key)
+}
+
+// defaultRedisKeyPrefix redis 中事件 key 的默认前缀
+const defaultRedisKeyPrefix = "botgo:dedup:"
+
+// RedisOption redis 存储的配置项
+type RedisOption func(s *RedisStore)
+
+// WithKeyPrefix 自定义 redis key 的前缀，多个机器人共用 redis 时需要区分
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
+}
+
+// RedisStore 基于 redis SETNX 的存储，适用于多实例部署
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore 创建基于 redis 的存储，支持单机、哨兵以及集群模式
+func NewRedisStore(client redis.UniversalClient, opts ...RedisOption) *RedisStore {
+	s := &RedisStore{client: client, prefix: defaultRedisKeyPrefix}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add 记录 key
+func (s *RedisStore) Add(ctx context.Context, key string, window time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+key, 1, window).Result()
+}
+
+// Remove 删除 key
+func (s *RedisStore) Remove(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
@@ -0,0 +1,108 @@
+package event
+
+import (
+	"sync"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// DefaultDispatcher 默认的事件分发器，使用 DefaultHandlers 处理事件，包级别的注册方法都作用于它
+var DefaultDispatcher = newDispatcher(&DefaultHandlers)
+
+// Dispatcher 事件分发器，持有自己的 handler、事件解析表与中间件
+// 同一个进程中运行多个机器人时，可以为每个机器人创建一个 Dispatcher，传递给 websocket 的 session manager 或者 webhook
+type Dispatcher struct {
+	handlers *Handlers
+
+	lock        sync.RWMutex
+	parseFuncs  map[dto.OPCode]map[dto.EventType]eventParseFunc // 通过 RegisterHandler 注册的事件解析
+	middlewares []Middleware
+	subscribers map[dto.EventType][]*subscriber // 通过 Subscribe 订阅的事件，按优先级排序
+	nextID      uint64
+}
+
+// NewDispatcher 创建一个新的事件分发器
+func NewDispatcher() *Dispatcher {
+	return newDispatcher(&Handlers{})
+}
+
+func newDispatcher(h *Handlers) *Dispatcher {
+	return &Dispatcher{
+		handlers:    h,
+		parseFuncs:  map[dto.OPCode]map[dto.EventType]eventParseFunc{},
+		subscribers: map[dto.EventType][]*subscriber{},
+	}
+}
+
+// Handlers 返回分发器使用的 handler 集合
+func (d *Dispatcher) Handlers() *Handlers {
+	return d.handlers
+}
+
+// RegisterHandlers 注册事件回调，并返回 intent 用于 websocket 的鉴权
+func (d *Dispatcher) RegisterHandlers(handlers ...interface{}) dto.Intent {
+	return d.handlers.Register(handlers...)
+}
+
+// RegisterHandler 注册回调事件处理器，会覆盖内置的事件解析
+func (d *Dispatcher) RegisterHandler(opCode dto.OPCode, eventType dto.EventType, handler eventParseFunc) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.parseFuncs[opCode] == nil {
+		d.parseFuncs[opCode] = make(map[dto.EventType]eventParseFunc)
+	}
+	d.parseFuncs[opCode][eventType] = handler
+}
+
+// Use 注册中间件，先注册的中间件在外层，即先注册的先执行
+func (d *Dispatcher) Use(m ...Middleware) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.middlewares = append(d.middlewares, m...)
+}
+
+// ParseAndHandle 处理回调事件，事件会先经过中间件，再投递给对应的 handler
+func (d *Dispatcher) ParseAndHandle(payload *dto.WSPayload) error {
+	d.lock.RLock()
+	h := Chain(d.dispatch, d.middlewares...)
+	d.lock.RUnlock()
+	return h(payload)
+}
+
+// dispatch 解析事件并投递给对应的 handler 与订阅者
+func (d *Dispatcher) dispatch(payload *dto.WSPayload) error {
+	primary := d.primary(payload)
+	var subs []*subscriber
+	if payload.OPCode == dto.WSDispatchEvent {
+		subs = d.subscribersOf(payload.Type)
+	}
+	if primary == nil && len(subs) == 0 {
+		// 透传handler，如果未注册具体类型的 handler，会统一投递到这个 handler
+		if d.handlers.Plain != nil {
+			return d.handlers.Plain(payload, payload.RawMessage)
+		}
+		return nil
+	}
+	return publish(payload, subs, primary)
+}
+
+// primary 返回通过 RegisterHandler 注册的事件解析，或者内置的事件解析
+func (d *Dispatcher) primary(payload *dto.WSPayload) Handler {
+	d.lock.RLock()
+	f, ok := d.parseFuncs[payload.OPCode][payload.Type]
+	d.lock.RUnlock()
+	// 指定类型的 handler
+	if ok {
+		return func(payload *dto.WSPayload) error {
+			return f(payload, payload.RawMessage)
+		}
+	}
+	if payload.OPCode == dto.WSDispatchEvent {
+		if f, ok := builtinParseFuncs[payload.Type]; ok {
+			return func(payload *dto.WSPayload) error {
+				return f(d.handlers, payload, payload.RawMessage)
+			}
+		}
+	}
+	return nil
+}
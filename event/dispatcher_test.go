@@ -0,0 +1,51 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+func TestDispatcher(t *testing.T) {
+	t.Parallel()
+	payload := func(eventType dto.EventType) *dto.WSPayload {
+		return &dto.WSPayload{
+			WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: eventType},
+			RawMessage:    []byte(`{"op":0,"d":{"content":"hi"}}`),
+		}
+	}
+	var got []string
+	bot := func(name string) *Dispatcher {
+		d := NewDispatcher()
+		intent := d.RegisterHandlers(
+			C2CMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSC2CMessageData) error {
+				got = append(got, name+" c2c "+data.Content)
+				return nil
+			}),
+			PlainEventHandler(func(p *dto.WSPayload, _ []byte) error {
+				got = append(got, name+" plain "+string(p.Type))
+				return nil
+			}),
+		)
+		assert.Equal(t, dto.IntentGroupMessages, intent&dto.IntentGroupMessages)
+		return d
+	}
+	a, b := bot("a"), bot("b")
+	b.RegisterHandler(dto.WSDispatchEvent, dto.EventC2CMessageCreate, func(p *dto.WSPayload, _ []byte) error {
+		got = append(got, "b custom "+string(p.Type))
+		return nil
+	})
+
+	require.NoError(t, a.ParseAndHandle(payload(dto.EventC2CMessageCreate)))
+	require.NoError(t, b.ParseAndHandle(payload(dto.EventC2CMessageCreate)))
+	require.NoError(t, a.ParseAndHandle(payload("UNKNOWN")))
+	assert.Equal(t, []string{"a c2c hi", "b custom C2C_MESSAGE_CREATE", "a plain UNKNOWN"}, got)
+
+	// 实例之间不会互相影响，也不会影响默认的 DefaultHandlers
+	assert.Nil(t, DefaultHandlers.C2CMessage)
+	assert.NotSame(t, a.Handlers(), b.Handlers())
+	assert.Same(t, &DefaultHandlers, DefaultDispatcher.Handlers())
+}
@@ -3,97 +3,76 @@ package event
 
 import (
 	"encoding/json"
-	"sync"
 
 	"github.com/tidwall/gjson" // 由于回包的 d 类型不确定，gjson 用于从回包json中提取 d 并进行针对性的解析
 
 	"github.com/tencent-connect/botgo/dto"
 )
 
-var eventParseFuncMapLock = new(sync.RWMutex)
-var eventParseFuncMap = map[dto.OPCode]map[dto.EventType]eventParseFunc{
-	dto.WSDispatchEvent: {
-		dto.EventGuildCreate: guildHandler,
-		dto.EventGuildUpdate: guildHandler,
-		dto.EventGuildDelete: guildHandler,
-
-		dto.EventChannelCreate: channelHandler,
-		dto.EventChannelUpdate: channelHandler,
-		dto.EventChannelDelete: channelHandler,
-
-		dto.EventGuildMemberAdd:    guildMemberHandler,
-		dto.EventGuildMemberUpdate: guildMemberHandler,
-		dto.EventGuildMemberRemove: guildMemberHandler,
-
-		dto.EventMessageCreate: messageHandler,
-		dto.EventMessageDelete: messageDeleteHandler,
-
-		dto.EventMessageReactionAdd:    messageReactionHandler,
-		dto.EventMessageReactionRemove: messageReactionHandler,
-
-		dto.EventAtMessageCreate:     atMessageHandler,
-		dto.EventPublicMessageDelete: publicMessageDeleteHandler,
-
-		dto.EventDirectMessageCreate: directMessageHandler,
-		dto.EventDirectMessageDelete: directMessageDeleteHandler,
-
-		dto.EventAudioStart:  audioHandler,
-		dto.EventAudioFinish: audioHandler,
-		dto.EventAudioOnMic:  audioHandler,
-		dto.EventAudioOffMic: audioHandler,
-
-		dto.EventMessageAuditPass:   messageAuditHandler,
-		dto.EventMessageAuditReject: messageAuditHandler,
-
-		dto.EventForumThreadCreate: threadHandler,
-		dto.EventForumThreadUpdate: threadHandler,
-		dto.EventForumThreadDelete: threadHandler,
-		dto.EventForumPostCreate:   postHandler,
-		dto.EventForumPostDelete:   postHandler,
-		dto.EventForumReplyCreate:  replyHandler,
-		dto.EventForumReplyDelete:  replyHandler,
-		dto.EventForumAuditResult:  forumAuditHandler,
-
-		dto.EventInteractionCreate:    interactionHandler,
-		dto.EventGroupAtMessageCreate: groupAtMessageHandler,
-		dto.EventC2CMessageCreate:     c2cMessageHandler,
-		dto.EventSubscribeMsgStatus:   subscribeStatusHandler,
-		dto.EventC2CFriendAdd:         c2cFriendAddHandler,
-		dto.EventC2CFriendDel:         c2cFriendDelHandler,
-		dto.EventEnterAIO:             enterAIOHandler,
-	},
+// builtinParseFuncs 内置的事件解析，解析后投递给 Handlers 中对应的 handler
+var builtinParseFuncs = map[dto.EventType]builtinParseFunc{
+	dto.EventGuildCreate: guildHandler,
+	dto.EventGuildUpdate: guildHandler,
+	dto.EventGuildDelete: guildHandler,
+
+	dto.EventChannelCreate: channelHandler,
+	dto.EventChannelUpdate: channelHandler,
+	dto.EventChannelDelete: channelHandler,
+
+	dto.EventGuildMemberAdd:    guildMemberHandler,
+	dto.EventGuildMemberUpdate: guildMemberHandler,
+	dto.EventGuildMemberRemove: guildMemberHandler,
+
+	dto.EventMessageCreate: messageHandler,
+	dto.EventMessageDelete: messageDeleteHandler,
+
+	dto.EventMessageReactionAdd:    messageReactionHandler,
+	dto.EventMessageReactionRemove: messageReactionHandler,
+
+	dto.EventAtMessageCreate:     atMessageHandler,
+	dto.EventPublicMessageDelete: publicMessageDeleteHandler,
+
+	dto.EventDirectMessageCreate: directMessageHandler,
+	dto.EventDirectMessageDelete: directMessageDeleteHandler,
+
+	dto.EventAudioStart:  audioHandler,
+	dto.EventAudioFinish: audioHandler,
+	dto.EventAudioOnMic:  audioHandler,
+	dto.EventAudioOffMic: audioHandler,
+
+	dto.EventMessageAuditPass:   messageAuditHandler,
+	dto.EventMessageAuditReject: messageAuditHandler,
+
+	dto.EventForumThreadCreate: threadHandler,
+	dto.EventForumThreadUpdate: threadHandler,
+	dto.EventForumThreadDelete: threadHandler,
+	dto.EventForumPostCreate:   postHandler,
+	dto.EventForumPostDelete:   postHandler,
+	dto.EventForumReplyCreate:  replyHandler,
+	dto.EventForumReplyDelete:  replyHandler,
+	dto.EventForumAuditResult:  forumAuditHandler,
+
+	dto.EventInteractionCreate:    interactionHandler,
+	dto.EventGroupAtMessageCreate: groupAtMessageHandler,
+	dto.EventC2CMessageCreate:     c2cMessageHandler,
+	dto.EventSubscribeMsgStatus:   subscribeStatusHandler,
+	dto.EventC2CFriendAdd:         c2cFriendAddHandler,
+	dto.EventC2CFriendDel:         c2cFriendDelHandler,
+	dto.EventEnterAIO:             enterAIOHandler,
 }
 
-// RegisterHandler 注册回调事件处理器
-func RegisterHandler(opCode dto.OPCode, eventType dto.EventType, handler eventParseFunc) {
-	eventParseFuncMapLock.Lock()
-	defer eventParseFuncMapLock.Unlock()
-	if eventParseFuncMap[opCode] == nil {
-		eventParseFuncMap[opCode] = make(map[dto.EventType]eventParseFunc)
-	}
-	eventParseFuncMap[opCode][eventType] = handler
-}
-
-func getHandler(opCode dto.OPCode, eventType dto.EventType) (eventParseFunc, bool) {
-	eventParseFuncMapLock.RLock()
-	defer eventParseFuncMapLock.RUnlock()
-	f, ok := eventParseFuncMap[opCode][eventType]
-	return f, ok
-}
+type builtinParseFunc func(h *Handlers, event *dto.WSPayload, message []byte) error
 
 type eventParseFunc func(event *dto.WSPayload, message []byte) error
 
-// ParseAndHandle 处理回调事件
+// RegisterHandler 注册回调事件处理器，会覆盖内置的事件解析
+func RegisterHandler(opCode dto.OPCode, eventType dto.EventType, handler eventParseFunc) {
+	DefaultDispatcher.RegisterHandler(opCode, eventType, handler)
+}
+
+// ParseAndHandle 使用 DefaultDispatcher 处理回调事件
 func ParseAndHandle(payload *dto.WSPayload) error {
-	// 指定类型的 handler
-	if h, ok := getHandler(payload.OPCode, payload.Type); ok {
-		return h(payload, payload.RawMessage)
-	}
-	// 透传handler，如果未注册具体类型的 handler，会统一投递到这个 handler
-	if DefaultHandlers.Plain != nil {
-		return DefaultHandlers.Plain(payload, payload.RawMessage)
-	}
-	return nil
+	return DefaultDispatcher.ParseAndHandle(payload)
 }
 
 // ParseData 解析数据
@@ -102,255 +81,255 @@ func ParseData(message []byte, target interface{}) error {
 	return json.Unmarshal([]byte(data.String()), target)
 }
 
-func guildHandler(payload *dto.WSPayload, message []byte) error {
+func guildHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSGuildData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Guild != nil {
-		return DefaultHandlers.Guild(payload, data)
+	if h.Guild != nil {
+		return h.Guild(payload, data)
 	}
 	return nil
 }
 
-func channelHandler(payload *dto.WSPayload, message []byte) error {
+func channelHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSChannelData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Channel != nil {
-		return DefaultHandlers.Channel(payload, data)
+	if h.Channel != nil {
+		return h.Channel(payload, data)
 	}
 	return nil
 }
 
-func guildMemberHandler(payload *dto.WSPayload, message []byte) error {
+func guildMemberHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSGuildMemberData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.GuildMember != nil {
-		return DefaultHandlers.GuildMember(payload, data)
+	if h.GuildMember != nil {
+		return h.GuildMember(payload, data)
 	}
 	return nil
 }
 
-func messageHandler(payload *dto.WSPayload, message []byte) error {
+func messageHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSMessageData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Message != nil {
-		return DefaultHandlers.Message(payload, data)
+	if h.Message != nil {
+		return h.Message(payload, data)
 	}
 	return nil
 }
 
-func messageDeleteHandler(payload *dto.WSPayload, message []byte) error {
+func messageDeleteHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSMessageDeleteData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.MessageDelete != nil {
-		return DefaultHandlers.MessageDelete(payload, data)
+	if h.MessageDelete != nil {
+		return h.MessageDelete(payload, data)
 	}
 	return nil
 }
 
-func messageReactionHandler(payload *dto.WSPayload, message []byte) error {
+func messageReactionHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSMessageReactionData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.MessageReaction != nil {
-		return DefaultHandlers.MessageReaction(payload, data)
+	if h.MessageReaction != nil {
+		return h.MessageReaction(payload, data)
 	}
 	return nil
 }
 
-func atMessageHandler(payload *dto.WSPayload, message []byte) error {
+func atMessageHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSATMessageData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.ATMessage != nil {
-		return DefaultHandlers.ATMessage(payload, data)
+	if h.ATMessage != nil {
+		return h.ATMessage(payload, data)
 	}
 	return nil
 }
 
-func groupAtMessageHandler(payload *dto.WSPayload, message []byte) error {
+func groupAtMessageHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSGroupATMessageData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.GroupATMessage != nil {
-		return DefaultHandlers.GroupATMessage(payload, data)
+	if h.GroupATMessage != nil {
+		return h.GroupATMessage(payload, data)
 	}
 	return nil
 }
 
-func c2cMessageHandler(payload *dto.WSPayload, message []byte) error {
+func c2cMessageHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSC2CMessageData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.C2CMessage != nil {
-		return DefaultHandlers.C2CMessage(payload, data)
+	if h.C2CMessage != nil {
+		return h.C2CMessage(payload, data)
 	}
 	return nil
 }
 
-func subscribeStatusHandler(payload *dto.WSPayload, message []byte) error {
+func subscribeStatusHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSSubscribeMsgStatus{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.SubscribeMsgStatus != nil {
-		return DefaultHandlers.SubscribeMsgStatus(payload, data)
+	if h.SubscribeMsgStatus != nil {
+		return h.SubscribeMsgStatus(payload, data)
 	}
 	return nil
 }
 
-func c2cFriendDelHandler(payload *dto.WSPayload, message []byte) error {
+func c2cFriendDelHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSC2CFriendData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.C2CFriend != nil {
-		return DefaultHandlers.C2CFriend(payload, data)
+	if h.C2CFriend != nil {
+		return h.C2CFriend(payload, data)
 	}
 	return nil
 }
 
-func c2cFriendAddHandler(payload *dto.WSPayload, message []byte) error {
+func c2cFriendAddHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSC2CFriendData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.C2CFriend != nil {
-		return DefaultHandlers.C2CFriend(payload, data)
+	if h.C2CFriend != nil {
+		return h.C2CFriend(payload, data)
 	}
 	return nil
 }
 
-func publicMessageDeleteHandler(payload *dto.WSPayload, message []byte) error {
+func publicMessageDeleteHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSPublicMessageDeleteData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.PublicMessageDelete != nil {
-		return DefaultHandlers.PublicMessageDelete(payload, data)
+	if h.PublicMessageDelete != nil {
+		return h.PublicMessageDelete(payload, data)
 	}
 	return nil
 }
 
-func directMessageHandler(payload *dto.WSPayload, message []byte) error {
+func directMessageHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSDirectMessageData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.DirectMessage != nil {
-		return DefaultHandlers.DirectMessage(payload, data)
+	if h.DirectMessage != nil {
+		return h.DirectMessage(payload, data)
 	}
 	return nil
 }
 
-func directMessageDeleteHandler(payload *dto.WSPayload, message []byte) error {
+func directMessageDeleteHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSDirectMessageDeleteData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.DirectMessageDelete != nil {
-		return DefaultHandlers.DirectMessageDelete(payload, data)
+	if h.DirectMessageDelete != nil {
+		return h.DirectMessageDelete(payload, data)
 	}
 	return nil
 }
 
-func audioHandler(payload *dto.WSPayload, message []byte) error {
+func audioHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSAudioData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Audio != nil {
-		return DefaultHandlers.Audio(payload, data)
+	if h.Audio != nil {
+		return h.Audio(payload, data)
 	}
 	return nil
 }
 
-func threadHandler(payload *dto.WSPayload, message []byte) error {
+func threadHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSThreadData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Thread != nil {
-		return DefaultHandlers.Thread(payload, data)
+	if h.Thread != nil {
+		return h.Thread(payload, data)
 	}
 	return nil
 }
 
-func postHandler(payload *dto.WSPayload, message []byte) error {
+func postHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSPostData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Post != nil {
-		return DefaultHandlers.Post(payload, data)
+	if h.Post != nil {
+		return h.Post(payload, data)
 	}
 	return nil
 }
 
-func replyHandler(payload *dto.WSPayload, message []byte) error {
+func replyHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSReplyData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Reply != nil {
-		return DefaultHandlers.Reply(payload, data)
+	if h.Reply != nil {
+		return h.Reply(payload, data)
 	}
 	return nil
 }
 
-func forumAuditHandler(payload *dto.WSPayload, message []byte) error {
+func forumAuditHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSForumAuditData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.ForumAudit != nil {
-		return DefaultHandlers.ForumAudit(payload, data)
+	if h.ForumAudit != nil {
+		return h.ForumAudit(payload, data)
 	}
 	return nil
 }
 
-func messageAuditHandler(payload *dto.WSPayload, message []byte) error {
+func messageAuditHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSMessageAuditData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.MessageAudit != nil {
-		return DefaultHandlers.MessageAudit(payload, data)
+	if h.MessageAudit != nil {
+		return h.MessageAudit(payload, data)
 	}
 	return nil
 }
 
-func interactionHandler(payload *dto.WSPayload, message []byte) error {
+func interactionHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSInteractionData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.Interaction != nil {
-		return DefaultHandlers.Interaction(payload, data)
+	if h.Interaction != nil {
+		return h.Interaction(payload, data)
 	}
 	return nil
 }
 
-func enterAIOHandler(payload *dto.WSPayload, message []byte) error {
+func enterAIOHandler(h *Handlers, payload *dto.WSPayload, message []byte) error {
 	data := &dto.WSEnterAIOData{}
 	if err := ParseData(message, data); err != nil {
 		return err
 	}
-	if DefaultHandlers.EnterAIO != nil {
-		return DefaultHandlers.EnterAIO(payload, data)
+	if h.EnterAIO != nil {
+		return h.EnterAIO(payload, data)
 	}
 	return nil
 }
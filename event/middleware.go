@@ -0,0 +1,74 @@
+package event
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/log"
+)
+
+// Handler 处理一个事件，payload.RawMessage 中为事件的原始数据
+type Handler func(payload *dto.WSPayload) error
+
+// Middleware 事件处理中间件，可以在 next 执行前后添加逻辑，或者不调用 next 直接拦截事件
+type Middleware func(next Handler) Handler
+
+// Use 为 DefaultDispatcher 注册中间件，websocket 与 webhook 收到的事件都会经过中间件再投递给注册的 handler
+// 先注册的中间件在外层，即先注册的先执行
+func Use(m ...Middleware) {
+	DefaultDispatcher.Use(m...)
+}
+
+// Chain 使用中间件包装 handler，m[0] 在最外层
+func Chain(h Handler, m ...Middleware) Handler {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
+
+// PanicError handler panic 时由 Recover 返回的错误
+type PanicError struct {
+	Value interface{} // recover 得到的值
+	Stack []byte      // panic 时的堆栈
+}
+
+// Error 输出错误信息
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("event handler panic: %v", e.Value)
+}
+
+// Recover 捕获 handler 的 panic，打印堆栈并转换为 *PanicError 返回，避免 websocket 连接因为业务 panic 而重连
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(payload *dto.WSPayload) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					pe := &PanicError{Value: v, Stack: debug.Stack()}
+					log.Errorf("%s, event: %s, id: %s, stack:\n%s", pe, payload.Type, payload.EventID, pe.Stack)
+					err = pe
+				}
+			}()
+			return next(payload)
+		}
+	}
+}
+
+// Logger 打印事件的类型、id、耗时以及处理结果
+func Logger() Middleware {
+	return func(next Handler) Handler {
+		return func(payload *dto.WSPayload) error {
+			start := time.Now()
+			err := next(payload)
+			if err != nil {
+				log.Errorf("handle event failed, event: %s, id: %s, cost: %s, err: %v",
+					payload.Type, payload.EventID, time.Since(start), err)
+				return err
+			}
+			log.Debugf("handle event, event: %s, id: %s, cost: %s", payload.Type, payload.EventID, time.Since(start))
+			return nil
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package event
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+func TestUse(t *testing.T) {
+	d := NewDispatcher()
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(payload *dto.WSPayload) error {
+				calls = append(calls, name+" before")
+				err := next(payload)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	errDenied := errors.New("denied")
+	auth := func(next Handler) Handler {
+		return func(payload *dto.WSPayload) error {
+			if payload.EventID == "blocked" {
+				return errDenied
+			}
+			return next(payload)
+		}
+	}
+	d.Use(trace("outer"), Recover(), Logger(), trace("inner"), auth)
+	d.RegisterHandlers(C2CMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSC2CMessageData) error {
+		calls = append(calls, "handler "+data.Content)
+		if data.Content == "panic" {
+			panic("boom")
+		}
+		return nil
+	}))
+	payload := func(id, content string) *dto.WSPayload {
+		return &dto.WSPayload{
+			WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: dto.EventC2CMessageCreate, EventID: id},
+			RawMessage:    []byte(`{"op":0,"d":{"content":"` + content + `"}}`),
+		}
+	}
+
+	require.NoError(t, d.ParseAndHandle(payload("1", "hi")))
+	assert.Equal(t, []string{"outer before", "inner before", "handler hi", "inner after", "outer after"}, calls)
+
+	calls = nil
+	assert.Equal(t, errDenied, d.ParseAndHandle(payload("blocked", "hi")))
+	assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, calls)
+
+	err := d.ParseAndHandle(payload("2", "panic"))
+	var pe *PanicError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, "boom", pe.Value)
+	assert.NotEmpty(t, pe.Stack)
+}
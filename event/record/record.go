@@ -0,0 +1,125 @@
+// Package record 录制 websocket 与 webhook 收到的原始数据，并在本地重放，用于复现线上问题。
+//
+//	rec, err := record.Create("events.jsonl")
+//	client.Setup(client.WithRecorder(rec))
+//	http.Handle("/qqbot", webhook.NewServer(credentials, webhook.WithDispatcher(d), webhook.WithRecorder(rec)))
+//
+//	// 本地复现，按照录制时的节奏以 10 倍速投递给注册的 handler
+//	f, _ := os.Open("events.jsonl")
+//	n, err := record.Replay(ctx, f, d, record.WithSpeed(10))
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// 数据的来源
+const (
+	SourceWebsocket = "websocket"
+	SourceWebhook   = "webhook"
+)
+
+// Record 一条录制的数据，每条记录为 JSONL 文件中的一行
+type Record struct {
+	Time       time.Time       `json:"time"`
+	Source     string          `json:"source"`
+	AppID      string          `json:"app_id,omitempty"`
+	SessionID  string          `json:"session_id,omitempty"`
+	ShardID    uint32          `json:"shard_id"`
+	ShardCount uint32          `json:"shard_count"`
+	Raw        json.RawMessage `json:"raw,omitempty"`      // 原始数据
+	RawText    string          `json:"raw_text,omitempty"` // 原始数据不是合法的 json 时，以字符串保存
+}
+
+// New 创建一条记录，session 为空时不包含 session 与分片信息
+func New(source string, session *dto.Session, raw []byte) *Record {
+	r := &Record{Time: time.Now(), Source: source}
+	if session != nil {
+		r.AppID = session.AppID
+		r.SessionID = session.ID
+		r.ShardID = session.Shards.ShardID
+		r.ShardCount = session.Shards.ShardCount
+	}
+	if json.Valid(raw) {
+		r.Raw = append(json.RawMessage(nil), raw...)
+	} else {
+		r.RawText = string(raw)
+	}
+	return r
+}
+
+// Data 返回原始数据
+func (r *Record) Data() []byte {
+	if r.Raw != nil {
+		return r.Raw
+	}
+	return []byte(r.RawText)
+}
+
+// Session 返回录制时的 session 信息
+func (r *Record) Session() *dto.Session {
+	return &dto.Session{
+		ID:     r.SessionID,
+		AppID:  r.AppID,
+		Shards: dto.ShardConfig{ShardID: r.ShardID, ShardCount: r.ShardCount},
+	}
+}
+
+// Recorder 把记录按行写入 JSONL，可以在多个连接之间共用
+type Recorder struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	out io.Writer
+}
+
+// NewRecorder 创建写入 w 的 Recorder，w 实现 io.Closer 时 Close 会关闭 w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: bufio.NewWriter(w), out: w}
+}
+
+// Create 创建写入文件的 Recorder，文件已经存在时追加写入
+func Create(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f), nil
+}
+
+// Record 写入一条记录，r 为空时不写入
+func (r *Recorder) Record(rec *Record) error {
+	if r == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	// 每条记录都写出，进程异常退出时不丢失已经收到的数据
+	return r.w.Flush()
+}
+
+// Close 关闭 Recorder
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if c, ok := r.out.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+)
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRecorder(&buf)
+	session := &dto.Session{ID: "s1", AppID: "app", Shards: dto.ShardConfig{ShardID: 1, ShardCount: 2}}
+	start := time.Now()
+	records := []*Record{
+		New(SourceWebsocket, session, []byte(`{"op":11}`)),
+		New(SourceWebsocket, session, []byte(`{"op":0,"s":1,"t":"C2C_MESSAGE_CREATE","d":{"content":"a"}}`)),
+		New(SourceWebsocket, session, []byte(`not json`)),
+		New(SourceWebhook, nil, []byte(`{"op":0,"t":"C2C_MESSAGE_CREATE","d":{"content":"b"}}`)),
+	}
+	for i, rec := range records {
+		rec.Time = start.Add(time.Duration(i) * 100 * time.Millisecond)
+		require.NoError(t, r.Record(rec))
+	}
+	assert.Equal(t, 4, bytes.Count(buf.Bytes(), []byte("\n")))
+
+	var handled []string
+	var sessions []*dto.Session
+	d := event.NewDispatcher()
+	d.RegisterHandlers(event.C2CMessageEventHandler(func(p *dto.WSPayload, data *dto.WSC2CMessageData) error {
+		handled = append(handled, data.Content)
+		sessions = append(sessions, p.Session)
+		return nil
+	}))
+
+	// 10 倍速重放，事件之间间隔 20ms
+	begin := time.Now()
+	n, err := Replay(context.Background(), bytes.NewReader(buf.Bytes()), d, WithSpeed(10))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"a", "b"}, handled)
+	assert.Equal(t, "s1", sessions[0].ID)
+	assert.Equal(t, uint32(1), sessions[0].Shards.ShardID)
+	assert.True(t, time.Since(begin) >= 20*time.Millisecond)
+
+	handled = nil
+	n, err = Replay(context.Background(), bytes.NewReader(buf.Bytes()), d, WithSpeed(0),
+		WithFilter(func(rec *Record) bool { return rec.Source == SourceWebhook }))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"b"}, handled)
+}
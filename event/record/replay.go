@@ -0,0 +1,95 @@
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/log"
+)
+
+// maxLineSize 单条记录的最大长度
+const maxLineSize = 16 * 1024 * 1024
+
+// ReplayOption 重放的配置项
+type ReplayOption func(o *replayOptions)
+
+type replayOptions struct {
+	speed  float64
+	filter func(rec *Record) bool
+}
+
+// WithSpeed 设置重放速度，1 为按照录制时的间隔投递，2 为两倍速，小于等于 0 时不等待，默认为 1
+func WithSpeed(speed float64) ReplayOption {
+	return func(o *replayOptions) {
+		o.speed = speed
+	}
+}
+
+// WithFilter 只重放 filter 返回 true 的记录，比如只重放某个分片或者某个时间段的事件
+func WithFilter(filter func(rec *Record) bool) ReplayOption {
+	return func(o *replayOptions) {
+		o.filter = filter
+	}
+}
+
+// Replay 读取录制的 JSONL，把其中的事件按顺序投递给 d，返回投递的事件数量
+// 心跳、鉴权等非事件数据会被跳过；handler 返回的错误只记录日志，不会中断重放
+func Replay(ctx context.Context, r io.Reader, d *event.Dispatcher, opts ...ReplayOption) (int, error) {
+	o := &replayOptions{speed: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	var last time.Time
+	var n, line int
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if o.filter != nil && !o.filter(rec) {
+			continue
+		}
+		payload := &dto.WSPayload{}
+		if err := json.Unmarshal(rec.Data(), payload); err != nil || payload.OPCode != dto.WSDispatchEvent {
+			continue
+		}
+		if err := wait(ctx, last, rec.Time, o.speed); err != nil {
+			return n, err
+		}
+		last = rec.Time
+		payload.RawMessage = rec.Data()
+		payload.Session = rec.Session()
+		if err := d.ParseAndHandle(payload); err != nil {
+			log.Errorf("[record] replay line %d, %s failed, %v", line, payload.Type, err)
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// wait 按照重放速度等待两条记录之间的间隔
+func wait(ctx context.Context, last, next time.Time, speed float64) error {
+	if last.IsZero() || speed <= 0 || !next.After(last) {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(time.Duration(float64(next.Sub(last)) / speed))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,13 +1,19 @@
 package event
 
 import (
+	"time"
+
 	"github.com/tencent-connect/botgo/dto"
 )
 
-// DefaultHandlers 默认的 handler 结构，管理所有支持的 handler 类型
-var DefaultHandlers struct {
+// DefaultHandlers 默认的 handler 结构，由 DefaultDispatcher 使用
+var DefaultHandlers Handlers
+
+// Handlers 管理所有支持的 handler 类型
+type Handlers struct {
 	Ready       ReadyHandler
 	ErrorNotify ErrorNotifyHandler
+	Heartbeat   HeartbeatHandler
 	Plain       PlainEventHandler
 
 	Guild       GuildEventHandler
@@ -47,6 +53,10 @@ type ReadyHandler func(event *dto.WSPayload, data *dto.WSReadyData)
 // 比如 reconnect invalidSession 等错误，错误可以转换为 bot.Err
 type ErrorNotifyHandler func(err error)
 
+// HeartbeatHandler 收到 ws 心跳 ack 时会回调，latency 为发送心跳到收到 ack 的耗时，方便使用方监控连接质量
+// 在读取消息的协程中调用，需要尽快返回
+type HeartbeatHandler func(session *dto.Session, latency time.Duration)
+
 // PlainEventHandler 透传handler
 type PlainEventHandler func(event *dto.WSPayload, message []byte) error
 
@@ -122,123 +132,133 @@ type SubscribeMsgStatusEventHandler func(event *dto.WSPayload, data *dto.WSSubsc
 // EnterAIOEventHandler 进入AIO事件 handler
 type EnterAIOEventHandler func(event *dto.WSPayload, data *dto.WSEnterAIOData) error
 
-// RegisterHandlers 注册事件回调，并返回 intent 用于 websocket 的鉴权
+// RegisterHandlers 为 DefaultDispatcher 注册事件回调，并返回 intent 用于 websocket 的鉴权
 func RegisterHandlers(handlers ...interface{}) dto.Intent {
+	return DefaultDispatcher.RegisterHandlers(handlers...)
+}
+
+// Register 注册事件回调，并返回 intent 用于 websocket 的鉴权
+func (h *Handlers) Register(handlers ...interface{}) dto.Intent {
 	var i dto.Intent
-	for _, h := range handlers {
-		switch handle := h.(type) {
-		case ReadyHandler:
-			DefaultHandlers.Ready = handle
-		case ErrorNotifyHandler:
-			DefaultHandlers.ErrorNotify = handle
-		case PlainEventHandler:
-			DefaultHandlers.Plain = handle
-		case AudioEventHandler:
-			DefaultHandlers.Audio = handle
-			i = i | dto.EventToIntent(
-				dto.EventAudioStart, dto.EventAudioFinish,
-				dto.EventAudioOnMic, dto.EventAudioOffMic,
-			)
-		case InteractionEventHandler:
-			DefaultHandlers.Interaction = handle
-			i = i | dto.EventToIntent(dto.EventInteractionCreate)
-		case SubscribeMsgStatusEventHandler:
-			DefaultHandlers.SubscribeMsgStatus = handle
-			i = i | dto.EventToIntent(dto.EventSubscribeMsgStatus)
-		case C2CFriendEventHandler:
-			DefaultHandlers.C2CFriend = handle
-			i = i | dto.EventToIntent(dto.EventC2CFriendAdd)
-		case EnterAIOEventHandler:
-			DefaultHandlers.EnterAIO = handle
-			i = i | dto.EventToIntent(dto.EventEnterAIO)
-		default:
-		}
+	for _, handler := range handlers {
+		i = i | dto.EventToIntent(h.set(handler)...)
 	}
-	i = i | registerRelationHandlers(i, handlers...)
-	i = i | registerMessageHandlers(i, handlers...)
-	i = i | registerForumHandlers(i, handlers...)
-
 	return i
 }
 
-func registerForumHandlers(i dto.Intent, handlers ...interface{}) dto.Intent {
-	for _, h := range handlers {
-		switch handle := h.(type) {
-		case ThreadEventHandler:
-			DefaultHandlers.Thread = handle
-			i = i | dto.EventToIntent(
-				dto.EventForumThreadCreate, dto.EventForumThreadUpdate, dto.EventForumThreadDelete,
-			)
-		case PostEventHandler:
-			DefaultHandlers.Post = handle
-			i = i | dto.EventToIntent(dto.EventForumPostCreate, dto.EventForumPostDelete)
-		case ReplyEventHandler:
-			DefaultHandlers.Reply = handle
-			i = i | dto.EventToIntent(dto.EventForumReplyCreate, dto.EventForumReplyDelete)
-		case ForumAuditEventHandler:
-			DefaultHandlers.ForumAudit = handle
-			i = i | dto.EventToIntent(dto.EventForumAuditResult)
-		default:
+// set 设置 handler，返回 handler 处理的事件类型
+func (h *Handlers) set(handler interface{}) []dto.EventType {
+	switch handle := handler.(type) {
+	case ReadyHandler:
+		h.Ready = handle
+	case ErrorNotifyHandler:
+		h.ErrorNotify = handle
+	case HeartbeatHandler:
+		h.Heartbeat = handle
+	case PlainEventHandler:
+		h.Plain = handle
+	case AudioEventHandler:
+		h.Audio = handle
+		return []dto.EventType{
+			dto.EventAudioStart, dto.EventAudioFinish,
+			dto.EventAudioOnMic, dto.EventAudioOffMic,
+		}
+	case InteractionEventHandler:
+		h.Interaction = handle
+		return []dto.EventType{dto.EventInteractionCreate}
+	case SubscribeMsgStatusEventHandler:
+		h.SubscribeMsgStatus = handle
+		return []dto.EventType{dto.EventSubscribeMsgStatus}
+	case C2CFriendEventHandler:
+		h.C2CFriend = handle
+		return []dto.EventType{dto.EventC2CFriendAdd, dto.EventC2CFriendDel}
+	case EnterAIOEventHandler:
+		h.EnterAIO = handle
+		return []dto.EventType{dto.EventEnterAIO}
+	default:
+		if events := h.setRelationHandler(handler); events != nil {
+			return events
 		}
+		if events := h.setMessageHandler(handler); events != nil {
+			return events
+		}
+		return h.setForumHandler(handler)
 	}
-	return i
+	return nil
 }
 
-// registerRelationHandlers 注册频道关系链相关handlers
-func registerRelationHandlers(i dto.Intent, handlers ...interface{}) dto.Intent {
-	for _, h := range handlers {
-		switch handle := h.(type) {
-		case GuildEventHandler:
-			DefaultHandlers.Guild = handle
-			i = i | dto.EventToIntent(dto.EventGuildCreate, dto.EventGuildDelete, dto.EventGuildUpdate)
-		case GuildMemberEventHandler:
-			DefaultHandlers.GuildMember = handle
-			i = i | dto.EventToIntent(dto.EventGuildMemberAdd, dto.EventGuildMemberRemove, dto.EventGuildMemberUpdate)
-		case ChannelEventHandler:
-			DefaultHandlers.Channel = handle
-			i = i | dto.EventToIntent(dto.EventChannelCreate, dto.EventChannelDelete, dto.EventChannelUpdate)
-		default:
+func (h *Handlers) setForumHandler(handler interface{}) []dto.EventType {
+	switch handle := handler.(type) {
+	case ThreadEventHandler:
+		h.Thread = handle
+		return []dto.EventType{
+			dto.EventForumThreadCreate, dto.EventForumThreadUpdate, dto.EventForumThreadDelete,
 		}
+	case PostEventHandler:
+		h.Post = handle
+		return []dto.EventType{dto.EventForumPostCreate, dto.EventForumPostDelete}
+	case ReplyEventHandler:
+		h.Reply = handle
+		return []dto.EventType{dto.EventForumReplyCreate, dto.EventForumReplyDelete}
+	case ForumAuditEventHandler:
+		h.ForumAudit = handle
+		return []dto.EventType{dto.EventForumAuditResult}
+	default:
+		return nil
 	}
-	return i
 }
 
-// registerMessageHandlers 注册消息相关的 handler
-func registerMessageHandlers(i dto.Intent, handlers ...interface{}) dto.Intent {
-	for _, h := range handlers {
-		switch handle := h.(type) {
-		case MessageEventHandler:
-			DefaultHandlers.Message = handle
-			i = i | dto.EventToIntent(dto.EventMessageCreate)
-		case ATMessageEventHandler:
-			DefaultHandlers.ATMessage = handle
-			i = i | dto.EventToIntent(dto.EventAtMessageCreate)
-		case DirectMessageEventHandler:
-			DefaultHandlers.DirectMessage = handle
-			i = i | dto.EventToIntent(dto.EventDirectMessageCreate)
-		case MessageDeleteEventHandler:
-			DefaultHandlers.MessageDelete = handle
-			i = i | dto.EventToIntent(dto.EventMessageDelete)
-		case PublicMessageDeleteEventHandler:
-			DefaultHandlers.PublicMessageDelete = handle
-			i = i | dto.EventToIntent(dto.EventPublicMessageDelete)
-		case DirectMessageDeleteEventHandler:
-			DefaultHandlers.DirectMessageDelete = handle
-			i = i | dto.EventToIntent(dto.EventDirectMessageDelete)
-		case MessageReactionEventHandler:
-			DefaultHandlers.MessageReaction = handle
-			i = i | dto.EventToIntent(dto.EventMessageReactionAdd, dto.EventMessageReactionRemove)
-		case MessageAuditEventHandler:
-			DefaultHandlers.MessageAudit = handle
-			i = i | dto.EventToIntent(dto.EventMessageAuditPass, dto.EventMessageAuditReject)
-		case GroupATMessageEventHandler:
-			DefaultHandlers.GroupATMessage = handle
-			i = i | dto.EventToIntent(dto.EventGroupAtMessageCreate)
-		case C2CMessageEventHandler:
-			DefaultHandlers.C2CMessage = handle
-			i = i | dto.EventToIntent(dto.EventC2CMessageCreate)
-		default:
-		}
+// setRelationHandler 注册频道关系链相关handlers
+func (h *Handlers) setRelationHandler(handler interface{}) []dto.EventType {
+	switch handle := handler.(type) {
+	case GuildEventHandler:
+		h.Guild = handle
+		return []dto.EventType{dto.EventGuildCreate, dto.EventGuildDelete, dto.EventGuildUpdate}
+	case GuildMemberEventHandler:
+		h.GuildMember = handle
+		return []dto.EventType{dto.EventGuildMemberAdd, dto.EventGuildMemberRemove, dto.EventGuildMemberUpdate}
+	case ChannelEventHandler:
+		h.Channel = handle
+		return []dto.EventType{dto.EventChannelCreate, dto.EventChannelDelete, dto.EventChannelUpdate}
+	default:
+		return nil
+	}
+}
+
+// setMessageHandler 注册消息相关的 handler
+func (h *Handlers) setMessageHandler(handler interface{}) []dto.EventType {
+	switch handle := handler.(type) {
+	case MessageEventHandler:
+		h.Message = handle
+		return []dto.EventType{dto.EventMessageCreate}
+	case ATMessageEventHandler:
+		h.ATMessage = handle
+		return []dto.EventType{dto.EventAtMessageCreate}
+	case DirectMessageEventHandler:
+		h.DirectMessage = handle
+		return []dto.EventType{dto.EventDirectMessageCreate}
+	case MessageDeleteEventHandler:
+		h.MessageDelete = handle
+		return []dto.EventType{dto.EventMessageDelete}
+	case PublicMessageDeleteEventHandler:
+		h.PublicMessageDelete = handle
+		return []dto.EventType{dto.EventPublicMessageDelete}
+	case DirectMessageDeleteEventHandler:
+		h.DirectMessageDelete = handle
+		return []dto.EventType{dto.EventDirectMessageDelete}
+	case MessageReactionEventHandler:
+		h.MessageReaction = handle
+		return []dto.EventType{dto.EventMessageReactionAdd, dto.EventMessageReactionRemove}
+	case MessageAuditEventHandler:
+		h.MessageAudit = handle
+		return []dto.EventType{dto.EventMessageAuditPass, dto.EventMessageAuditReject}
+	case GroupATMessageEventHandler:
+		h.GroupATMessage = handle
+		return []dto.EventType{dto.EventGroupAtMessageCreate}
+	case C2CMessageEventHandler:
+		h.C2CMessage = handle
+		return []dto.EventType{dto.EventC2CMessageCreate}
+	default:
+		return nil
 	}
-	return i
 }
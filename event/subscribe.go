@@ -0,0 +1,148 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// ErrStopPropagation 订阅者返回该错误时，优先级更低的订阅者不再执行，ParseAndHandle 返回 nil
+var ErrStopPropagation = errors.New("event: stop propagation")
+
+// SubscribeOption 订阅的配置项
+type SubscribeOption func(s *subscriber)
+
+// WithPriority 设置订阅的优先级，优先级高的先执行，相同优先级按照订阅顺序执行，默认为 0
+// 通过 RegisterHandlers 注册的 handler 相当于优先级为 0 且最先订阅
+func WithPriority(priority int) SubscribeOption {
+	return func(s *subscriber) {
+		s.priority = priority
+	}
+}
+
+type subscriber struct {
+	id       uint64
+	priority int
+	handlers *Handlers         // 只设置了订阅的 handler，用于复用内置的事件解析
+	raw      PlainEventHandler // 通过 SubscribeEvent 订阅的原始事件处理
+	events   []dto.EventType
+}
+
+func (s *subscriber) handle(payload *dto.WSPayload) error {
+	if s.raw != nil {
+		return s.raw(payload, payload.RawMessage)
+	}
+	return builtinParseFuncs[payload.Type](s.handlers, payload, payload.RawMessage)
+}
+
+// Subscription 订阅句柄，用于取消订阅
+type Subscription struct {
+	d      *Dispatcher
+	id     uint64
+	intent dto.Intent
+}
+
+// Intent 订阅的事件对应的 intent，用于 websocket 的鉴权
+func (s *Subscription) Intent() dto.Intent {
+	return s.intent
+}
+
+// Unsubscribe 取消订阅，可以重复调用
+func (s *Subscription) Unsubscribe() {
+	s.d.lock.Lock()
+	defer s.d.lock.Unlock()
+	for eventType, subs := range s.d.subscribers {
+		for i, sub := range subs {
+			if sub.id == s.id {
+				s.d.subscribers[eventType] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Subscribe 为 DefaultDispatcher 订阅事件
+func Subscribe(handler interface{}, opt ...SubscribeOption) (*Subscription, error) {
+	return DefaultDispatcher.Subscribe(handler, opt...)
+}
+
+// SubscribeEvent 为 DefaultDispatcher 订阅原始事件
+func SubscribeEvent(eventType dto.EventType, handler PlainEventHandler, opt ...SubscribeOption) *Subscription {
+	return DefaultDispatcher.SubscribeEvent(eventType, handler, opt...)
+}
+
+// Subscribe 订阅事件，handler 为 RegisterHandlers 支持的事件 handler 类型，比如 GroupATMessageEventHandler
+// 与 RegisterHandlers 不同，同一类事件可以有多个订阅者，互不覆盖
+func (d *Dispatcher) Subscribe(handler interface{}, opt ...SubscribeOption) (*Subscription, error) {
+	h := &Handlers{}
+	events := h.set(handler)
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event: unsupported handler type %T", handler)
+	}
+	return d.subscribe(&subscriber{handlers: h, events: events}, opt), nil
+}
+
+// SubscribeEvent 订阅原始事件，handler 收到的 message 为事件的原始数据，可以使用 ParseData 解析
+func (d *Dispatcher) SubscribeEvent(eventType dto.EventType, handler PlainEventHandler,
+	opt ...SubscribeOption) *Subscription {
+	return d.subscribe(&subscriber{raw: handler, events: []dto.EventType{eventType}}, opt)
+}
+
+func (d *Dispatcher) subscribe(s *subscriber, opt []SubscribeOption) *Subscription {
+	for _, o := range opt {
+		o(s)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.nextID++
+	s.id = d.nextID
+	for _, eventType := range s.events {
+		// 复制一份再修改，避免影响正在分发的事件
+		subs := append(append(make([]*subscriber, 0, len(d.subscribers[eventType])+1), d.subscribers[eventType]...), s)
+		sort.SliceStable(subs, func(i, j int) bool {
+			return subs[i].priority > subs[j].priority
+		})
+		d.subscribers[eventType] = subs
+	}
+	return &Subscription{d: d, id: s.id, intent: dto.EventToIntent(s.events...)}
+}
+
+// subscribersOf 返回事件的订阅者，按优先级从高到低排列
+func (d *Dispatcher) subscribersOf(eventType dto.EventType) []*subscriber {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.subscribers[eventType]
+}
+
+// publish 按优先级依次执行订阅者，primary 为通过 RegisterHandlers 或 RegisterHandler 注册的处理，优先级为 0
+// 订阅者返回错误时，后续订阅者仍会执行，最终返回第一个错误；返回 ErrStopPropagation 时停止执行后续订阅者
+func publish(payload *dto.WSPayload, subs []*subscriber, primary Handler) error {
+	var first error
+	run := func(h Handler) bool {
+		err := h(payload)
+		if errors.Is(err, ErrStopPropagation) {
+			return false
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+		return true
+	}
+	i := 0
+	for ; i < len(subs) && subs[i].priority > 0; i++ {
+		if !run(subs[i].handle) {
+			return first
+		}
+	}
+	if primary != nil && !run(primary) {
+		return first
+	}
+	for ; i < len(subs); i++ {
+		if !run(subs[i].handle) {
+			return first
+		}
+	}
+	return first
+}
@@ -0,0 +1,60 @@
+package event
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+func TestDispatcher_Subscribe(t *testing.T) {
+	d := NewDispatcher()
+	var got []string
+	group := func(name string, err error) GroupATMessageEventHandler {
+		return func(_ *dto.WSPayload, data *dto.WSGroupATMessageData) error {
+			got = append(got, name+" "+data.Content)
+			return err
+		}
+	}
+	errFailed := errors.New("failed")
+	d.RegisterHandlers(group("registered", nil))
+	_, err := d.Subscribe(group("low", nil), WithPriority(-1))
+	require.NoError(t, err)
+	audit, err := d.Subscribe(group("audit", nil), WithPriority(10))
+	require.NoError(t, err)
+	assert.Equal(t, dto.IntentGroupMessages, audit.Intent())
+	_, err = d.Subscribe(group("failed", errFailed))
+	require.NoError(t, err)
+	d.SubscribeEvent(dto.EventGroupAtMessageCreate, func(p *dto.WSPayload, message []byte) error {
+		data := &dto.WSGroupATMessageData{}
+		require.NoError(t, ParseData(message, data))
+		got = append(got, "raw "+data.Content)
+		return nil
+	})
+	_, err = d.Subscribe(ReadyHandler(func(*dto.WSPayload, *dto.WSReadyData) {}))
+	assert.Error(t, err)
+
+	payload := &dto.WSPayload{
+		WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: dto.EventGroupAtMessageCreate},
+		RawMessage:    []byte(`{"op":0,"d":{"content":"hi"}}`),
+	}
+	// 订阅者返回错误不影响后续订阅者
+	assert.Equal(t, errFailed, d.ParseAndHandle(payload))
+	assert.Equal(t, []string{"audit hi", "registered hi", "failed hi", "raw hi", "low hi"}, got)
+
+	got = nil
+	audit.Unsubscribe()
+	audit.Unsubscribe()
+	stop, err := d.Subscribe(group("stop", ErrStopPropagation), WithPriority(1))
+	require.NoError(t, err)
+	assert.NoError(t, d.ParseAndHandle(payload))
+	assert.Equal(t, []string{"stop hi"}, got)
+
+	got = nil
+	stop.Unsubscribe()
+	assert.Equal(t, errFailed, d.ParseAndHandle(payload))
+	assert.Equal(t, []string{"registered hi", "failed hi", "raw hi", "low hi"}, got)
+}
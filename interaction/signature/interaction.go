@@ -27,6 +27,7 @@ type ed25519Key struct {
 
 // Verify 验证签名，需要传入 http 头，httpBody
 // 请在方法外部从 http request 上读取了 body 之后再交给签名验证方法进行验证，避免重复读取
+// 每次调用都会重新生成密钥，不检查时间戳，也不拒绝重复的签名，需要防止请求被重放时请使用 Verifier
 func Verify(secret string, header http.Header, httpBody []byte) (bool, error) {
 	// 生成密钥
 	key, err := genKey(secret)
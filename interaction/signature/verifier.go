@@ -0,0 +1,152 @@
+package signature
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimestampWindow 默认允许的签名时间戳与当前时间的最大偏差
+const DefaultTimestampWindow = 5 * time.Minute
+
+var (
+	// ErrSignatureMismatch 签名与所有 secret 都不匹配
+	ErrSignatureMismatch = errors.New("signature mismatch")
+	// ErrTimestampInvalid 签名时间戳格式不正确
+	ErrTimestampInvalid = errors.New("signature timestamp invalid")
+	// ErrTimestampExpired 签名时间戳超出允许的时间窗口
+	ErrTimestampExpired = errors.New("signature timestamp out of window")
+	// ErrReplayed 签名已经使用过，请求被重放
+	ErrReplayed = errors.New("signature replayed")
+)
+
+// NonceCache 记录已经使用过的签名，dedup.NewMemoryStore、dedup.NewRedisStore 可以直接使用
+type NonceCache interface {
+	// Add 记录 nonce，nonce 在 ttl 内已经存在时返回 false
+	Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+	// Remove 删除 nonce，之后相同的签名可以再次通过验证
+	Remove(ctx context.Context, nonce string) error
+}
+
+// VerifierOption 签名验证的配置项
+type VerifierOption func(v *Verifier)
+
+// WithTimestampWindow 设置允许的签名时间戳与当前时间的最大偏差，小于等于 0 时不检查时间戳
+func WithTimestampWindow(d time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.window = d
+	}
+}
+
+// WithNonceCache 拒绝时间窗口内重复的签名，多实例部署时需要使用共享的存储
+func WithNonceCache(c NonceCache) VerifierOption {
+	return func(v *Verifier) {
+		v.nonces = c
+	}
+}
+
+// Verifier 可复用的签名验证，创建时生成并缓存公钥，检查时间戳以及重复的签名，防止请求被重放
+type Verifier struct {
+	keys   []ed25519.PublicKey
+	window time.Duration
+	nonces NonceCache
+	now    func() time.Time
+}
+
+// NewVerifier 创建签名验证，secrets 轮换期间可以同时传入新旧多个 secret，签名与任意一个匹配即通过
+func NewVerifier(secrets []string, opts ...VerifierOption) (*Verifier, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("secret invalid")
+	}
+	v := &Verifier{window: DefaultTimestampWindow, now: time.Now}
+	for _, secret := range secrets {
+		key, err := genKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, key.PublicKey)
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v, nil
+}
+
+// Verify 验证签名，通过时返回 nil
+func (v *Verifier) Verify(ctx context.Context, header http.Header, httpBody []byte) error {
+	sig := header.Get(HeaderSig)
+	sigBuffer, err := decodeSigBuffer(sig)
+	if err != nil {
+		return err
+	}
+	timestamp := header.Get(HeaderTimestamp)
+	if err := v.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+	content, err := genOriginalContent(timestamp, httpBody)
+	if err != nil {
+		return err
+	}
+	if !v.match(content, sigBuffer) {
+		return ErrSignatureMismatch
+	}
+	// 签名验证通过之后才记录，避免伪造的请求占用缓存
+	if v.nonces != nil {
+		ok, err := v.nonces.Add(ctx, nonceKey(sig), v.nonceTTL())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrReplayed
+		}
+	}
+	return nil
+}
+
+// Release 删除请求签名的记录，事件处理失败、需要平台使用相同的签名重试时调用
+func (v *Verifier) Release(ctx context.Context, header http.Header) error {
+	if v.nonces == nil {
+		return nil
+	}
+	return v.nonces.Remove(ctx, nonceKey(header.Get(HeaderSig)))
+}
+
+func nonceKey(sig string) string {
+	return "signature:" + strings.ToLower(sig)
+}
+
+func (v *Verifier) checkTimestamp(timestamp string) error {
+	if v.window <= 0 {
+		return nil
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestampInvalid
+	}
+	diff := v.now().Sub(time.Unix(sec, 0))
+	if diff > v.window || diff < -v.window {
+		return ErrTimestampExpired
+	}
+	return nil
+}
+
+func (v *Verifier) match(content, sig []byte) bool {
+	for _, key := range v.keys {
+		if ed25519.Verify(key, content, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonceTTL 签名需要覆盖时间戳前后的整个时间窗口
+func (v *Verifier) nonceTTL() time.Duration {
+	if v.window <= 0 {
+		return 2 * DefaultTimestampWindow
+	}
+	return 2 * v.window
+}
@@ -0,0 +1,77 @@
+package signature
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/event/dedup"
+)
+
+func TestVerifier(t *testing.T) {
+	const oldSecret, newSecret = "123456abcdef", "naOC0ocQE3shWLAfffVLB1rhYPG7"
+	now := time.Unix(1728981195, 0)
+	body := []byte(`{"op":0,"d":{"content":"hi"}}`)
+	sign := func(secret string, ts time.Time) http.Header {
+		header := http.Header{}
+		header.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+		sig, err := Generate(secret, header, body)
+		require.NoError(t, err)
+		header.Set(HeaderSig, sig)
+		return header
+	}
+	v, err := NewVerifier([]string{newSecret, oldSecret}, WithNonceCache(dedup.NewMemoryStore(0)))
+	require.NoError(t, err)
+	v.now = func() time.Time { return now }
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   error
+	}{
+		{name: "new secret", header: sign(newSecret, now)},
+		{name: "old secret during rotation", header: sign(oldSecret, now.Add(-time.Minute))},
+		{name: "other secret", header: sign("other secret", now), want: ErrSignatureMismatch},
+		{
+			name:   "expired",
+			header: sign(newSecret, now.Add(-DefaultTimestampWindow-time.Second)),
+			want:   ErrTimestampExpired,
+		},
+		{
+			name:   "from future",
+			header: sign(newSecret, now.Add(DefaultTimestampWindow+time.Second)),
+			want:   ErrTimestampExpired,
+		},
+		{
+			name: "invalid timestamp",
+			header: http.Header{
+				HeaderTimestamp: {"abc"},
+				HeaderSig:       {sign(newSecret, now).Get(HeaderSig)},
+			},
+			want: ErrTimestampInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, v.Verify(context.Background(), tt.header, body))
+		})
+	}
+
+	// 同一个签名只能使用一次
+	header := sign(newSecret, now.Add(time.Second))
+	assert.NoError(t, v.Verify(context.Background(), header, body))
+	assert.Equal(t, ErrReplayed, v.Verify(context.Background(), header, body))
+	// 释放之后可以使用相同的签名重试
+	require.NoError(t, v.Release(context.Background(), header))
+	assert.NoError(t, v.Verify(context.Background(), header, body))
+
+	_, err = NewVerifier(nil)
+	assert.Error(t, err)
+	_, err = NewVerifier([]string{""})
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+)
+
+// APIGatewayRequest API 网关触发云函数时的请求事件，腾讯云 SCF 与 AWS Lambda 的代理集成使用相同的字段
+type APIGatewayRequest struct {
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// APIGatewayResponse 云函数返回给 API 网关的响应
+type APIGatewayResponse struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// HandlerFunc 返回 http.HandlerFunc，用于只接受 HandlerFunc 的路由，比如 gin.WrapF、echo.WrapHandler
+func (s *Server) HandlerFunc() http.HandlerFunc {
+	return s.ServeHTTP
+}
+
+// HandleAPIGateway 处理 API 网关触发云函数的事件，用于不通过 http 服务接收请求的云函数
+func (s *Server) HandleAPIGateway(ctx context.Context, req *APIGatewayRequest) *APIGatewayResponse {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return &APIGatewayResponse{StatusCode: http.StatusBadRequest, Body: http.StatusText(http.StatusBadRequest)}
+		}
+		body = decoded
+	}
+	if int64(len(body)) > s.opts.maxBodySize {
+		return &APIGatewayResponse{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Body:       http.StatusText(http.StatusRequestEntityTooLarge),
+		}
+	}
+	header := http.Header{}
+	for k, v := range req.Headers {
+		header.Set(k, v)
+	}
+	status, rsp := s.Handle(ctx, header, body)
+	if status != http.StatusOK {
+		return &APIGatewayResponse{StatusCode: status, Body: http.StatusText(status)}
+	}
+	return &APIGatewayResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(rsp),
+	}
+}
@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/constant"
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/event/record"
+	"github.com/tencent-connect/botgo/interaction/signature"
+	"github.com/tencent-connect/botgo/log"
+	"github.com/tencent-connect/botgo/token"
+)
+
+const (
+	// DefaultMaxBodySize 默认的请求体大小限制
+	DefaultMaxBodySize = 1 << 20
+	// DefaultHandleTimeout 同步回包时，默认等待事件处理的最长时间
+	DefaultHandleTimeout = 5 * time.Second
+	// DefaultReadTimeout NewHTTPServer 创建的 http 服务默认的读取超时时间
+	DefaultReadTimeout = 10 * time.Second
+)
+
+// AckMode 事件的回包方式
+type AckMode int
+
+// 事件的回包方式
+const (
+	// AckSync 事件处理完成后回包，处理失败时通知平台重试，默认方式
+	// 处理超时时回复成功，事件在后台继续处理，避免平台重试导致同一个事件被并发处理两次
+	// 平台重试以及网络异常时仍然可能重复投递事件，需要配合 event/dedup 去重
+	AckSync AckMode = iota
+	// AckAsync 收到事件后立即回包，在后台处理事件，处理失败时平台不会重试
+	// 云函数等请求结束后会冻结实例的环境中，后台处理可能无法完成，请使用 AckSync
+	AckAsync
+)
+
+// Option webhook 服务的配置项
+type Option func(o *options)
+
+type options struct {
+	dispatcher    *event.Dispatcher
+	maxBodySize   int64
+	handleTimeout time.Duration
+	ackMode       AckMode
+	recorder      *record.Recorder
+	verifier      *signature.Verifier
+	anyMethod     bool // 接受所有请求方法，兼容 HTTPHandler
+}
+
+// WithDispatcher 指定处理事件的分发器，默认使用 event.DefaultDispatcher
+func WithDispatcher(d *event.Dispatcher) Option {
+	return func(o *options) {
+		o.dispatcher = d
+	}
+}
+
+// WithMaxBodySize 设置请求体大小限制，超过时返回 413，小于等于 0 时不限制
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+// WithHandleTimeout 设置同步回包时等待事件处理的最长时间，超时后回复成功，事件在后台继续处理
+func WithHandleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.handleTimeout = d
+	}
+}
+
+// WithAckMode 设置事件的回包方式，默认为 AckSync
+func WithAckMode(m AckMode) Option {
+	return func(o *options) {
+		o.ackMode = m
+	}
+}
+
+// WithVerifier 指定签名验证，用于配置多个 secret、时间窗口以及拒绝重复的签名
+// 默认使用 credentials 中的 secret，检查时间戳但不拒绝重复的签名
+func WithVerifier(v *signature.Verifier) Option {
+	return func(o *options) {
+		o.verifier = v
+	}
+}
+
+// WithRecorder 录制通过签名验证的回调数据，用于通过 record.Replay 在本地复现问题
+func WithRecorder(r *record.Recorder) Option {
+	return func(o *options) {
+		o.recorder = r
+	}
+}
+
+// Server webhook 回调服务，实现了 http.Handler，可以直接挂载到 http.ServeMux 以及兼容 http.Handler 的路由上
+// 会进行签名验证，回复心跳与回调地址校验，并把事件投递给 dispatcher 中注册的 handler
+type Server struct {
+	credentials *token.QQBotCredentials
+	opts        options
+	verifierErr error          // 使用 credentials 创建默认的签名验证失败时，所有请求都无法通过验证
+	wg          sync.WaitGroup // 正在后台处理的事件，包括 AckSync 模式下处理超时的事件
+}
+
+// NewServer 创建 webhook 回调服务
+func NewServer(credentials *token.QQBotCredentials, opts ...Option) *Server {
+	s := &Server{
+		credentials: credentials,
+		opts: options{
+			dispatcher:    event.DefaultDispatcher,
+			maxBodySize:   DefaultMaxBodySize,
+			handleTimeout: DefaultHandleTimeout,
+		},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	if s.opts.verifier == nil {
+		s.opts.verifier, s.verifierErr = signature.NewVerifier([]string{credentials.AppSecret})
+	}
+	return s
+}
+
+// ServeHTTP 处理回调请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.opts.anyMethod && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+	// 不依赖 ContentLength，最多多读一个字节用于判断是否超过限制
+	var reader io.Reader = r.Body
+	if s.opts.maxBodySize > 0 {
+		reader = io.LimitReader(r.Body, s.opts.maxBodySize+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		log.Errorf("read http callback body error: %s, traceID: %s", err, r.Header.Get(constant.HeaderTraceID))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if s.opts.maxBodySize > 0 && int64(len(body)) > s.opts.maxBodySize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	status, rsp := s.Handle(r.Context(), r.Header, body)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if rsp == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(rsp); err != nil {
+		log.Errorf("write http callback response error: %s, traceID: %s", err, r.Header.Get(constant.HeaderTraceID))
+	}
+}
+
+// Handle 处理已经读取的回调请求，返回 http 状态码与回包，状态码不为 200 时回包为空
+// 用于接入不使用 net/http 的框架，比如云函数 API 网关触发器的事件
+func (s *Server) Handle(ctx context.Context, header http.Header, body []byte) (int, []byte) {
+	traceID := header.Get(constant.HeaderTraceID)
+	log.Debugf("http callback body: %s, len: %d, traceID: %s", body, len(body), traceID)
+	// 签名验证
+	if err := s.verify(ctx, header, body); err != nil {
+		log.Errorf("signature verify failed, err: %v, traceID: %s", err, traceID)
+		return http.StatusUnauthorized, nil
+	}
+	session := &dto.Session{AppID: s.credentials.AppID}
+	if s.opts.recorder != nil {
+		if err := s.opts.recorder.Record(record.New(record.SourceWebhook, session, body)); err != nil {
+			log.Errorf("record http callback body error: %s, traceID: %s", err, traceID)
+		}
+	}
+	// 解析 payload
+	payload := &dto.WSPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		log.Errorf("unmarshal http callback body error: %s, traceID: %s", err, traceID)
+		return http.StatusBadRequest, nil
+	}
+	log.Debugf("payload: %+v, traceID: %s", payload, traceID)
+	// 原始数据放入，parse 的时候需要从里面提取 d
+	payload.RawMessage = body
+	payload.Session = session
+	switch payload.OPCode {
+	case dto.HTTPCallbackValidation:
+		return s.validate(header, payload, traceID)
+	case dto.WSHeartbeat:
+		seq, ok := payload.Data.(float64)
+		if !ok {
+			log.Errorf("heartbeat data invalid: %+v, traceID: %s", payload.Data, traceID)
+			return http.StatusBadRequest, nil
+		}
+		return http.StatusOK, []byte(GenHeartbeatACK(uint32(seq)))
+	case dto.WSDispatchEvent:
+		ok := s.dispatch(ctx, payload, traceID)
+		if !ok {
+			// 平台会使用相同的签名重试，删除签名的记录，避免重试被当作重放拒绝
+			if err := s.opts.verifier.Release(context.Background(), header); err != nil {
+				log.Errorf("release signature error: %s, traceID: %s", err, traceID)
+			}
+		}
+		return http.StatusOK, []byte(GenDispatchACK(ok))
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) verify(ctx context.Context, header http.Header, body []byte) error {
+	if s.verifierErr != nil {
+		return s.verifierErr
+	}
+	return s.opts.verifier.Verify(ctx, header, body)
+}
+
+// validate 回复回调地址校验
+func (s *Server) validate(header http.Header, payload *dto.WSPayload, traceID string) (int, []byte) {
+	data, _ := payload.Data.(map[string]interface{})
+	plainToken, ptOk := data["plain_token"].(string)
+	eventTs, etOk := data["event_ts"].(string)
+	if !ptOk || !etOk {
+		log.Errorf("callback data invalid: %+v, traceID: %s", payload.Data, traceID)
+		return http.StatusBadRequest, nil
+	}
+	req := &dto.WHValidationReq{
+		PlainToken: plainToken,
+		EventTs:    eventTs,
+	}
+	rsp := GenValidationACK(req, header, s.credentials.AppSecret)
+	if rsp == nil {
+		return http.StatusInternalServerError, nil
+	}
+	return http.StatusOK, rsp
+}
+
+// dispatch 按照回包方式处理事件，返回 false 时通知平台重试，只有处理完成并且失败时返回 false
+func (s *Server) dispatch(ctx context.Context, payload *dto.WSPayload, traceID string) bool {
+	if s.opts.ackMode == AckAsync {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			_ = s.handle(payload, traceID)
+		}()
+		return true
+	}
+	done := make(chan error, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		done <- s.handle(payload, traceID)
+	}()
+	var timeout <-chan time.Time
+	if s.opts.handleTimeout > 0 {
+		timer := time.NewTimer(s.opts.handleTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-done:
+		return err == nil
+	case <-timeout:
+		log.Errorf("handle %s timeout after %s, traceID: %s", payload.Type, s.opts.handleTimeout, traceID)
+	case <-ctx.Done():
+		log.Errorf("handle %s canceled, %v, traceID: %s", payload.Type, ctx.Err(), traceID)
+	}
+	// 事件仍在处理中，通知平台重试会导致重复处理
+	return true
+}
+
+// handle 解析具体事件，并投递给业务注册的 handler
+func (s *Server) handle(payload *dto.WSPayload, traceID string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+			log.Errorf("handle %s panic, %v, traceID: %s", payload.Type, v, traceID)
+		}
+	}()
+	if err = s.opts.dispatcher.ParseAndHandle(payload); err != nil {
+		log.Errorf("parseAndHandle failed, %v, traceID: %s, payload: %s", err, traceID, payload.RawMessage)
+	}
+	return err
+}
+
+// Shutdown 等待后台处理中的事件完成，ctx 结束时不再等待，返回 ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewHTTPServer 创建监听 addr 的 http 服务，设置了读写超时，path 为回调地址的路径
+func (s *Server) NewHTTPServer(addr, path string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(path, s)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: DefaultReadTimeout,
+		ReadTimeout:       DefaultReadTimeout,
+		WriteTimeout:      s.opts.handleTimeout + DefaultReadTimeout,
+		IdleTimeout:       2 * DefaultReadTimeout,
+	}
+}
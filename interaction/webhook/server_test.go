@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/event/dedup"
+	"github.com/tencent-connect/botgo/interaction/signature"
+	"github.com/tencent-connect/botgo/token"
+)
+
+var credentials = &token.QQBotCredentials{AppID: "app", AppSecret: "naOC0ocQE3shWLAfffVLB1rhYPG7"}
+
+// sign 生成带有签名的请求头
+func sign(t *testing.T, body string) http.Header {
+	return signAt(t, body, time.Now())
+}
+
+func signAt(t *testing.T, body string, ts time.Time) http.Header {
+	header := http.Header{}
+	header.Set(signature.HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	sig, err := signature.Generate(credentials.AppSecret, header, []byte(body))
+	require.NoError(t, err)
+	header.Set(signature.HeaderSig, sig)
+	return header
+}
+
+func TestServer(t *testing.T) {
+	errFailed := errors.New("failed")
+	d := event.NewDispatcher()
+	d.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSC2CMessageData) error {
+		switch data.Content {
+		case "fail":
+			return errFailed
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "panic":
+			panic("boom")
+		}
+		return nil
+	}))
+	message := func(content string) string {
+		return `{"op":0,"t":"C2C_MESSAGE_CREATE","d":{"content":"` + content + `"}}`
+	}
+	s := NewServer(credentials, WithDispatcher(d), WithMaxBodySize(256), WithHandleTimeout(50*time.Millisecond))
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		body   string
+		status int
+		rsp    string
+	}{
+		{name: "method not allowed", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+		{name: "body too large", body: strings.Repeat("a", 257), status: http.StatusRequestEntityTooLarge},
+		{name: "bad signature", header: sign(t, "other"), body: message("hi"), status: http.StatusUnauthorized},
+		{
+			name:   "expired signature",
+			header: signAt(t, message("hi"), time.Now().Add(-time.Hour)),
+			body:   message("hi"),
+			status: http.StatusUnauthorized,
+		},
+		{name: "bad json", body: "{", status: http.StatusBadRequest},
+		{name: "heartbeat", body: `{"op":1,"d":1314}`, status: http.StatusOK, rsp: GenHeartbeatACK(1314)},
+		{name: "dispatch", body: message("hi"), status: http.StatusOK, rsp: GenDispatchACK(true)},
+		{name: "handler failed", body: message("fail"), status: http.StatusOK, rsp: GenDispatchACK(false)},
+		{name: "handler panic", body: message("panic"), status: http.StatusOK, rsp: GenDispatchACK(false)},
+		{name: "handle timeout", body: message("slow"), status: http.StatusOK, rsp: GenDispatchACK(true)},
+		{
+			name:   "validation",
+			body:   `{"op":13,"d":{"plain_token":"token","event_ts":"1725442341"}}`,
+			status: http.StatusOK,
+			rsp:    `"plain_token":"token"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			header := tt.header
+			if header == nil {
+				header = sign(t, tt.body)
+			}
+			req := httptest.NewRequest(method, "/qqbot", strings.NewReader(tt.body))
+			req.Header = header
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+			if tt.rsp != "" {
+				assert.Contains(t, w.Body.String(), tt.rsp)
+			}
+		})
+	}
+	// 等待超时的事件处理完成
+	require.NoError(t, s.Shutdown(context.Background()))
+}
+
+func TestServer_Retry(t *testing.T) {
+	calls := 0
+	d := event.NewDispatcher()
+	d.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, _ *dto.WSC2CMessageData) error {
+		calls++
+		if calls == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	v, err := signature.NewVerifier([]string{credentials.AppSecret}, signature.WithNonceCache(dedup.NewMemoryStore(0)))
+	require.NoError(t, err)
+	s := NewServer(credentials, WithDispatcher(d), WithVerifier(v))
+	body := `{"op":0,"t":"C2C_MESSAGE_CREATE","d":{"content":"hi"}}`
+	header := sign(t, body)
+
+	// 处理失败后平台使用相同的签名重试，重试不会被当作重放
+	status, rsp := s.Handle(context.Background(), header, []byte(body))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, GenDispatchACK(false), string(rsp))
+	status, rsp = s.Handle(context.Background(), header, []byte(body))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, GenDispatchACK(true), string(rsp))
+	assert.Equal(t, 2, calls)
+
+	// 处理成功之后，相同的签名被拒绝
+	status, _ = s.Handle(context.Background(), header, []byte(body))
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestServer_AckAsync(t *testing.T) {
+	handled := make(chan struct{})
+	d := event.NewDispatcher()
+	d.RegisterHandlers(event.C2CMessageEventHandler(func(_ *dto.WSPayload, _ *dto.WSC2CMessageData) error {
+		time.Sleep(100 * time.Millisecond)
+		close(handled)
+		return errors.New("failed")
+	}))
+	s := NewServer(credentials, WithDispatcher(d), WithAckMode(AckAsync))
+	body := `{"op":0,"t":"C2C_MESSAGE_CREATE","d":{"content":"hi"}}`
+
+	// 立即回包，处理失败也不会通知平台重试
+	rsp := s.HandleAPIGateway(context.Background(), &APIGatewayRequest{
+		Headers: map[string]string{
+			signature.HeaderTimestamp: sign(t, body).Get(signature.HeaderTimestamp),
+			signature.HeaderSig:       sign(t, body).Get(signature.HeaderSig),
+		},
+		Body: body,
+	})
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, GenDispatchACK(true), rsp.Body)
+	select {
+	case <-handled:
+		t.Fatal("ack after handled")
+	default:
+	}
+	require.NoError(t, s.Shutdown(context.Background()))
+	select {
+	case <-handled:
+	default:
+		t.Fatal("shutdown returned before handled")
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	body := `{"op":1,"d":1314}`
+	// 兼容原有的接收规则，不限制请求方法，不检查签名时间戳
+	req := httptest.NewRequest(http.MethodPut, "/qqbot", strings.NewReader(body))
+	req.Header = signAt(t, body, time.Now().Add(-time.Hour))
+	w := httptest.NewRecorder()
+	HTTPHandler(w, req, credentials)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, GenHeartbeatACK(1314), w.Body.String())
+	assert.Same(t, legacyServer(credentials), legacyServer(credentials))
+}
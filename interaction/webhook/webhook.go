@@ -3,11 +3,10 @@ package webhook
 
 import (
 	"encoding/json"
-	"io"
 	"net/http"
 	"os"
+	"sync"
 
-	"github.com/tencent-connect/botgo/constant"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/event"
 	"github.com/tencent-connect/botgo/interaction/signature"
@@ -45,85 +44,41 @@ var DefaultGetSecretFunc = func() string {
 // HTTPHandler 用户处理回调时间，该函数实现的是 https://pkg.go.dev/net/http#HandleFunc 所要求的 handler
 // 会自动进行签名验证，心跳包回复，以及根据使用 event.RegisterHandlers 注册的 handler 去执行不同的 handler 来处理事件
 // 如果开发者不想在接收事件的地方处理，可以实现 DefaultHandlers.Plain 然后在内部处理相关的异步生产或者转发的逻辑
+//
+// 保持原有的接收规则：接受所有请求方法，不限制请求体大小，不检查签名时间戳，等待事件处理完成后回包
+// 签名验证失败时返回 401，请求体不是合法的 json 时返回 400
+//
+// Deprecated: 使用 NewServer 创建实现了 http.Handler 的回调服务，可以配置请求体大小限制、超时与回包方式
 func HTTPHandler(w http.ResponseWriter, r *http.Request, credentials *token.QQBotCredentials) {
-	defer r.Body.Close()
-	body := make([]byte, r.ContentLength)
-	if _, err := r.Body.Read(body); err != nil && err != io.EOF {
-		log.Errorf("read http callback body error: %s", err)
-		return
-	}
-	log.Debugf("http callback body: %s,len:%d", string(body), len(body))
-	log.Debugf("http callback header: %v", r.Header)
-	traceID := r.Header.Get(constant.HeaderTraceID)
-	// 签名验证
-	if pass, err := signature.Verify(credentials.AppSecret, r.Header, body); err != nil || !pass {
-		log.Errorf("signature verify failed, err: %v, traceID: %s", err, traceID)
-		return
-	}
-	// 解析 payload
-	payload := &dto.WSPayload{}
-	if err := json.Unmarshal(body, payload); err != nil {
-		log.Errorf("unmarshal http callback body error: %s, traceID: %s", err, traceID)
-		return
-	}
-	log.Info("payload:%+v", payload)
-	// 原始数据放入，parse 的时候需要从里面提取 d
-	payload.RawMessage = body
-	payload.Session = &dto.Session{AppID: credentials.AppID}
-	var result string
-	if payload.OPCode == dto.HTTPCallbackValidation {
-		data, ok := payload.Data.(map[string]interface{})
-		if !ok {
-			log.Errorf("callback data invalid: %+v, traceID: %s", payload.Data, traceID)
-			return
-		}
-		plainToken, ptOk := data["plain_token"].(string)
-		eventTs, etOk := data["event_ts"].(string)
-		if !ptOk || !etOk {
-			log.Errorf("callback data invalid: %+v, traceID: %s", payload.Data, traceID)
-		}
-		req := &dto.WHValidationReq{
-			PlainToken: plainToken,
-			EventTs:    eventTs,
-		}
-		validationRsp := GenValidationACK(req, r.Header, credentials.AppSecret)
-		if validationRsp != nil {
-			if _, err := w.Write(validationRsp); err != nil {
-				log.Errorf("write http callback response error: %s, traceID: %s", err, traceID)
-				return
-			}
-		}
-		return
-	}
+	legacyServer(credentials).ServeHTTP(w, r)
+}
 
-	result = parsePayload(payload, traceID)
-	if result != "" {
-		if _, err := w.Write([]byte(result)); err != nil {
-			log.Errorf("write http callback response error: %s, traceID: %s", err, traceID)
-			return
-		}
-	}
+// legacyServers HTTPHandler 按照 credentials 复用的回调服务
+var legacyServers sync.Map
+
+type legacyEntry struct {
+	secret string // 创建时的 secret，credentials 中的 secret 变化后重新创建
+	server *Server
 }
 
-func parsePayload(payload *dto.WSPayload, traceID string) string {
-	// 处理心跳包
-	if payload.OPCode == dto.WSHeartbeat {
-		return GenHeartbeatACK(uint32(payload.Data.(float64)))
-	}
-	// 处理事件
-	if payload.OPCode == dto.WSDispatchEvent {
-		// 解析具体事件，并投递给业务注册的 handler
-		if err := event.ParseAndHandle(payload); err != nil {
-			log.Errorf(
-				"parseAndHandle failed, %v, traceID:%s, payload: %v", err,
-				traceID, payload,
-			)
-			return GenDispatchACK(false)
+func legacyServer(credentials *token.QQBotCredentials) *Server {
+	if v, ok := legacyServers.Load(credentials); ok {
+		if e := v.(*legacyEntry); e.secret == credentials.AppSecret {
+			return e.server
 		}
-		return GenDispatchACK(true)
 	}
+	opts := []Option{WithMaxBodySize(0), WithHandleTimeout(0), func(o *options) { o.anyMethod = true }}
+	if v, err := signature.NewVerifier([]string{credentials.AppSecret}, signature.WithTimestampWindow(0)); err == nil {
+		opts = append(opts, WithVerifier(v))
+	}
+	s := NewServer(credentials, opts...)
+	legacyServers.Store(credentials, &legacyEntry{secret: credentials.AppSecret, server: s})
+	return s
+}
 
-	return ""
+// NewHTTPHandler 创建使用指定 Dispatcher 分发事件的 http handler，用于同一个进程中接入多个机器人
+func NewHTTPHandler(credentials *token.QQBotCredentials, d *event.Dispatcher, opts ...Option) http.HandlerFunc {
+	return NewServer(credentials, append([]Option{WithDispatcher(d)}, opts...)...).ServeHTTP
 }
 
 // GenValidationACK 生成回调校验回包
@@ -141,7 +96,7 @@ func GenValidationACK(req *dto.WHValidationReq, header http.Header, secret strin
 			Signature:  sig,
 		})
 	if err != nil {
-		log.Errorf("handle validation failed:", err)
+		log.Errorf("handle validation failed: %v", err)
 		return nil
 	}
 	return rsp
@@ -104,6 +104,18 @@ type MessageAPI interface {
 	RetractGroupMessage(ctx context.Context, groupID, msgID string, opt ...options.Option) error
 }
 
+// MediaAPI 富媒体文件相关接口，不包含在 OpenAPI 中，避免已有的 OpenAPI 实现无法编译
+// sdk 的 v1 实现支持该接口，通过类型断言使用：media, ok := api.(openapi.MediaAPI)
+type MediaAPI interface {
+	// UploadGroupMedia 上传群富媒体文件，返回的 MediaInfo 可以在有效期内用于发送群消息
+	UploadGroupMedia(ctx context.Context, groupID string, media *dto.MediaToUpload, opt ...options.Option) (
+		*dto.MediaInfo, error)
+
+	// UploadC2CMedia 上传单聊富媒体文件，返回的 MediaInfo 可以在有效期内用于发送单聊消息
+	UploadC2CMedia(ctx context.Context, userID string, media *dto.MediaToUpload, opt ...options.Option) (
+		*dto.MediaInfo, error)
+}
+
 // GuildAPI guild 相关接口
 type GuildAPI interface {
 	Guild(ctx context.Context, guildID string) (*dto.Guild, error)
@@ -0,0 +1,584 @@
+package openapitest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// fileTTL 模拟上传富媒体文件后的有效期，单位秒
+const fileTTL = 3600
+
+// registerRoutes 注册路由，路由模板与 openapi/v1/resource.go 中的定义保持一致
+func (s *Server) registerRoutes() {
+	s.registerUserRoutes()
+	s.registerGuildRoutes()
+	s.registerChannelRoutes()
+	s.registerMessageRoutes()
+	s.registerGroupAndC2CRoutes()
+	s.registerDMRoutes()
+	s.registerPinsAndReactionRoutes()
+	s.registerScheduleAndAnnounceRoutes()
+}
+
+func (s *Server) registerUserRoutes() {
+	s.handle(http.MethodGet, "/users/@me", func(c *call) {
+		c.json(c.state.bot)
+	})
+	s.handle(http.MethodGet, "/users/@me/guilds", func(c *call) {
+		guilds := make([]*dto.Guild, 0, len(c.state.guildIDs))
+		for _, id := range c.state.guildIDs {
+			guilds = append(guilds, c.state.guilds[id])
+		}
+		c.json(guilds)
+	})
+	gateway := func(c *call) {
+		c.json(c.state.gateway)
+	}
+	s.handle(http.MethodGet, "/gateway", gateway)
+	s.handle(http.MethodGet, "/gateway/bot", gateway)
+}
+
+func (s *Server) registerGuildRoutes() {
+	s.handle(http.MethodGet, "/guilds/{guild_id}", func(c *call) {
+		g, ok := c.state.guilds[c.param("guild_id")]
+		if !ok {
+			c.notFound("guild")
+			return
+		}
+		c.json(g)
+	})
+	s.handle(http.MethodGet, "/guilds/{guild_id}/members", func(c *call) {
+		members := c.state.members[c.param("guild_id")]
+		after := c.r.URL.Query().Get("after")
+		if after != "" && after != "0" {
+			if i, _ := c.state.member(c.param("guild_id"), after); i >= 0 {
+				members = members[i+1:]
+			}
+		}
+		c.json(limit(members, c.r.URL.Query().Get("limit")))
+	})
+	s.handle(http.MethodGet, "/guilds/{guild_id}/members/{user_id}", func(c *call) {
+		if _, m := c.state.member(c.param("guild_id"), c.param("user_id")); m != nil {
+			c.json(m)
+			return
+		}
+		c.notFound("member")
+	})
+	s.handle(http.MethodDelete, "/guilds/{guild_id}/members/{user_id}", func(c *call) {
+		guildID := c.param("guild_id")
+		i, _ := c.state.member(guildID, c.param("user_id"))
+		if i < 0 {
+			c.notFound("member")
+			return
+		}
+		c.state.members[guildID] = append(c.state.members[guildID][:i], c.state.members[guildID][i+1:]...)
+		c.noContent()
+	})
+	s.registerRoleRoutes()
+}
+
+func (s *Server) registerRoleRoutes() {
+	s.handle(http.MethodGet, "/guilds/{guild_id}/roles", func(c *call) {
+		c.json(&dto.GuildRoles{
+			GuildID: c.param("guild_id"), Roles: c.state.roles[c.param("guild_id")], NumLimit: "30",
+		})
+	})
+	s.handle(http.MethodPost, "/guilds/{guild_id}/roles", func(c *call) {
+		body := &dto.UpdateRole{}
+		if !c.bind(body) {
+			return
+		}
+		if body.Update == nil {
+			c.invalid("role info is required")
+			return
+		}
+		guildID := c.param("guild_id")
+		role := *body.Update
+		role.ID = dto.RoleID(c.state.nextID(""))
+		c.state.roles[guildID] = append(c.state.roles[guildID], &role)
+		c.json(&dto.UpdateResult{RoleID: role.ID, GuildID: guildID, Role: &role})
+	})
+	s.handle(http.MethodPatch, "/guilds/{guild_id}/roles/{role_id}", func(c *call) {
+		body := &dto.UpdateRole{}
+		if !c.bind(body) {
+			return
+		}
+		if body.Update == nil {
+			c.invalid("role info is required")
+			return
+		}
+		guildID, roleID := c.param("guild_id"), dto.RoleID(c.param("role_id"))
+		_, role := c.state.role(guildID, roleID)
+		if role == nil {
+			c.notFound("role")
+			return
+		}
+		role.Name, role.Color, role.Hoist = body.Update.Name, body.Update.Color, body.Update.Hoist
+		c.json(&dto.UpdateResult{RoleID: roleID, GuildID: guildID, Role: role})
+	})
+	s.handle(http.MethodDelete, "/guilds/{guild_id}/roles/{role_id}", func(c *call) {
+		guildID := c.param("guild_id")
+		i, _ := c.state.role(guildID, dto.RoleID(c.param("role_id")))
+		if i < 0 {
+			c.notFound("role")
+			return
+		}
+		c.state.roles[guildID] = append(c.state.roles[guildID][:i], c.state.roles[guildID][i+1:]...)
+		c.noContent()
+	})
+	memberRole := func(add bool) func(c *call) {
+		return func(c *call) {
+			guildID, roleID := c.param("guild_id"), c.param("role_id")
+			_, m := c.state.member(guildID, c.param("user_id"))
+			if m == nil {
+				c.notFound("member")
+				return
+			}
+			roles := make([]string, 0, len(m.Roles)+1)
+			for _, r := range m.Roles {
+				if r != roleID {
+					roles = append(roles, r)
+				}
+			}
+			if add {
+				roles = append(roles, roleID)
+			}
+			m.Roles = roles
+			c.noContent()
+		}
+	}
+	s.handle(http.MethodPut, "/guilds/{guild_id}/members/{user_id}/roles/{role_id}", memberRole(true))
+	s.handle(http.MethodDelete, "/guilds/{guild_id}/members/{user_id}/roles/{role_id}", memberRole(false))
+}
+
+func (s *Server) registerChannelRoutes() {
+	s.handle(http.MethodGet, "/guilds/{guild_id}/channels", func(c *call) {
+		channels := make([]*dto.Channel, 0)
+		for _, id := range c.state.channelIDs {
+			if ch := c.state.channels[id]; ch.GuildID == c.param("guild_id") {
+				channels = append(channels, ch)
+			}
+		}
+		c.json(channels)
+	})
+	s.handle(http.MethodPost, "/guilds/{guild_id}/channels", func(c *call) {
+		value := &dto.ChannelValueObject{}
+		if !c.bind(value) {
+			return
+		}
+		ch := &dto.Channel{GuildID: c.param("guild_id"), ChannelValueObject: *value}
+		c.state.addChannel(ch)
+		c.json(ch)
+	})
+	s.handle(http.MethodGet, "/channels/{channel_id}", func(c *call) {
+		ch, ok := c.state.channels[c.param("channel_id")]
+		if !ok {
+			c.notFound("channel")
+			return
+		}
+		c.json(ch)
+	})
+	s.handle(http.MethodPatch, "/channels/{channel_id}", func(c *call) {
+		ch, ok := c.state.channels[c.param("channel_id")]
+		if !ok {
+			c.notFound("channel")
+			return
+		}
+		value := &dto.ChannelValueObject{}
+		if !c.bind(value) {
+			return
+		}
+		patchChannel(ch, value)
+		c.json(ch)
+	})
+	s.handle(http.MethodDelete, "/channels/{channel_id}", func(c *call) {
+		ch, ok := c.state.channels[c.param("channel_id")]
+		if !ok {
+			c.notFound("channel")
+			return
+		}
+		c.state.deleteChannel(ch.ID)
+		c.json(ch)
+	})
+}
+
+// patchChannel 只修改请求中携带了的字段
+func patchChannel(ch *dto.Channel, value *dto.ChannelValueObject) {
+	if value.Name != "" {
+		ch.Name = value.Name
+	}
+	if value.Position != 0 {
+		ch.Position = value.Position
+	}
+	if value.ParentID != "" {
+		ch.ParentID = value.ParentID
+	}
+	if value.PrivateType != 0 {
+		ch.PrivateType = value.PrivateType
+	}
+	if value.SpeakPermission != 0 {
+		ch.SpeakPermission = value.SpeakPermission
+	}
+}
+
+func (s *Server) registerMessageRoutes() {
+	s.handle(http.MethodGet, "/channels/{channel_id}/messages", func(c *call) {
+		c.json(limit(c.state.messages[c.param("channel_id")], c.r.URL.Query().Get("limit")))
+	})
+	s.handle(http.MethodPost, "/channels/{channel_id}/messages", func(c *call) {
+		ch, ok := c.state.channels[c.param("channel_id")]
+		if !ok {
+			c.notFound("channel")
+			return
+		}
+		msg := &dto.MessageToCreate{}
+		if !c.bind(msg) {
+			return
+		}
+		c.json(c.state.postMessage(ch.ID, ch.GuildID, msg, false))
+	})
+	s.handle(http.MethodGet, "/channels/{channel_id}/messages/{message_id}", func(c *call) {
+		if _, m := c.state.message(c.param("channel_id"), c.param("message_id")); m != nil {
+			c.json(m)
+			return
+		}
+		c.notFound("message")
+	})
+	s.handle(http.MethodPatch, "/channels/{channel_id}/messages/{message_id}", func(c *call) {
+		_, m := c.state.message(c.param("channel_id"), c.param("message_id"))
+		if m == nil {
+			c.notFound("message")
+			return
+		}
+		msg := &dto.MessageToCreate{}
+		if !c.bind(msg) {
+			return
+		}
+		m.Content = msg.Content
+		m.EditedTimestamp = now()
+		c.json(m)
+	})
+	s.handle(http.MethodDelete, "/channels/{channel_id}/messages/{message_id}", func(c *call) {
+		channelID := c.param("channel_id")
+		i, m := c.state.message(channelID, c.param("message_id"))
+		if m == nil {
+			c.notFound("message")
+			return
+		}
+		c.state.messages[channelID] = append(c.state.messages[channelID][:i], c.state.messages[channelID][i+1:]...)
+		c.state.retracted[m.ID] = true
+		c.noContent()
+	})
+}
+
+func (s *Server) registerGroupAndC2CRoutes() {
+	s.handle(http.MethodPost, "/v2/groups/{group_id}/messages", func(c *call) {
+		msg := &dto.MessageToCreate{}
+		if !c.bind(msg) {
+			return
+		}
+		id := c.param("group_id")
+		c.state.groupMessages[id] = append(c.state.groupMessages[id], msg)
+		c.json(&dto.Message{ID: c.state.streamID(msg, "group-msg-"), GroupID: id, Timestamp: now()})
+	})
+	s.handle(http.MethodPost, "/v2/users/{user_id}/messages", func(c *call) {
+		msg := &dto.MessageToCreate{}
+		if !c.bind(msg) {
+			return
+		}
+		id := c.param("user_id")
+		c.state.c2cMessages[id] = append(c.state.c2cMessages[id], msg)
+		c.json(&dto.Message{ID: c.state.streamID(msg, "c2c-msg-"), Timestamp: now()})
+	})
+	upload := func(scene, param string) func(c *call) {
+		return func(c *call) {
+			msg := &dto.RichMediaMessage{}
+			if !c.bind(msg) {
+				return
+			}
+			target := scene + "/" + c.param(param)
+			c.state.files[target] = append(c.state.files[target], msg)
+			fileID := c.state.nextID("file-")
+			rsp := &dto.Message{FileUUID: fileID, FileInfo: []byte(fileID), TTL: fileTTL, Timestamp: now()}
+			if msg.SrvSendMsg {
+				rsp.ID = c.state.nextID("media-msg-")
+			}
+			c.json(rsp)
+		}
+	}
+	s.handle(http.MethodPost, "/v2/groups/{group_id}/files", upload("groups", "group_id"))
+	s.handle(http.MethodPost, "/v2/users/{user_id}/files", upload("users", "user_id"))
+	retract := func(c *call) {
+		c.state.retracted[c.param("message_id")] = true
+		c.noContent()
+	}
+	s.handle(http.MethodDelete, "/v2/groups/{group_id}/messages/{message_id}", retract)
+	s.handle(http.MethodDelete, "/v2/users/{user_id}/messages/{message_id}", retract)
+}
+
+func (s *Server) registerDMRoutes() {
+	s.handle(http.MethodPost, "/users/@me/dms", func(c *call) {
+		req := &dto.DirectMessageToCreate{}
+		if !c.bind(req) {
+			return
+		}
+		for _, dm := range c.state.dms {
+			if dm.ChannelID == "dm-"+req.RecipientID {
+				c.json(dm)
+				return
+			}
+		}
+		dm := &dto.DirectMessage{
+			GuildID:    c.state.nextID("dm-guild-"),
+			ChannelID:  "dm-" + req.RecipientID,
+			CreateTime: strconv.FormatInt(int64(c.state.seq), 10),
+		}
+		c.state.dms[dm.GuildID] = dm
+		c.json(dm)
+	})
+	s.handle(http.MethodPost, "/dms/{guild_id}/messages", func(c *call) {
+		dm, ok := c.state.dms[c.param("guild_id")]
+		if !ok {
+			c.notFound("direct message session")
+			return
+		}
+		msg := &dto.MessageToCreate{}
+		if !c.bind(msg) {
+			return
+		}
+		c.json(c.state.postMessage(dm.ChannelID, dm.GuildID, msg, true))
+	})
+	s.handle(http.MethodDelete, "/dms/{guild_id}/messages/{message_id}", func(c *call) {
+		dm, ok := c.state.dms[c.param("guild_id")]
+		if !ok {
+			c.notFound("direct message session")
+			return
+		}
+		i, m := c.state.message(dm.ChannelID, c.param("message_id"))
+		if m == nil {
+			c.notFound("message")
+			return
+		}
+		msgs := c.state.messages[dm.ChannelID]
+		c.state.messages[dm.ChannelID] = append(msgs[:i], msgs[i+1:]...)
+		c.state.retracted[m.ID] = true
+		c.noContent()
+	})
+}
+
+func (s *Server) registerPinsAndReactionRoutes() {
+	pins := func(c *call) *dto.PinsMessage {
+		channelID := c.param("channel_id")
+		p := &dto.PinsMessage{ChannelID: channelID, MessageIDs: append([]string{}, c.state.pins[channelID]...)}
+		if ch, ok := c.state.channels[channelID]; ok {
+			p.GuildID = ch.GuildID
+		}
+		return p
+	}
+	s.handle(http.MethodGet, "/channels/{channel_id}/pins", func(c *call) {
+		c.json(pins(c))
+	})
+	s.handle(http.MethodPut, "/channels/{channel_id}/pins/{message_id}", func(c *call) {
+		channelID, messageID := c.param("channel_id"), c.param("message_id")
+		if _, m := c.state.message(channelID, messageID); m == nil {
+			c.notFound("message")
+			return
+		}
+		ids := c.state.pins[channelID]
+		c.state.pins[channelID] = append(removeString(ids, messageID), messageID)
+		c.json(pins(c))
+	})
+	s.handle(http.MethodDelete, "/channels/{channel_id}/pins/{message_id}", func(c *call) {
+		channelID, messageID := c.param("channel_id"), c.param("message_id")
+		if messageID == "all" {
+			delete(c.state.pins, channelID)
+		} else {
+			c.state.pins[channelID] = removeString(c.state.pins[channelID], messageID)
+		}
+		c.noContent()
+	})
+
+	const reactionURI = "/channels/{channel_id}/messages/{message_id}/reactions/{emoji_type}/{emoji_id}"
+	reactKey := func(c *call) string {
+		return fmt.Sprintf("%s/%s/%s/%s",
+			c.param("channel_id"), c.param("message_id"), c.param("emoji_type"), c.param("emoji_id"))
+	}
+	s.handle(http.MethodPut, reactionURI, func(c *call) {
+		if _, m := c.state.message(c.param("channel_id"), c.param("message_id")); m == nil {
+			c.notFound("message")
+			return
+		}
+		key, bot := reactKey(c), c.state.bot
+		for _, u := range c.state.reacts[key] {
+			if u.ID == bot.ID {
+				c.noContent()
+				return
+			}
+		}
+		c.state.reacts[key] = append(c.state.reacts[key], &bot)
+		c.noContent()
+	})
+	s.handle(http.MethodDelete, reactionURI, func(c *call) {
+		key := reactKey(c)
+		users := make([]*dto.User, 0, len(c.state.reacts[key]))
+		for _, u := range c.state.reacts[key] {
+			if u.ID != c.state.bot.ID {
+				users = append(users, u)
+			}
+		}
+		c.state.reacts[key] = users
+		c.noContent()
+	})
+	s.handle(http.MethodGet, reactionURI, func(c *call) {
+		c.json(&dto.MessageReactionUsers{Users: c.state.reacts[reactKey(c)], IsEnd: true})
+	})
+}
+
+func (s *Server) registerScheduleAndAnnounceRoutes() {
+	s.handle(http.MethodGet, "/channels/{channel_id}/schedules", func(c *call) {
+		schedules := c.state.sched[c.param("channel_id")]
+		if schedules == nil {
+			schedules = []*dto.Schedule{}
+		}
+		c.json(schedules)
+	})
+	s.handle(http.MethodPost, "/channels/{channel_id}/schedules", func(c *call) {
+		w := &dto.ScheduleWrapper{}
+		if !c.bind(w) {
+			return
+		}
+		if w.Schedule == nil {
+			c.invalid("schedule is required")
+			return
+		}
+		channelID := c.param("channel_id")
+		sc := *w.Schedule
+		sc.ID = c.state.nextID("schedule-")
+		c.state.sched[channelID] = append(c.state.sched[channelID], &sc)
+		c.json(&sc)
+	})
+	s.handle(http.MethodGet, "/channels/{channel_id}/schedules/{schedule_id}", func(c *call) {
+		if _, sc := c.state.schedule(c.param("channel_id"), c.param("schedule_id")); sc != nil {
+			c.json(sc)
+			return
+		}
+		c.notFound("schedule")
+	})
+	s.handle(http.MethodPatch, "/channels/{channel_id}/schedules/{schedule_id}", func(c *call) {
+		i, sc := c.state.schedule(c.param("channel_id"), c.param("schedule_id"))
+		w := &dto.ScheduleWrapper{}
+		if sc == nil {
+			c.notFound("schedule")
+			return
+		}
+		if !c.bind(w) {
+			return
+		}
+		if w.Schedule == nil {
+			c.invalid("schedule is required")
+			return
+		}
+		updated := *w.Schedule
+		updated.ID = sc.ID
+		c.state.sched[c.param("channel_id")][i] = &updated
+		c.json(&updated)
+	})
+	s.handle(http.MethodDelete, "/channels/{channel_id}/schedules/{schedule_id}", func(c *call) {
+		channelID := c.param("channel_id")
+		i, _ := c.state.schedule(channelID, c.param("schedule_id"))
+		if i < 0 {
+			c.notFound("schedule")
+			return
+		}
+		c.state.sched[channelID] = append(c.state.sched[channelID][:i], c.state.sched[channelID][i+1:]...)
+		c.noContent()
+	})
+	s.registerAnnounceRoutes()
+}
+
+func (s *Server) registerAnnounceRoutes() {
+	s.handle(http.MethodPost, "/channels/{channel_id}/announces", func(c *call) {
+		req := &dto.ChannelAnnouncesToCreate{}
+		if !c.bind(req) {
+			return
+		}
+		channelID := c.param("channel_id")
+		a := &dto.Announces{ChannelID: channelID, MessageID: req.MessageID}
+		if ch, ok := c.state.channels[channelID]; ok {
+			a.GuildID = ch.GuildID
+		}
+		c.state.annos[channelID] = a
+		c.json(a)
+	})
+	s.handle(http.MethodPost, "/guilds/{guild_id}/announces", func(c *call) {
+		req := &dto.GuildAnnouncesToCreate{}
+		if !c.bind(req) {
+			return
+		}
+		a := &dto.Announces{
+			GuildID:           c.param("guild_id"),
+			ChannelID:         req.ChannelID,
+			MessageID:         req.MessageID,
+			AnnouncesType:     req.AnnouncesType,
+			RecommendChannels: req.RecommendChannels,
+		}
+		c.state.annos[a.GuildID] = a
+		c.json(a)
+	})
+	deleteAnnounce := func(key string) func(c *call) {
+		return func(c *call) {
+			id := c.param(key)
+			a, ok := c.state.annos[id]
+			if !ok {
+				c.notFound("announces")
+				return
+			}
+			// message_id 为 all 时不校验消息ID
+			if messageID := c.param("message_id"); messageID != "all" && messageID != a.MessageID {
+				c.notFound("announces")
+				return
+			}
+			delete(c.state.annos, id)
+			c.noContent()
+		}
+	}
+	s.handle(http.MethodDelete, "/channels/{channel_id}/announces/{message_id}", deleteAnnounce("channel_id"))
+	s.handle(http.MethodDelete, "/guilds/{guild_id}/announces/{message_id}", deleteAnnounce("guild_id"))
+}
+
+func limit(list interface{}, raw string) interface{} {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return list
+	}
+	switch l := list.(type) {
+	case []*dto.Member:
+		if l == nil {
+			return []*dto.Member{}
+		}
+		if len(l) > n {
+			return l[:n]
+		}
+	case []*dto.Message:
+		if l == nil {
+			return []*dto.Message{}
+		}
+		if len(l) > n {
+			return l[len(l)-n:]
+		}
+	}
+	return list
+}
+
+func removeString(list []string, target string) []string {
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		if s != target {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,345 @@
+// Package openapitest 提供一个进程内的 openapi 模拟服务，用于在没有真实凭证的情况下进行集成测试。
+//
+// 模拟服务实现了 openapi/v1 中声明的主要接口，数据保存在内存中，并且是有状态的，
+// 例如发送的消息可以再被拉取，创建的子频道可以再被修改或删除。
+// 通过 Install 将 constant.APIDomain 指向模拟服务后，openapi v1 的实现无需任何修改即可与其交互。
+//
+//	srv := openapitest.NewServer()
+//	defer srv.Close()
+//	defer srv.Install()()
+//	api := botgo.NewOpenAPI("appid", oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+package openapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/tencent-connect/botgo/constant"
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// DefaultBotID 模拟服务中机器人的默认用户ID
+const DefaultBotID = "10000"
+
+// Fault 注入的错误响应，用于模拟接口调用失败
+type Fault struct {
+	Status  int         // http 状态码
+	Code    int         // 业务错误码，会同时写入 code 与 err_code
+	Message string      // 错误原因
+	Header  http.Header // 额外的返回头，比如 Retry-After
+	Times   int         // 生效次数，0 表示一直生效
+}
+
+// Request 模拟服务收到的请求记录
+type Request struct {
+	Method  string
+	Path    string
+	Pattern string // 命中的路由模板，比如 /channels/{channel_id}/messages
+	Header  http.Header
+	Body    []byte
+}
+
+// Server 进程内的 openapi 模拟服务
+type Server struct {
+	// URL 模拟服务的地址，形如 http://127.0.0.1:port
+	URL string
+
+	srv    *httptest.Server
+	routes []*route
+
+	mu       sync.Mutex
+	state    *state
+	faults   map[string]*Fault
+	requests []*Request
+}
+
+// NewServer 创建并启动一个模拟服务，使用完毕后需要调用 Close
+func NewServer() *Server {
+	s := &Server{
+		state:  newState(),
+		faults: map[string]*Fault{},
+	}
+	s.registerRoutes()
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close 关闭模拟服务
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Install 将 constant.APIDomain 与 constant.SandBoxAPIDomain 指向模拟服务，返回用于恢复原值的函数
+func (s *Server) Install() (restore func()) {
+	apiDomain, sandboxDomain := constant.APIDomain, constant.SandBoxAPIDomain
+	constant.APIDomain = s.URL
+	constant.SandBoxAPIDomain = s.URL
+	return func() {
+		constant.APIDomain = apiDomain
+		constant.SandBoxAPIDomain = sandboxDomain
+	}
+}
+
+// InjectFault 为指定的方法与路由模板注入错误响应，pattern 与 openapi/v1 中的 uri 定义保持一致
+func (s *Server) InjectFault(method, pattern string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[faultKey(method, pattern)] = &f
+}
+
+// ClearFaults 清除所有注入的错误
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = map[string]*Fault{}
+}
+
+// Requests 返回模拟服务收到的所有请求
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// Reset 清空所有状态，包括请求记录与注入的错误
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = newState()
+	s.faults = map[string]*Fault{}
+	s.requests = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParam, err.Error())
+		return
+	}
+	rt, p := s.match(r.Method, r.URL.Path)
+	req := &Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body}
+	if rt != nil {
+		req.Pattern = rt.pattern
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	w.Header().Set(constant.HeaderTraceID, fmt.Sprintf("fake-trace-%d", len(s.requests)))
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, http.StatusUnauthorized, codeTokenInvalid, "token invalid")
+		return
+	}
+	if rt == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("route %s %s not found", r.Method, r.URL.Path))
+		return
+	}
+	if s.applyFault(w, faultKey(r.Method, rt.pattern)) {
+		return
+	}
+	rt.handle(&call{w: w, r: r, params: p, body: body, state: s.state})
+}
+
+func (s *Server) applyFault(w http.ResponseWriter, key string) bool {
+	f, ok := s.faults[key]
+	if !ok {
+		return false
+	}
+	if f.Times > 0 {
+		f.Times--
+		if f.Times == 0 {
+			delete(s.faults, key)
+		}
+	}
+	for k, v := range f.Header {
+		w.Header()[k] = v
+	}
+	writeError(w, f.Status, f.Code, f.Message)
+	return true
+}
+
+func faultKey(method, pattern string) string {
+	return strings.ToUpper(method) + " " + pattern
+}
+
+// route 一条路由规则
+type route struct {
+	method  string
+	pattern string
+	parts   []string
+	handle  func(c *call)
+}
+
+func (s *Server) handle(method, pattern string, h func(c *call)) {
+	s.routes = append(s.routes, &route{
+		method:  method,
+		pattern: pattern,
+		parts:   strings.Split(strings.Trim(pattern, "/"), "/"),
+		handle:  h,
+	})
+}
+
+func (s *Server) match(method, path string) (*route, map[string]string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for _, rt := range s.routes {
+		if rt.method != method || len(rt.parts) != len(parts) {
+			continue
+		}
+		if p, ok := matchParts(rt.parts, parts); ok {
+			return rt, p
+		}
+	}
+	return nil, nil
+}
+
+func matchParts(pattern, parts []string) (map[string]string, bool) {
+	p := map[string]string{}
+	for i, seg := range pattern {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			p[seg[1:len(seg)-1]] = parts[i]
+			continue
+		}
+		if seg != parts[i] {
+			return nil, false
+		}
+	}
+	return p, true
+}
+
+// call 一次请求的上下文
+type call struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	params map[string]string
+	body   []byte
+	state  *state
+}
+
+func (c *call) param(name string) string {
+	return c.params[name]
+}
+
+func (c *call) bind(v interface{}) bool {
+	if len(c.body) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(c.body, v); err != nil {
+		writeError(c.w, http.StatusBadRequest, codeInvalidParam, err.Error())
+		return false
+	}
+	return true
+}
+
+func (c *call) json(v interface{}) {
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(c.w).Encode(v)
+}
+
+func (c *call) noContent() {
+	c.w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *call) invalid(message string) {
+	writeError(c.w, http.StatusBadRequest, codeInvalidParam, message)
+}
+
+func (c *call) notFound(what string) {
+	writeError(c.w, http.StatusNotFound, codeNotFound, what+" not found")
+}
+
+// 模拟服务返回的错误码
+const (
+	codeInvalidParam = 10001
+	codeNotFound     = 10002
+	codeTokenInvalid = 11244
+)
+
+// errBody 与 openapi 返回的错误结构保持一致
+type errBody struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	ErrCode int    `json:"err_code"`
+	TraceID string `json:"trace_id"`
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&errBody{
+		Message: message,
+		Code:    code,
+		ErrCode: code,
+		TraceID: w.Header().Get(constant.HeaderTraceID),
+	})
+}
+
+// Bot 返回模拟服务中的机器人信息
+func (s *Server) Bot() *dto.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.state.bot
+	return &u
+}
+
+// SetGateway 设置 /gateway/bot 接口返回的接入点信息，通常与 websockettest 配合使用
+func (s *Server) SetGateway(ap dto.WebsocketAP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.gateway = ap
+}
+
+// AddGuild 预置频道数据
+func (s *Server) AddGuild(g *dto.Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.addGuild(g)
+}
+
+// AddChannel 预置子频道数据
+func (s *Server) AddChannel(ch *dto.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.addChannel(ch)
+}
+
+// AddMember 预置频道成员数据
+func (s *Server) AddMember(guildID string, m *dto.Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.addMember(guildID, m)
+}
+
+// Messages 返回子频道（或私信频道）内的消息
+func (s *Server) Messages(channelID string) []*dto.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*dto.Message(nil), s.state.messages[channelID]...)
+}
+
+// GroupMessages 返回发送到群的消息
+func (s *Server) GroupMessages(groupID string) []*dto.MessageToCreate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*dto.MessageToCreate(nil), s.state.groupMessages[groupID]...)
+}
+
+// C2CMessages 返回发送给用户的单聊消息
+func (s *Server) C2CMessages(userID string) []*dto.MessageToCreate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*dto.MessageToCreate(nil), s.state.c2cMessages[userID]...)
+}
+
+// Files 返回上传到群或单聊的富媒体文件，target 为 groups/{group_id} 或 users/{user_id}
+func (s *Server) Files(target string) []*dto.RichMediaMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*dto.RichMediaMessage(nil), s.state.files[target]...)
+}
@@ -0,0 +1,109 @@
+package openapitest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/errs"
+	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/openapitest"
+	v1 "github.com/tencent-connect/botgo/openapi/v1"
+)
+
+func newAPI(t *testing.T) (*openapitest.Server, openapi.OpenAPI) {
+	srv := openapitest.NewServer()
+	restore := srv.Install()
+	t.Cleanup(func() {
+		restore()
+		srv.Close()
+	})
+	v1.Setup()
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake", TokenType: "QQBot"})
+	return srv, openapi.VersionMapping[openapi.APIv1].Setup("appid", tokenSource, false)
+}
+
+func TestServer_GuildAndChannel(t *testing.T) {
+	srv, api := newAPI(t)
+	ctx := context.Background()
+	srv.AddGuild(&dto.Guild{ID: "g1", Name: "guild"})
+
+	me, err := api.Me(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, openapitest.DefaultBotID, me.ID)
+
+	guild, err := api.Guild(ctx, "g1")
+	require.NoError(t, err)
+	assert.Equal(t, "guild", guild.Name)
+
+	ch, err := api.PostChannel(ctx, "g1", &dto.ChannelValueObject{Name: "general"})
+	require.NoError(t, err)
+	_, err = api.PatchChannel(ctx, ch.ID, &dto.ChannelValueObject{Name: "renamed"})
+	require.NoError(t, err)
+	channels, err := api.Channels(ctx, "g1")
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	assert.Equal(t, "renamed", channels[0].Name)
+
+	require.NoError(t, api.DeleteChannel(ctx, ch.ID))
+	_, err = api.Channel(ctx, ch.ID)
+	assert.Equal(t, http.StatusNotFound, errs.Error(err).Code())
+}
+
+func TestServer_Messages(t *testing.T) {
+	srv, api := newAPI(t)
+	ctx := context.Background()
+	srv.AddChannel(&dto.Channel{ID: "c1", GuildID: "g1"})
+
+	msg, err := api.PostMessage(ctx, "c1", &dto.MessageToCreate{Content: "hello"})
+	require.NoError(t, err)
+	require.NoError(t, api.CreateMessageReaction(ctx, "c1", msg.ID, dto.Emoji{ID: "4", Type: 1}))
+	users, err := api.GetMessageReactionUsers(ctx, "c1", msg.ID, dto.Emoji{ID: "4", Type: 1},
+		&dto.MessageReactionPager{})
+	require.NoError(t, err)
+	assert.Len(t, users.Users, 1)
+
+	pins, err := api.AddPins(ctx, "c1", msg.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{msg.ID}, pins.MessageIDs)
+
+	require.NoError(t, api.RetractMessage(ctx, "c1", msg.ID))
+	assert.Empty(t, srv.Messages("c1"))
+
+	_, err = api.PostGroupMessage(ctx, "group", &dto.MessageToCreate{Content: "hi group", MsgID: "m1"})
+	require.NoError(t, err)
+	_, err = api.PostC2CMessage(ctx, "user", &dto.MessageToCreate{Content: "hi user"})
+	require.NoError(t, err)
+	assert.Equal(t, "hi group", srv.GroupMessages("group")[0].Content)
+	assert.Equal(t, "hi user", srv.C2CMessages("user")[0].Content)
+
+	file, err := api.PostGroupMessage(ctx, "group", &dto.RichMediaMessage{FileType: 1, URL: "https://a/b.png"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, file.FileInfo)
+	assert.Len(t, srv.Files("groups/group"), 1)
+}
+
+func TestServer_InjectFault(t *testing.T) {
+	srv, api := newAPI(t)
+	ctx := context.Background()
+	srv.InjectFault(http.MethodPost, "/v2/users/{user_id}/messages", openapitest.Fault{
+		Status: http.StatusInternalServerError, Code: 500, Message: "internal", Times: 1,
+	})
+
+	_, err := api.PostC2CMessage(ctx, "user", &dto.MessageToCreate{Content: "hi"})
+	assert.Equal(t, http.StatusInternalServerError, errs.Error(err).Code())
+	assert.NotEmpty(t, errs.Error(err).Trace())
+	apiErr, ok := errs.AsAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, 500, apiErr.Code)
+	assert.Equal(t, "internal", apiErr.Message)
+
+	_, err = api.PostC2CMessage(ctx, "user", &dto.MessageToCreate{Content: "hi"})
+	assert.NoError(t, err)
+	assert.Len(t, srv.Requests(), 2)
+}
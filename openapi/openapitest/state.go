@@ -0,0 +1,184 @@
+package openapitest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// state 模拟服务的内存数据，所有访问都需要持有 Server.mu
+type state struct {
+	seq     uint64
+	bot     dto.User
+	gateway dto.WebsocketAP
+
+	guildIDs []string
+	guilds   map[string]*dto.Guild
+
+	channelIDs []string
+	channels   map[string]*dto.Channel
+
+	members map[string][]*dto.Member      // guild_id -> members
+	roles   map[string][]*dto.Role        // guild_id -> roles
+	dms     map[string]*dto.DirectMessage // guild_id -> dm
+	pins    map[string][]string           // channel_id -> message_ids
+	reacts  map[string][]*dto.User        // channel/message/emoji -> users
+	sched   map[string][]*dto.Schedule    // channel_id -> schedules
+	annos   map[string]*dto.Announces     // channel_id or guild_id -> announces
+
+	messages      map[string][]*dto.Message          // channel_id -> messages
+	groupMessages map[string][]*dto.MessageToCreate  // group_openid -> messages
+	c2cMessages   map[string][]*dto.MessageToCreate  // user_openid -> messages
+	files         map[string][]*dto.RichMediaMessage // groups/{id} or users/{id} -> uploads
+	retracted     map[string]bool                    // message_id -> retracted
+}
+
+func newState() *state {
+	return &state{
+		bot: dto.User{ID: DefaultBotID, Username: "fake-bot", Bot: true},
+		gateway: dto.WebsocketAP{
+			URL:    "ws://127.0.0.1/websocket",
+			Shards: 1,
+			SessionStartLimit: dto.SessionStartLimit{
+				Total: 1000, Remaining: 1000, ResetAfter: 86400000, MaxConcurrency: 1,
+			},
+		},
+		guilds:        map[string]*dto.Guild{},
+		channels:      map[string]*dto.Channel{},
+		members:       map[string][]*dto.Member{},
+		roles:         map[string][]*dto.Role{},
+		dms:           map[string]*dto.DirectMessage{},
+		pins:          map[string][]string{},
+		reacts:        map[string][]*dto.User{},
+		sched:         map[string][]*dto.Schedule{},
+		annos:         map[string]*dto.Announces{},
+		messages:      map[string][]*dto.Message{},
+		groupMessages: map[string][]*dto.MessageToCreate{},
+		c2cMessages:   map[string][]*dto.MessageToCreate{},
+		files:         map[string][]*dto.RichMediaMessage{},
+		retracted:     map[string]bool{},
+	}
+}
+
+func (st *state) nextID(prefix string) string {
+	st.seq++
+	return fmt.Sprintf("%s%d", prefix, st.seq)
+}
+
+// streamID 流式消息的后续分片沿用第一个分片的消息 id
+func (st *state) streamID(msg *dto.MessageToCreate, prefix string) string {
+	if msg.Stream != nil && msg.Stream.ID != "" {
+		return msg.Stream.ID
+	}
+	return st.nextID(prefix)
+}
+
+func now() dto.Timestamp {
+	return dto.Timestamp(time.Now().Format(time.RFC3339))
+}
+
+func (st *state) addGuild(g *dto.Guild) {
+	if g.ID == "" {
+		g.ID = st.nextID("guild-")
+	}
+	if _, ok := st.guilds[g.ID]; !ok {
+		st.guildIDs = append(st.guildIDs, g.ID)
+	}
+	st.guilds[g.ID] = g
+}
+
+func (st *state) addChannel(ch *dto.Channel) {
+	if ch.ID == "" {
+		ch.ID = st.nextID("channel-")
+	}
+	if _, ok := st.channels[ch.ID]; !ok {
+		st.channelIDs = append(st.channelIDs, ch.ID)
+	}
+	st.channels[ch.ID] = ch
+}
+
+func (st *state) deleteChannel(id string) {
+	delete(st.channels, id)
+	for i, cid := range st.channelIDs {
+		if cid == id {
+			st.channelIDs = append(st.channelIDs[:i], st.channelIDs[i+1:]...)
+			break
+		}
+	}
+}
+
+func (st *state) addMember(guildID string, m *dto.Member) {
+	m.GuildID = guildID
+	if m.JoinedAt == "" {
+		m.JoinedAt = now()
+	}
+	for i, old := range st.members[guildID] {
+		if old.User != nil && m.User != nil && old.User.ID == m.User.ID {
+			st.members[guildID][i] = m
+			return
+		}
+	}
+	st.members[guildID] = append(st.members[guildID], m)
+}
+
+func (st *state) member(guildID, userID string) (int, *dto.Member) {
+	for i, m := range st.members[guildID] {
+		if m.User != nil && m.User.ID == userID {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+func (st *state) role(guildID string, roleID dto.RoleID) (int, *dto.Role) {
+	for i, r := range st.roles[guildID] {
+		if r.ID == roleID {
+			return i, r
+		}
+	}
+	return -1, nil
+}
+
+func (st *state) message(channelID, messageID string) (int, *dto.Message) {
+	for i, m := range st.messages[channelID] {
+		if m.ID == messageID {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+func (st *state) schedule(channelID, scheduleID string) (int, *dto.Schedule) {
+	for i, sc := range st.sched[channelID] {
+		if sc.ID == scheduleID {
+			return i, sc
+		}
+	}
+	return -1, nil
+}
+
+// postMessage 保存一条频道或者私信消息，并返回平台视角的消息对象
+func (st *state) postMessage(channelID, guildID string, msg *dto.MessageToCreate, direct bool) *dto.Message {
+	bot := st.bot
+	m := &dto.Message{
+		ID:               st.nextID("msg-"),
+		ChannelID:        channelID,
+		GuildID:          guildID,
+		Content:          msg.Content,
+		Timestamp:        now(),
+		Author:           &bot,
+		Ark:              msg.Ark,
+		DirectMessage:    direct,
+		MessageReference: msg.MessageReference,
+	}
+	if msg.Embed != nil {
+		m.Embeds = []*dto.Embed{msg.Embed}
+	}
+	if msg.Image != "" {
+		m.Attachments = []*dto.MessageAttachment{{URL: msg.Image}}
+	}
+	m.SeqInChannel = fmt.Sprintf("%d", len(st.messages[channelID])+1)
+	st.messages[channelID] = append(st.messages[channelID], m)
+	return m
+}
@@ -4,7 +4,9 @@ package options
 // Options are openapi options
 type Options struct {
 	URL     string
-	HideTip bool // 撤回消息隐藏小灰条可选参数, true: 隐藏小灰条
+	HideTip bool         // 撤回消息隐藏小灰条可选参数, true: 隐藏小灰条
+	Retry   *RetryPolicy // 重试策略，为空时使用 DefaultRetryPolicy
+	NoCache bool         // 上传富媒体文件时不使用缓存，强制重新上传
 }
 
 // Option sets client options.
@@ -23,3 +25,10 @@ func WithHideTip() Option {
 		o.HideTip = true
 	}
 }
+
+// WithoutCache 上传富媒体文件时不使用缓存
+func WithoutCache() Option {
+	return func(o *Options) {
+		o.NoCache = true
+	}
+}
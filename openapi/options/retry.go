@@ -0,0 +1,52 @@
+package options
+
+import (
+	"time"
+)
+
+// RetryPolicy 接口请求的重试策略
+//
+// 只有网络错误以及 5xx 返回会被重试，并且只对幂等的请求生效：
+// GET、HEAD、OPTIONS、PUT、DELETE 请求，以及携带了 msg_id 或者 event_id 的消息发送请求。
+// 消息发送请求重试时会原样重发请求体，服务端会根据 msg_id/event_id 与 msg_seq 进行去重。
+type RetryPolicy struct {
+	MaxRetries int           // 最大重试次数，0 表示不重试，超过 MaxRetriesLimit 时按照 MaxRetriesLimit 重试
+	Backoff    time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff time.Duration // 单次等待时间的上限，0 表示不限制
+}
+
+// MaxRetriesLimit 单个请求最多的重试次数，包含 429 限频的重试
+const MaxRetriesLimit = 10
+
+// DefaultRetryPolicy 没有通过 WithRetry 指定时使用的全局默认策略，设置为 nil 表示默认不重试
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxRetries: 2,
+	Backoff:    200 * time.Millisecond,
+	MaxBackoff: 2 * time.Second,
+}
+
+// Wait 计算第 attempt 次请求失败后，重试前需要等待的时间
+func (p *RetryPolicy) Wait(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := p.Backoff << uint(attempt-1)
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d <= 0) {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// WithRetry 指定本次请求的重试策略
+func WithRetry(p RetryPolicy) Option {
+	return func(o *Options) {
+		o.Retry = &p
+	}
+}
+
+// WithoutRetry 本次请求不进行重试
+func WithoutRetry() Option {
+	return func(o *Options) {
+		o.Retry = &RetryPolicy{}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// bucket 令牌桶，所有访问都需要持有 Limiter.mu
+type bucket struct {
+	rate         float64 // 每秒补充的令牌数
+	burst        float64 // 桶容量
+	tokens       float64
+	last         time.Time // 上一次补充令牌的时间
+	blockedUntil time.Time // 服务端要求暂停请求的截止时间
+	stat         Stat      // 统计数据，随令牌桶一起回收
+}
+
+func newBucket(rule Rule, now time.Time) *bucket {
+	burst := float64(rule.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: rule.Rate, burst: burst, tokens: burst, last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// reserve 预占一个令牌，返回需要等待的时间
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 {
+		if b.rate <= 0 {
+			wait = time.Second
+		} else {
+			wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+	if blocked := b.blockedUntil.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	return wait
+}
+
+// cancel 归还预占的令牌
+func (b *bucket) cancel() {
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// block 在 until 之前不再放行请求，同时根据服务端返回的剩余次数校准令牌数
+func (b *bucket) block(now, until time.Time, remaining int) {
+	b.refill(now)
+	if remaining >= 0 && float64(remaining) < b.tokens {
+		b.tokens = float64(remaining)
+	}
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// idle 桶已经补满并且没有被阻塞，可以安全的回收
+func (b *bucket) idle(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst && !now.Before(b.blockedUntil)
+}
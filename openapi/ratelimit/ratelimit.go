@@ -0,0 +1,369 @@
+// Package ratelimit 基于令牌桶的 openapi 请求频率控制。
+//
+// Limiter 按照机器人与路由维度维护令牌桶，请求发出前在请求过滤器中排队等待令牌，
+// 返回之后在返回过滤器中根据限频相关的返回头以及 429 状态码校准令牌桶。
+// 通过 Install 安装之后，openapi v1 会自动对 429 的请求进行退避重试。
+//
+//	ratelimit.Install(ratelimit.New(ratelimit.WithRule(http.MethodPost, "/channels/{channel_id}/messages",
+//		ratelimit.Rule{Rate: 5, Burst: 5, PerResource: true})))
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/errs"
+	"github.com/tencent-connect/botgo/openapi"
+)
+
+// FilterName 注册到 openapi 过滤器链上的名称
+const FilterName = "ratelimit"
+
+// 默认的限频相关返回头
+const (
+	DefaultRemainingHeader = "X-RateLimit-Remaining"   // 当前窗口剩余的请求次数
+	DefaultResetHeader     = "X-RateLimit-Reset-After" // 距离窗口重置的秒数，支持小数
+	retryAfterHeader       = "Retry-After"
+)
+
+// maxBuckets 令牌桶数量超过该值时，回收空闲的令牌桶
+const maxBuckets = 4096
+
+// Rule 限频规则
+type Rule struct {
+	Rate        float64 // 每秒补充的令牌数
+	Burst       int     // 令牌桶容量，即允许的突发请求数
+	PerResource bool    // 是否按照路由中的第一个资源 id（比如 channel_id）分别限频
+}
+
+// DefaultRule 没有命中任何规则的路由使用的默认规则
+var DefaultRule = Rule{Rate: 20, Burst: 20}
+
+// DefaultRules 主动消息相关接口的默认规则，取值较为保守，可以通过 WithRule 覆盖
+var DefaultRules = map[string]Rule{
+	"POST /channels/{channel_id}/messages": {Rate: 5, Burst: 5, PerResource: true},
+	"POST /dms/{guild_id}/messages":        {Rate: 5, Burst: 5, PerResource: true},
+	"POST /v2/groups/{group_id}/messages":  {Rate: 5, Burst: 5, PerResource: true},
+	"POST /v2/users/{user_id}/messages":    {Rate: 5, Burst: 5, PerResource: true},
+}
+
+// Stat 单个令牌桶的统计数据
+type Stat struct {
+	Requests  int64         // 经过限频器的请求数
+	Delayed   int64         // 需要排队等待的请求数
+	Throttled int64         // 服务端返回 429 的次数
+	Waited    time.Duration // 累计排队时间
+}
+
+// Option 限频器配置项
+type Option func(l *Limiter)
+
+// WithRule 为指定的方法与路由模板设置规则，路由模板与 openapi/v1 中的 uri 定义保持一致
+func WithRule(method, route string, rule Rule) Option {
+	return func(l *Limiter) {
+		l.rules[ruleKey(method, route)] = rule
+	}
+}
+
+// WithDefaultRule 设置默认规则
+func WithDefaultRule(rule Rule) Option {
+	return func(l *Limiter) {
+		l.defaultRule = rule
+	}
+}
+
+// WithHeaders 设置服务端返回的剩余次数与重置时间的返回头，传空字符串表示不解析
+func WithHeaders(remaining, reset string) Option {
+	return func(l *Limiter) {
+		l.remainingHeader = remaining
+		l.resetHeader = reset
+	}
+}
+
+// WithMaxWait 设置单个请求最长的排队时间，超过时直接返回 errs.ErrRateLimitWaitTooLong，0 表示不限制
+func WithMaxWait(d time.Duration) Option {
+	return func(l *Limiter) {
+		l.maxWait = d
+	}
+}
+
+// WithRetry 设置 429 的最大重试次数，以及没有 Retry-After 返回头时的基础退避时间
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(l *Limiter) {
+		l.maxRetries = maxRetries
+		l.backoff = backoff
+	}
+}
+
+// Limiter 按照机器人与路由维度限频的调度器
+type Limiter struct {
+	defaultRule     Rule
+	rules           map[string]Rule
+	remainingHeader string
+	resetHeader     string
+	maxWait         time.Duration
+	maxRetries      int
+	backoff         time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New 创建限频器，默认包含 DefaultRules 中的规则
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		defaultRule:     DefaultRule,
+		rules:           map[string]Rule{},
+		remainingHeader: DefaultRemainingHeader,
+		resetHeader:     DefaultResetHeader,
+		maxRetries:      3,
+		backoff:         500 * time.Millisecond,
+		buckets:         map[string]*bucket{},
+	}
+	for k, r := range DefaultRules {
+		l.rules[k] = r
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Wait 等待请求对应的令牌桶放行，等待过程中 req 的 context 被取消时返回 context 的错误
+func (l *Limiter) Wait(req *http.Request) error {
+	key, rule := l.match(req)
+	now := time.Now()
+	l.mu.Lock()
+	b := l.bucket(key, rule, now)
+	wait := b.reserve(now)
+	b.stat.Requests++
+	if wait > 0 && (l.maxWait <= 0 || wait <= l.maxWait) {
+		b.stat.Delayed++
+		b.stat.Waited += wait
+	}
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if l.maxWait > 0 && wait > l.maxWait {
+		l.cancel(b)
+		return errs.ErrRateLimitWaitTooLong
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		l.cancel(b)
+		return req.Context().Err()
+	}
+}
+
+// Update 根据服务端的返回校准令牌桶
+func (l *Limiter) Update(req *http.Request, resp *http.Response) {
+	if req == nil || resp == nil {
+		return
+	}
+	now := time.Now()
+	remaining := headerInt(resp.Header, l.remainingHeader)
+	var until time.Time
+	if remaining == 0 {
+		if d, ok := headerSeconds(resp.Header, l.resetHeader); ok {
+			until = now.Add(d)
+		}
+	}
+	throttled := resp.StatusCode == http.StatusTooManyRequests
+	if throttled {
+		remaining = 0
+		if d, ok := headerSeconds(resp.Header, retryAfterHeader); ok {
+			until = now.Add(d)
+		}
+	}
+	if remaining < 0 && until.IsZero() {
+		return
+	}
+	key, rule := l.match(req)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(key, rule, now)
+	b.block(now, until, remaining)
+	if throttled {
+		b.stat.Throttled++
+	}
+}
+
+// Retry 判断响应是否为需要重试的 429，attempt 为已经发起的请求次数，返回重试前需要等待的时间
+func (l *Limiter) Retry(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests || attempt > l.maxRetries {
+		return 0, false
+	}
+	if d, ok := headerSeconds(resp.Header, retryAfterHeader); ok {
+		return d, true
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	return l.backoff << uint(attempt-1), true
+}
+
+// Stats 返回各个令牌桶的统计数据，key 的格式为 "appid METHOD route [resource]"
+// 空闲的令牌桶被回收时，统计数据也一起删除
+func (l *Limiter) Stats() map[string]Stat {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]Stat, len(l.buckets))
+	for k, b := range l.buckets {
+		stats[k] = b.stat
+	}
+	return stats
+}
+
+func (l *Limiter) cancel(b *bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b.cancel()
+}
+
+// match 计算请求对应的令牌桶与规则
+func (l *Limiter) match(req *http.Request) (string, Rule) {
+	route := RouteFrom(req.Context())
+	if route == "" {
+		route = req.URL.Path
+	}
+	rule, ok := l.rules[ruleKey(req.Method, route)]
+	if !ok {
+		rule = l.defaultRule
+	}
+	key := fmt.Sprintf("%s %s %s", req.Header.Get("X-Union-Appid"), req.Method, route)
+	if rule.PerResource {
+		if resource := resourceID(route, req.URL.Path); resource != "" {
+			key += " " + resource
+		}
+	}
+	return key, rule
+}
+
+func (l *Limiter) bucket(key string, rule Rule, now time.Time) *bucket {
+	if b, ok := l.buckets[key]; ok {
+		return b
+	}
+	if len(l.buckets) >= maxBuckets {
+		for k, b := range l.buckets {
+			if b.idle(now) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b := newBucket(rule, now)
+	l.buckets[key] = b
+	return b
+}
+
+func ruleKey(method, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
+
+// resourceID 取路由模板中第一个参数在实际路径中的取值
+func resourceID(route, path string) string {
+	rs := strings.Split(strings.Trim(route, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	if len(rs) != len(ps) {
+		return ""
+	}
+	for i, seg := range rs {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			return ps[i]
+		}
+	}
+	return ""
+}
+
+func headerInt(h http.Header, name string) int {
+	if name == "" {
+		return -1
+	}
+	v, err := strconv.Atoi(h.Get(name))
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+func headerSeconds(h http.Header, name string) (time.Duration, bool) {
+	if name == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(h.Get(name), 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return time.Duration(v * float64(time.Second)), true
+}
+
+type routeKey struct{}
+
+// WithRoute 将请求的路由模板保存到 context 中，rawURL 为填充路径参数之前的地址，比如 https://api.sgroup.qq.com/channels/{channel_id}
+func WithRoute(ctx context.Context, rawURL string) context.Context {
+	route := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		route = u.Path
+	}
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteFrom 从 context 中获取路由模板
+func RouteFrom(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+var (
+	installLock sync.RWMutex
+	installed   *Limiter
+)
+
+// Install 安装限频器，并注册请求与返回过滤器，传入 nil 表示关闭限频
+func Install(l *Limiter) {
+	installLock.Lock()
+	installed = l
+	installLock.Unlock()
+	openapi.RegisterReqFilter(FilterName, reqFilter)
+	openapi.RegisterRespFilter(FilterName, respFilter)
+}
+
+// Installed 返回当前安装的限频器
+func Installed() *Limiter {
+	installLock.RLock()
+	defer installLock.RUnlock()
+	return installed
+}
+
+// Retry 使用当前安装的限频器判断是否需要重试，没有安装限频器时不重试
+func Retry(resp *http.Response, attempt int) (time.Duration, bool) {
+	l := Installed()
+	if l == nil {
+		return 0, false
+	}
+	return l.Retry(resp, attempt)
+}
+
+func reqFilter(req *http.Request, _ *http.Response) error {
+	if l := Installed(); l != nil {
+		return l.Wait(req)
+	}
+	return nil
+}
+
+func respFilter(req *http.Request, resp *http.Response) error {
+	if l := Installed(); l != nil {
+		l.Update(req, resp)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/errs"
+	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/openapitest"
+	"github.com/tencent-connect/botgo/openapi/ratelimit"
+	v1 "github.com/tencent-connect/botgo/openapi/v1"
+)
+
+func newAPI(t *testing.T, l *ratelimit.Limiter) (*openapitest.Server, openapi.OpenAPI) {
+	srv := openapitest.NewServer()
+	restore := srv.Install()
+	ratelimit.Install(l)
+	t.Cleanup(func() {
+		ratelimit.Install(nil)
+		restore()
+		srv.Close()
+	})
+	v1.Setup()
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake", TokenType: "QQBot"})
+	return srv, openapi.VersionMapping[openapi.APIv1].Setup("appid", tokenSource, false)
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := ratelimit.New(ratelimit.WithRule(http.MethodPost, "/channels/{channel_id}/messages",
+		ratelimit.Rule{Rate: 20, Burst: 1, PerResource: true}))
+	srv, api := newAPI(t, l)
+	srv.AddChannel(&dto.Channel{ID: "c1"})
+	srv.AddChannel(&dto.Channel{ID: "c2"})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := api.PostMessage(ctx, "c1", &dto.MessageToCreate{Content: "hi"})
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+
+	// 不同子频道使用独立的令牌桶
+	_, err := api.PostMessage(ctx, "c2", &dto.MessageToCreate{Content: "hi"})
+	require.NoError(t, err)
+	stats := l.Stats()
+	assert.Equal(t, ratelimit.Stat{Requests: 1}, stats["appid POST /channels/{channel_id}/messages c2"])
+	c1 := stats["appid POST /channels/{channel_id}/messages c1"]
+	assert.Equal(t, int64(3), c1.Requests)
+	assert.Equal(t, int64(2), c1.Delayed)
+}
+
+func TestLimiter_MaxWait(t *testing.T) {
+	l := ratelimit.New(
+		ratelimit.WithDefaultRule(ratelimit.Rule{Rate: 1, Burst: 1}),
+		ratelimit.WithMaxWait(10*time.Millisecond),
+	)
+	_, api := newAPI(t, l)
+	ctx := context.Background()
+
+	_, err := api.Me(ctx)
+	require.NoError(t, err)
+	_, err = api.Me(ctx)
+	assert.Equal(t, errs.ErrRateLimitWaitTooLong, err)
+}
+
+func TestLimiter_Retry429(t *testing.T) {
+	l := ratelimit.New(ratelimit.WithRetry(2, 10*time.Millisecond))
+	srv, api := newAPI(t, l)
+	ctx := context.Background()
+	fault := openapitest.Fault{
+		Status:  http.StatusTooManyRequests,
+		Code:    http.StatusTooManyRequests,
+		Message: "too many requests",
+		Header:  http.Header{"Retry-After": []string{"0.05"}},
+		Times:   2,
+	}
+	srv.InjectFault(http.MethodPost, "/v2/users/{user_id}/messages", fault)
+
+	start := time.Now()
+	_, err := api.PostC2CMessage(ctx, "user", &dto.MessageToCreate{Content: "hi"})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	assert.Len(t, srv.Requests(), 3)
+	assert.Equal(t, int64(2), l.Stats()["appid POST /v2/users/{user_id}/messages user"].Throttled)
+
+	// 超过重试次数之后，返回原始的 429 错误
+	fault.Times = 3
+	srv.InjectFault(http.MethodPost, "/v2/users/{user_id}/messages", fault)
+	_, err = api.PostC2CMessage(ctx, "user", &dto.MessageToCreate{Content: "hi"})
+	assert.Equal(t, http.StatusTooManyRequests, errs.Error(err).Code())
+}
+
+func TestLimiter_Headers(t *testing.T) {
+	l := ratelimit.New()
+	req, _ := http.NewRequest(http.MethodGet, "https://api.sgroup.qq.com/users/@me", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	resp.Header.Set(ratelimit.DefaultRemainingHeader, "0")
+	resp.Header.Set(ratelimit.DefaultResetHeader, "0.05")
+	l.Update(req, resp)
+
+	start := time.Now()
+	require.NoError(t, l.Wait(req))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(40*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.Update(req, resp)
+	assert.Equal(t, context.Canceled, l.Wait(req.WithContext(ctx)))
+}
+
+func TestLimiter_Evict(t *testing.T) {
+	l := ratelimit.New()
+	// 令牌桶数量达到上限 4096 之后，创建新的令牌桶时回收空闲的令牌桶
+	for i := 0; i < 4096; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.sgroup.qq.com/users/"+strconv.Itoa(i), nil)
+		require.NoError(t, l.Wait(req))
+	}
+	// 统计数据随令牌桶一起回收，不会无限增长
+	time.Sleep(100 * time.Millisecond)
+	req, _ := http.NewRequest(http.MethodGet, "https://api.sgroup.qq.com/users/@me", nil)
+	require.NoError(t, l.Wait(req))
+	stats := l.Stats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, ratelimit.Stat{Requests: 1}, stats[" GET /users/@me"])
+}
@@ -0,0 +1,262 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/openapi/options"
+)
+
+// 流式消息默认的发送策略
+const (
+	DefaultStreamFlushInterval = 500 * time.Millisecond
+	DefaultStreamFlushSize     = 200 // 字节
+)
+
+// ErrStreamClosed 流式消息已经结束
+var ErrStreamClosed = errors.New("stream writer closed")
+
+// StreamOption 流式消息的配置项
+type StreamOption func(w *StreamWriter)
+
+// WithStreamFlushInterval 设置定时发送的间隔，小于等于 0 表示不定时发送
+func WithStreamFlushInterval(d time.Duration) StreamOption {
+	return func(w *StreamWriter) {
+		w.interval = d
+	}
+}
+
+// WithStreamFlushSize 设置缓冲区达到多少字节后立即发送
+func WithStreamFlushSize(size int) StreamOption {
+	return func(w *StreamWriter) {
+		w.size = size
+	}
+}
+
+// WithStreamMsgSeq 设置回复消息的序号，同一条消息回复多次时需要使用不同的序号
+func WithStreamMsgSeq(seq uint32) StreamOption {
+	return func(w *StreamWriter) {
+		w.msgSeq = seq
+	}
+}
+
+// WithStreamOptions 设置发送每个分片时使用的 openapi options
+func WithStreamOptions(opt ...options.Option) StreamOption {
+	return func(w *StreamWriter) {
+		w.opts = opt
+	}
+}
+
+// StreamWriter 以 markdown 流式消息的形式回复群聊或单聊消息，实现了 io.Writer
+//
+// 写入的内容会先缓冲，达到 flush size 或者 flush interval 时作为一个分片发送，每个分片只包含新增的内容。
+// 第一个分片返回的消息 id 会作为后续分片的流式消息 id，Close 时发送结束状态。
+//
+//	w := openapi.NewC2CStreamWriter(ctx, api, userID, msgID)
+//	for token := range tokens {
+//		_, _ = io.WriteString(w, token)
+//	}
+//	err := w.Close()
+type StreamWriter struct {
+	ctx    context.Context
+	post   func(ctx context.Context, msg *dto.MessageToCreate) (*dto.Message, error)
+	msgID  string
+	msgSeq uint32
+	opts   []options.Option
+
+	interval time.Duration
+	size     int
+
+	mu     sync.Mutex
+	buf    []byte
+	id     string // 流式消息 id，第一个分片发送成功后获得
+	index  int32
+	reset  bool  // 等待发送的 reset 分片，发送失败时在下一次发送时重试
+	err    error // 后台定时发送时出现的错误，会在下一次调用时返回
+	closed bool
+	done   chan struct{}
+}
+
+// NewC2CStreamWriter 创建回复单聊消息的流式消息，msgID 为要回复的消息 id
+func NewC2CStreamWriter(ctx context.Context, api MessageAPI, userID, msgID string, opt ...StreamOption) *StreamWriter {
+	w := &StreamWriter{msgID: msgID}
+	w.post = func(ctx context.Context, msg *dto.MessageToCreate) (*dto.Message, error) {
+		return api.PostC2CMessage(ctx, userID, msg, w.opts...)
+	}
+	return w.start(ctx, opt)
+}
+
+// NewGroupStreamWriter 创建回复群消息的流式消息，msgID 为要回复的消息 id
+func NewGroupStreamWriter(ctx context.Context, api MessageAPI, groupID, msgID string,
+	opt ...StreamOption) *StreamWriter {
+	w := &StreamWriter{msgID: msgID}
+	w.post = func(ctx context.Context, msg *dto.MessageToCreate) (*dto.Message, error) {
+		return api.PostGroupMessage(ctx, groupID, msg, w.opts...)
+	}
+	return w.start(ctx, opt)
+}
+
+func (w *StreamWriter) start(ctx context.Context, opt []StreamOption) *StreamWriter {
+	w.ctx = ctx
+	w.interval = DefaultStreamFlushInterval
+	w.size = DefaultStreamFlushSize
+	w.msgSeq = 1
+	w.done = make(chan struct{})
+	for _, o := range opt {
+		o(w)
+	}
+	if w.interval > 0 {
+		go w.tick()
+	}
+	return w
+}
+
+// ID 返回流式消息 id，第一个分片发送之前为空
+func (w *StreamWriter) ID() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.id
+}
+
+// Write 写入内容，缓冲区达到 flush size 时立即发送
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.check(); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf, p...)
+	if w.size > 0 && len(w.buf) >= w.size {
+		if err := w.send(dto.StreamStateGenerating); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 立即发送缓冲区中的内容
+func (w *StreamWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.send(dto.StreamStateGenerating)
+}
+
+// Reset 丢弃已经发送的内容，重新生成，content 为重新生成后的第一段内容
+func (w *StreamWriter) Reset(content string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.check(); err != nil {
+		return err
+	}
+	w.buf = append(w.buf[:0], content...)
+	if w.id == "" {
+		// 还没有发送过分片，不需要通知服务端
+		return nil
+	}
+	w.reset = true
+	return w.send(dto.StreamStateGenerating)
+}
+
+// Close 发送剩余的内容以及结束状态
+func (w *StreamWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	close(w.done)
+	pending := w.err
+	w.err = w.send(dto.StreamStateDone)
+	if pending != nil {
+		w.err = pending
+	}
+	return w.err
+}
+
+// check 返回后台发送时出现的错误，错误只返回一次，未发送成功的内容仍在缓冲区中
+func (w *StreamWriter) check() error {
+	if w.closed {
+		return ErrStreamClosed
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+func (w *StreamWriter) tick() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed && w.err == nil {
+				w.err = w.send(dto.StreamStateGenerating)
+			}
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// send 发送一个分片，需要持有 w.mu；进行中的分片只会发送完整的 utf8 字符
+// 发送成功之后才更新序号，失败时序号与 reset 状态保持不变，可以重试
+func (w *StreamWriter) send(state int32) error {
+	n := len(w.buf)
+	if state == dto.StreamStateGenerating {
+		n = completeUTF8(w.buf)
+		if n == 0 && !w.reset {
+			return nil
+		}
+	}
+	index := w.index + 1
+	if w.reset {
+		index = 0 // reset 分片的序号从 0 开始
+	}
+	msg := &dto.MessageToCreate{
+		MsgType:  dto.MarkdownMsg,
+		Markdown: &dto.Markdown{Content: string(w.buf[:n])},
+		MsgID:    w.msgID,
+		MsgSeq:   w.msgSeq,
+		Stream: &dto.Stream{
+			State: state,
+			ID:    w.id,
+			Index: index,
+			Reset: w.reset,
+		},
+	}
+	resp, err := w.post(w.ctx, msg)
+	if err != nil {
+		return err
+	}
+	w.index, w.reset = index, false
+	if w.id == "" {
+		w.id = resp.ID
+	}
+	w.buf = append(w.buf[:0], w.buf[n:]...)
+	return nil
+}
+
+// completeUTF8 返回 b 中完整的 utf8 字符的长度，末尾不完整的字符留到下一次发送
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
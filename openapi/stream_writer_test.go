@@ -0,0 +1,118 @@
+package openapi_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/openapitest"
+	"github.com/tencent-connect/botgo/openapi/options"
+	v1 "github.com/tencent-connect/botgo/openapi/v1"
+)
+
+func newTestAPI(t *testing.T) (*openapitest.Server, openapi.OpenAPI) {
+	srv := openapitest.NewServer()
+	restore := srv.Install()
+	t.Cleanup(func() {
+		restore()
+		srv.Close()
+	})
+	v1.Setup()
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake", TokenType: "QQBot"})
+	return srv, openapi.DefaultImpl.Setup("appid", tokenSource, false)
+}
+
+type chunk struct {
+	content string
+	state   int32
+	index   int32
+	reset   bool
+}
+
+func chunks(msgs []*dto.MessageToCreate) []chunk {
+	var c []chunk
+	for _, m := range msgs {
+		c = append(c, chunk{m.Markdown.Content, m.Stream.State, m.Stream.Index, m.Stream.Reset})
+	}
+	return c
+}
+
+func TestStreamWriter(t *testing.T) {
+	srv, api := newTestAPI(t)
+	w := openapi.NewC2CStreamWriter(context.Background(), api, "user1", "msg1",
+		openapi.WithStreamFlushInterval(0), openapi.WithStreamFlushSize(6))
+
+	_, err := io.WriteString(w, "你好") // 6 字节，立即发送
+	require.NoError(t, err)
+	_, err = w.Write([]byte("世界"[:4])) // 不完整的 utf8 字符留在缓冲区
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	id := w.ID()
+	assert.NotEmpty(t, id)
+	_, err = w.Write([]byte("世界"[4:]))
+	require.NoError(t, err)
+	require.NoError(t, w.Reset("重来"))
+	_, err = io.WriteString(w, "!")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = io.WriteString(w, "late")
+	assert.Equal(t, openapi.ErrStreamClosed, err)
+
+	msgs := srv.C2CMessages("user1")
+	assert.Equal(t, []chunk{
+		{"你好", dto.StreamStateGenerating, 1, false},
+		{"世", dto.StreamStateGenerating, 2, false},
+		{"重来", dto.StreamStateGenerating, 0, true},
+		{"!", dto.StreamStateDone, 1, false},
+	}, chunks(msgs))
+	// reset 分片的序号 0 需要出现在请求中
+	assert.Contains(t, string(srv.Requests()[2].Body), `"index":0`)
+	assert.Equal(t, "", msgs[0].Stream.ID)
+	for _, m := range msgs[1:] {
+		assert.Equal(t, id, m.Stream.ID)
+		assert.Equal(t, "msg1", m.MsgID)
+	}
+}
+
+func TestStreamWriter_Error(t *testing.T) {
+	srv, api := newTestAPI(t)
+	srv.InjectFault(http.MethodPost, "/v2/groups/{group_id}/messages",
+		openapitest.Fault{Status: http.StatusBadRequest, Code: 40034, Message: "bad stream", Times: 1})
+	w := openapi.NewGroupStreamWriter(context.Background(), api, "group1", "msg1",
+		openapi.WithStreamFlushInterval(0), openapi.WithStreamOptions(options.WithoutRetry()))
+
+	_, err := io.WriteString(w, "hello")
+	require.NoError(t, err)
+	assert.Error(t, w.Flush())
+	// 发送失败的内容保留在缓冲区，可以重试
+	require.NoError(t, w.Close())
+	assert.Equal(t, []chunk{{"hello", dto.StreamStateDone, 1, false}}, chunks(srv.GroupMessages("group1")))
+}
+
+func TestStreamWriter_ResetError(t *testing.T) {
+	srv, api := newTestAPI(t)
+	w := openapi.NewC2CStreamWriter(context.Background(), api, "user1", "msg1",
+		openapi.WithStreamFlushInterval(0), openapi.WithStreamOptions(options.WithoutRetry()))
+	_, err := io.WriteString(w, "a")
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	// reset 发送失败时序号不变，下一次发送时重试 reset
+	srv.InjectFault(http.MethodPost, "/v2/users/{user_id}/messages",
+		openapitest.Fault{Status: http.StatusBadRequest, Code: 40034, Message: "bad stream", Times: 1})
+	assert.Error(t, w.Reset("b"))
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Close())
+	assert.Equal(t, []chunk{
+		{"a", dto.StreamStateGenerating, 1, false},
+		{"b", dto.StreamStateGenerating, 0, true},
+		{"", dto.StreamStateDone, 1, false},
+	}, chunks(srv.C2CMessages("user1")))
+}
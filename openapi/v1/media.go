@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/options"
+)
+
+var _ openapi.MediaAPI = (*openAPI)(nil)
+
+// mediaCacheMargin 缓存比服务端的有效期提前过期，避免发送时 file_info 刚好失效
+const mediaCacheMargin = time.Minute
+
+// mediaCacheCapacity 最多缓存的富媒体文件数量
+const mediaCacheCapacity = 1024
+
+// UploadGroupMedia 上传群富媒体文件
+func (o *openAPI) UploadGroupMedia(ctx context.Context, groupID string, media *dto.MediaToUpload,
+	opt ...options.Option) (*dto.MediaInfo, error) {
+	return o.uploadMedia(ctx, groupRichMediaURI, "group_id", groupID, media, opt...)
+}
+
+// UploadC2CMedia 上传单聊富媒体文件
+func (o *openAPI) UploadC2CMedia(ctx context.Context, userID string, media *dto.MediaToUpload,
+	opt ...options.Option) (*dto.MediaInfo, error) {
+	return o.uploadMedia(ctx, c2cRichMediaURI, "user_id", userID, media, opt...)
+}
+
+// uploadMedia 上传富媒体文件，群与单聊上传的文件分别可以在同类场景中复用，所以按场景、类型与内容缓存
+func (o *openAPI) uploadMedia(ctx context.Context, u uri, param, id string, media *dto.MediaToUpload,
+	opt ...options.Option) (*dto.MediaInfo, error) {
+	if media == nil || (media.URL == "") == (len(media.Data) == 0) {
+		return nil, errors.New("exactly one of media url and data is required")
+	}
+	var key string
+	if len(media.Data) > 0 && !getOptions(ctx, opt...).NoCache {
+		key = mediaCacheKey(u, media)
+		if info := o.mediaCache.get(key); info != nil {
+			return info, nil
+		}
+	}
+	reqCMD := o.request(ctx).
+		SetResult(dto.MediaInfo{}).
+		SetPathParam(param, id).
+		SetBody(&dto.RichMediaMessage{FileType: media.FileType, URL: media.URL, FileData: media.Data})
+
+	resp, err := baseRequest(ctx, reqCMD, http.MethodPost, o.getURL(u), opt...)
+	if err != nil {
+		return nil, err
+	}
+	info := resp.Result().(*dto.MediaInfo)
+	if key != "" {
+		o.mediaCache.set(key, info)
+	}
+	return info, nil
+}
+
+func mediaCacheKey(u uri, media *dto.MediaToUpload) string {
+	sum := sha256.Sum256(media.Data)
+	return string(u) + " " + strconv.FormatUint(media.FileType, 10) + " " + hex.EncodeToString(sum[:])
+}
+
+type mediaCacheItem struct {
+	key      string
+	info     *dto.MediaInfo
+	expireAt time.Time // 为零值时长期有效
+}
+
+// mediaCache 已上传的富媒体文件，在有效期内复用 file_info
+// 超过容量时淘汰最久未使用的文件，过期的文件在读取时删除
+type mediaCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List // 按使用时间排序，front 为最近使用
+	items    map[string]*list.Element
+}
+
+func newMediaCache(capacity int) *mediaCache {
+	return &mediaCache{capacity: capacity, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *mediaCache) get(key string) *dto.MediaInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	item := e.Value.(*mediaCacheItem)
+	if !item.expireAt.IsZero() && time.Now().After(item.expireAt) {
+		c.remove(e)
+		return nil
+	}
+	c.ll.MoveToFront(e)
+	info := *item.info
+	return &info
+}
+
+func (c *mediaCache) set(key string, info *dto.MediaInfo) {
+	item := &mediaCacheItem{key: key, info: info}
+	if info.TTL > 0 {
+		ttl := time.Duration(info.TTL) * time.Second
+		if ttl <= mediaCacheMargin {
+			return
+		}
+		item.expireAt = time.Now().Add(ttl - mediaCacheMargin)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	c.items[key] = c.ll.PushFront(item)
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *mediaCache) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*mediaCacheItem).key)
+}
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/options"
+)
+
+func TestUploadMedia(t *testing.T) {
+	srv, base := newTestAPI(t)
+	api, ok := base.(openapi.MediaAPI)
+	require.True(t, ok)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "a.png")
+	require.NoError(t, os.WriteFile(path, []byte("png"), 0o600))
+	media, err := dto.MediaFromFile(dto.MediaFileTypeImage, path)
+	require.NoError(t, err)
+
+	info, err := api.UploadGroupMedia(ctx, "g1", media)
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.FileInfo)
+	assert.NotEmpty(t, info.FileUUID)
+	assert.Equal(t, uint(3600), info.TTL)
+	files := srv.Files("groups/g1")
+	require.Len(t, files, 1)
+	assert.Equal(t, []byte("png"), files[0].FileData)
+	assert.Equal(t, dto.MediaFileTypeImage, files[0].FileType)
+
+	// 同类场景中相同内容的文件直接使用缓存
+	cached, err := api.UploadGroupMedia(ctx, "g2", dto.MediaFromBytes(dto.MediaFileTypeImage, []byte("png")))
+	require.NoError(t, err)
+	assert.Equal(t, info, cached)
+	assert.Empty(t, srv.Files("groups/g2"))
+
+	// 单聊、不同类型以及 WithoutCache 都需要重新上传
+	_, err = api.UploadC2CMedia(ctx, "u1", media)
+	require.NoError(t, err)
+	_, err = api.UploadGroupMedia(ctx, "g1", dto.MediaFromBytes(dto.MediaFileTypeFile, []byte("png")))
+	require.NoError(t, err)
+	_, err = api.UploadGroupMedia(ctx, "g1", media, options.WithoutCache())
+	require.NoError(t, err)
+	assert.Len(t, srv.Files("users/u1"), 1)
+	assert.Len(t, srv.Files("groups/g1"), 3)
+
+	// url 不缓存
+	for i := 0; i < 2; i++ {
+		_, err = api.UploadC2CMedia(ctx, "u2", dto.MediaFromURL(dto.MediaFileTypeImage, "https://a/b.png"))
+		require.NoError(t, err)
+	}
+	assert.Len(t, srv.Files("users/u2"), 2)
+
+	_, err = api.UploadC2CMedia(ctx, "u2", &dto.MediaToUpload{})
+	assert.Error(t, err)
+}
+
+func TestMediaCache(t *testing.T) {
+	c := newMediaCache(2)
+	c.set("a", &dto.MediaInfo{FileUUID: "a"})
+	c.set("b", &dto.MediaInfo{FileUUID: "b"})
+	require.NotNil(t, c.get("a"))
+	// 超过容量时淘汰最久未使用的文件
+	c.set("c", &dto.MediaInfo{FileUUID: "c"})
+	assert.Nil(t, c.get("b"))
+	assert.Equal(t, "a", c.get("a").FileUUID)
+	assert.Equal(t, "c", c.get("c").FileUUID)
+	assert.Equal(t, 2, c.ll.Len())
+}
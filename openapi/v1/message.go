@@ -202,6 +202,9 @@ func baseRequest(ctx context.Context, reqCMD *resty.Request, method, url string,
 	if opts.HideTip {
 		reqCMD = reqCMD.SetQueryParam("hidetip", "true")
 	}
+	if opts.Retry != nil {
+		reqCMD = withRetryPolicy(reqCMD, opts.Retry)
+	}
 
 	return reqCMD.Execute(method, url)
 }
@@ -14,6 +14,8 @@ import (
 	"github.com/tencent-connect/botgo/errs"
 	"github.com/tencent-connect/botgo/log"
 	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/options"
+	"github.com/tencent-connect/botgo/openapi/ratelimit"
 	"github.com/tencent-connect/botgo/version"
 	"golang.org/x/oauth2"
 )
@@ -21,6 +23,12 @@ import (
 // MaxIdleConns 默认指定空闲连接池大小
 const MaxIdleConns = 3000
 
+// 请求重试的上限，实际是否重试由 retryCondition 决定，参考 retry.go
+const (
+	maxRetryCount    = options.MaxRetriesLimit
+	maxRetryWaitTime = time.Minute
+)
+
 type openAPI struct {
 	appID       string
 	tokenSource oauth2.TokenSource
@@ -31,6 +39,7 @@ type openAPI struct {
 	lastTraceID string // lastTraceID id
 
 	restyClient *resty.Client // resty client 复用
+	mediaCache  *mediaCache   // 已上传的富媒体文件
 }
 
 // Setup 注册
@@ -55,6 +64,7 @@ func (o *openAPI) Setup(botAppID string, tokenSource oauth2.TokenSource, inSandb
 		tokenSource: tokenSource,
 		timeout:     5 * time.Second,
 		sandbox:     inSandbox,
+		mediaCache:  newMediaCache(mediaCacheCapacity),
 	}
 	api.setupClient(botAppID) // 初始化可复用的 client
 	return api
@@ -94,6 +104,14 @@ func (o *openAPI) setupClient(appID string) {
 				return openapi.DoReqFilterChains(request, nil)
 			},
 		).
+		OnBeforeRequest(
+			func(_ *resty.Client, r *resty.Request) error {
+				// 此时 url 中的路径参数还没有被替换，记录下路由模板，用于按路由限频
+				r.SetContext(ratelimit.WithRoute(r.Context(), r.URL))
+				return nil
+			},
+		).
+		OnBeforeRequest(resolveRetryPolicy).
 		OnBeforeRequest(
 			func(c *resty.Client, _ *resty.Request) error {
 				tk, err := o.tokenSource.Token()
@@ -119,12 +137,15 @@ func (o *openAPI) setupClient(appID string) {
 				o.lastTraceID = traceID
 				// 非成功含义的状态码，需要返回 error 供调用方识别
 				if !openapi.IsSuccessStatus(resp.StatusCode()) {
-					o.handleError(resp)
-					return errs.New(resp.StatusCode(), string(resp.Body()), traceID)
+					return o.handleError(resp, traceID)
 				}
 				return nil
 			},
-		)
+		).
+		SetRetryCount(maxRetryCount).
+		SetRetryMaxWaitTime(maxRetryWaitTime).
+		AddRetryCondition(retryCondition).
+		SetRetryAfter(retryAfter)
 }
 
 // request 每个请求，都需要创建一个 request
@@ -148,18 +169,27 @@ type errBody struct {
 	TraceID string `json:"trace_id"` // 服务端traceID, 用于问题排查
 }
 
-// handleError 处理openapi调用失败的情况
-func (o *openAPI) handleError(resp *resty.Response) {
+// handleError 处理openapi调用失败的情况，返回携带业务错误码的 errs.APIError
+func (o *openAPI) handleError(resp *resty.Response, traceID string) error {
+	apiErr := errs.NewAPIError(resp.StatusCode(), 0, "", traceID, string(resp.Body()))
 	var b errBody
-	err := json.Unmarshal(resp.Body(), &b)
-	if err != nil {
+	if err := json.Unmarshal(resp.Body(), &b); err != nil {
 		log.Errorf("parse errBody fail, err:%v, body:%s", err, string(resp.Body()))
-		return
+		return apiErr
+	}
+	apiErr.Code = b.ErrCode
+	if apiErr.Code == 0 {
+		apiErr.Code = b.Code
+	}
+	apiErr.Message = b.Message
+	if apiErr.TraceID == "" {
+		apiErr.TraceID = b.TraceID
 	}
 	if b.ErrCode == errs.APICodeTokenExpireOrNotExist || b.Code == errs.APICodeTokenExpireOrNotExist {
 		log.Errorf("token expire or not exist, update token")
 		_, _ = o.tokenSource.Token()
 	}
+	return apiErr
 }
 
 // respInfo 用于输出日志的时候格式化数据
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/openapi/options"
+	"github.com/tencent-connect/botgo/openapi/ratelimit"
+)
+
+type retryPolicyKey struct{}
+
+// withRetryPolicy 将本次请求指定的重试策略保存到请求的 context 中
+func withRetryPolicy(reqCMD *resty.Request, policy *options.RetryPolicy) *resty.Request {
+	return reqCMD.SetContext(context.WithValue(reqCMD.Context(), retryPolicyKey{}, policy))
+}
+
+// resolveRetryPolicy 在发送请求前确定重试策略，对所有接口生效
+// 没有指定时使用 DefaultRetryPolicy，不幂等的请求不重试，重试次数不超过 options.MaxRetriesLimit
+func resolveRetryPolicy(_ *resty.Client, r *resty.Request) error {
+	p, ok := r.Context().Value(retryPolicyKey{}).(*options.RetryPolicy)
+	if !ok {
+		p = options.DefaultRetryPolicy
+	}
+	if p != nil && (p.MaxRetries <= 0 || !idempotent(r.Method, r.Body)) {
+		p = nil
+	}
+	if p != nil && p.MaxRetries > options.MaxRetriesLimit {
+		clamped := *p
+		clamped.MaxRetries = options.MaxRetriesLimit
+		p = &clamped
+	}
+	r.SetContext(context.WithValue(r.Context(), retryPolicyKey{}, p))
+	return nil
+}
+
+func retryPolicyFrom(ctx context.Context) *options.RetryPolicy {
+	p, _ := ctx.Value(retryPolicyKey{}).(*options.RetryPolicy)
+	return p
+}
+
+// idempotent 判断请求重发是否安全，消息发送需要携带 msg_id 或者 event_id，由服务端进行去重
+func idempotent(method string, body interface{}) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		switch msg := body.(type) {
+		case *dto.MessageToCreate:
+			return msg.MsgID != "" || msg.EventID != ""
+		case *dto.RichMediaMessage:
+			// 只上传不发送的富媒体可以重复上传
+			return !msg.SrvSendMsg || msg.EventID != ""
+		}
+	}
+	return false
+}
+
+// retryCondition 判断请求是否需要重试，429 由限频器决定，网络错误与 5xx 由请求的重试策略决定
+func retryCondition(resp *resty.Response, err error) bool {
+	if resp == nil {
+		return false
+	}
+	if _, ok := ratelimit.Retry(resp.RawResponse, resp.Request.Attempt); ok {
+		return true
+	}
+	p := retryPolicyFrom(resp.Request.Context())
+	if p == nil || resp.Request.Attempt > p.MaxRetries {
+		return false
+	}
+	if resp.RawResponse == nil {
+		return err != nil
+	}
+	return resp.StatusCode() >= http.StatusInternalServerError
+}
+
+// retryAfter 计算重试前需要等待的时间
+func retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	if d, ok := ratelimit.Retry(resp.RawResponse, resp.Request.Attempt); ok {
+		return d, nil
+	}
+	if p := retryPolicyFrom(resp.Request.Context()); p != nil {
+		return p.Wait(resp.Request.Attempt), nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/errs"
+	"github.com/tencent-connect/botgo/openapi"
+	"github.com/tencent-connect/botgo/openapi/openapitest"
+	"github.com/tencent-connect/botgo/openapi/options"
+)
+
+func newTestAPI(t *testing.T) (*openapitest.Server, openapi.OpenAPI) {
+	srv := openapitest.NewServer()
+	restore := srv.Install()
+	t.Cleanup(func() {
+		restore()
+		srv.Close()
+	})
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake", TokenType: "QQBot"})
+	return srv, (&openAPI{}).Setup("appid", tokenSource, false)
+}
+
+func TestBaseRequest_Retry(t *testing.T) {
+	fastRetry := options.WithRetry(options.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+	serverError := openapitest.Fault{Status: http.StatusBadGateway, Code: 502, Message: "bad gateway"}
+	tests := []struct {
+		name     string
+		method   string
+		pattern  string
+		times    int
+		call     func(api openapi.OpenAPI) error
+		wantErr  bool
+		requests int
+	}{
+		{
+			name: "get retried", method: http.MethodGet, pattern: "/channels/{channel_id}/messages", times: 2,
+			call: func(api openapi.OpenAPI) error {
+				_, err := api.Messages(context.Background(), "c1", &dto.MessagesPager{}, fastRetry)
+				return err
+			},
+			requests: 3,
+		},
+		{
+			name: "retry exhausted", method: http.MethodGet, pattern: "/channels/{channel_id}/messages", times: 3,
+			call: func(api openapi.OpenAPI) error {
+				_, err := api.Messages(context.Background(), "c1", &dto.MessagesPager{}, fastRetry)
+				return err
+			},
+			wantErr: true, requests: 3,
+		},
+		{
+			name: "without retry", method: http.MethodGet, pattern: "/channels/{channel_id}/messages", times: 1,
+			call: func(api openapi.OpenAPI) error {
+				_, err := api.Messages(context.Background(), "c1", &dto.MessagesPager{}, options.WithoutRetry())
+				return err
+			},
+			wantErr: true, requests: 1,
+		},
+		{
+			name: "reply retried", method: http.MethodPost, pattern: "/v2/groups/{group_id}/messages", times: 1,
+			call: func(api openapi.OpenAPI) error {
+				msg := &dto.MessageToCreate{Content: "hi", MsgID: "m1", MsgSeq: 1}
+				_, err := api.PostGroupMessage(context.Background(), "g1", msg, fastRetry)
+				return err
+			},
+			requests: 2,
+		},
+		{
+			name: "active message not retried", method: http.MethodPost, pattern: "/v2/groups/{group_id}/messages", times: 1,
+			call: func(api openapi.OpenAPI) error {
+				_, err := api.PostGroupMessage(context.Background(), "g1", &dto.MessageToCreate{Content: "hi"}, fastRetry)
+				return err
+			},
+			wantErr: true, requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, api := newTestAPI(t)
+			srv.AddChannel(&dto.Channel{ID: "c1"})
+			fault := serverError
+			fault.Times = tt.times
+			srv.InjectFault(tt.method, tt.pattern, fault)
+
+			err := tt.call(api)
+			if tt.wantErr {
+				assert.Equal(t, http.StatusBadGateway, errs.Error(err).Code())
+			} else {
+				assert.NoError(t, err)
+			}
+			reqs := srv.Requests()
+			require.Len(t, reqs, tt.requests)
+			// 重试时请求体保持不变，服务端据此去重
+			for _, r := range reqs {
+				assert.Equal(t, reqs[0].Body, r.Body)
+			}
+		})
+	}
+}
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	policy := options.DefaultRetryPolicy
+	t.Cleanup(func() { options.DefaultRetryPolicy = policy })
+	options.DefaultRetryPolicy = &options.RetryPolicy{MaxRetries: 20, Backoff: time.Millisecond}
+	srv, api := newTestAPI(t)
+	srv.AddGuild(&dto.Guild{ID: "g1"})
+
+	// 不接收 options 的幂等接口同样使用默认策略重试
+	srv.InjectFault(http.MethodGet, "/guilds/{guild_id}", openapitest.Fault{Status: http.StatusBadGateway, Times: 2})
+	_, err := api.Guild(context.Background(), "g1")
+	require.NoError(t, err)
+	assert.Len(t, srv.Requests(), 3)
+
+	// 重试次数不超过 MaxRetriesLimit
+	srv.Reset()
+	srv.InjectFault(http.MethodGet, "/guilds/{guild_id}", openapitest.Fault{Status: http.StatusBadGateway, Times: 20})
+	_, err = api.Guild(context.Background(), "g1")
+	assert.Equal(t, http.StatusBadGateway, errs.Error(err).Code())
+	assert.Len(t, srv.Requests(), options.MaxRetriesLimit+1)
+}
@@ -1,6 +1,8 @@
 package botgo
 
 import (
+	"context"
+
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/sessions/local"
 	"golang.org/x/oauth2"
@@ -15,3 +17,13 @@ type SessionManager interface {
 	// Start 启动连接，默认使用 apInfo 中的 shards 作为 shard 数量，如果有需要自己指定 shard 数，请修 apInfo 中的信息
 	Start(apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSource, intents *dto.Intent) error
 }
+
+// GracefulSessionManager 支持优雅退出的 SessionManager，sessions/local 与 sessions/remote 都已经实现
+type GracefulSessionManager interface {
+	SessionManager
+	// StartWithContext 启动连接，阻塞直到 ctx 结束或者调用 Shutdown
+	StartWithContext(ctx context.Context, apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSource,
+		intents *dto.Intent) error
+	// Shutdown 停止接收新的事件，等待已经接收的事件处理完成后关闭连接，ctx 结束时不再等待
+	Shutdown(ctx context.Context) error
+}
@@ -2,28 +2,62 @@
 package local
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
 	"github.com/tencent-connect/botgo/log"
 	"github.com/tencent-connect/botgo/sessions/manager"
+	"github.com/tencent-connect/botgo/sessions/store"
 	"github.com/tencent-connect/botgo/websocket"
 	"golang.org/x/oauth2"
 )
 
+// Option 本地 session 管理器的配置项
+type Option func(l *ChanManager)
+
+// WithDispatcher 指定连接使用的事件分发器，默认使用 event.DefaultDispatcher
+func WithDispatcher(d *event.Dispatcher) Option {
+	return func(l *ChanManager) {
+		l.dispatcher = d
+	}
+}
+
+// WithSessionStore 持久化 session 的续传信息，进程重启后使用保存的 session 进行 resume
+func WithSessionStore(s store.Store) Option {
+	return func(l *ChanManager) {
+		l.store = s
+	}
+}
+
 // New 创建本地session管理器
-func New() *ChanManager {
-	return &ChanManager{}
+func New(opts ...Option) *ChanManager {
+	l := &ChanManager{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
 // ChanManager 默认的本地 session manager 实现
 type ChanManager struct {
 	sessionChan chan dto.Session
+	dispatcher  *event.Dispatcher
+	conns       manager.Connections
+	store       store.Store
 }
 
-// Start 启动本地 session manager
+// Start 启动本地 session manager，会一直阻塞
 func (l *ChanManager) Start(apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSource, intents *dto.Intent) error {
+	return l.StartWithContext(context.Background(), apInfo, tokenSource, intents)
+}
+
+// StartWithContext 启动本地 session manager，阻塞直到 ctx 结束或者调用 Shutdown
+// ctx 结束时会调用 Shutdown，等待所有连接上已经接收的事件处理完成后返回
+func (l *ChanManager) StartWithContext(ctx context.Context, apInfo *dto.WebsocketAP,
+	tokenSource oauth2.TokenSource, intents *dto.Intent) error {
 	defer log.Sync()
 	if err := manager.CheckSessionLimit(apInfo); err != nil {
 		log.Errorf("[ws/session/local] session limited apInfo: %+v", apInfo)
@@ -46,22 +80,63 @@ func (l *ChanManager) Start(apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSou
 				ShardCount: apInfo.Shards,
 			},
 		}
+		if l.store != nil {
+			store.Restore(ctx, l.store, &session)
+		}
+		l.conns.Expect(session.Shards)
 		l.sessionChan <- session
 	}
 
-	for session := range l.sessionChan {
-		// MaxConcurrency 代表的是每 5s 可以连多少个请求
-		time.Sleep(startInterval)
-		go l.newConnect(session)
+	closing := l.conns.Closing()
+	for {
+		select {
+		case session := <-l.sessionChan:
+			// MaxConcurrency 代表的是每 5s 可以连多少个请求
+			select {
+			case <-time.After(startInterval):
+			case <-ctx.Done():
+				return l.stop()
+			case <-closing:
+				return l.stop()
+			}
+			if !l.conns.Add() {
+				return l.stop()
+			}
+			go l.newConnect(session)
+		case <-ctx.Done():
+			return l.stop()
+		case <-closing:
+			return l.stop()
+		}
+	}
+}
+
+// stop Start 退出前等待所有连接退出
+func (l *ChanManager) stop() error {
+	if err := l.conns.Shutdown(context.Background()); err != nil {
+		return err
 	}
+	log.Infof("[ws/session/local] session manager stopped")
 	return nil
 }
 
+// Shutdown 优雅退出，不再建立新的连接，所有连接停止接收新的事件，等待已经接收的事件处理完成后关闭连接
+// ctx 结束时不再等待，返回 ctx.Err()
+func (l *ChanManager) Shutdown(ctx context.Context) error {
+	return l.conns.Shutdown(ctx)
+}
+
+// Status 返回各个分片的运行状态
+func (l *ChanManager) Status() manager.Status {
+	return l.conns.Status()
+}
+
 // newConnect 启动一个新的连接，如果连接在监听过程中报错了，或者被远端关闭了链接，需要识别关闭的原因，能否继续 resume
 // 如果能够 resume，则往 sessionChan 中放入带有 sessionID 的 session
 // 如果不能，则清理掉 sessionID，将 session 放入 sessionChan 中
 // session 的启动，交给 start 中的 for 循环执行，session 不自己递归进行重连，避免递归深度过深
 func (l *ChanManager) newConnect(session dto.Session) {
+	defer l.conns.Done()
 	defer func() {
 		// panic 留下日志，放回 session
 		if err := recover(); err != nil {
@@ -69,12 +144,17 @@ func (l *ChanManager) newConnect(session dto.Session) {
 			l.sessionChan <- session
 		}
 	}()
-	wsClient := websocket.ClientImpl.New(session)
+	wsClient := websocket.New(session, l.dispatcher)
 	if err := wsClient.Connect(); err != nil {
 		log.Error(err)
 		l.sessionChan <- session // 连接失败，丢回去队列排队重连
 		return
 	}
+	if !l.conns.Track(wsClient) {
+		wsClient.Close()
+		return
+	}
+	defer l.conns.Untrack(wsClient)
 	var err error
 	// 如果 session id 不为空，则执行的是 resume 操作，如果为空，则执行的是 identify 操作
 	if session.ID != "" {
@@ -87,6 +167,7 @@ func (l *ChanManager) newConnect(session dto.Session) {
 		log.Errorf("[ws/session] Identify/Resume err %+v", err)
 		return
 	}
+	checkpointer := store.Start(l.store, wsClient, 0)
 	if err = wsClient.Listening(); err != nil {
 		log.Errorf("[ws/session] Listening err %+v", err)
 		currentSession := wsClient.Session()
@@ -95,6 +176,7 @@ func (l *ChanManager) newConnect(session dto.Session) {
 			currentSession.ID = ""
 			currentSession.LastSeq = 0
 		}
+		checkpointer.Stop(*currentSession)
 		// 一些错误不能够鉴权，比如机器人被封禁，这里就直接退出了
 		if manager.CanNotIdentify(err) {
 			msg := fmt.Sprintf("can not identify because server return %+v, so process exit", err)
@@ -105,4 +187,6 @@ func (l *ChanManager) newConnect(session dto.Session) {
 		l.sessionChan <- *currentSession
 		return
 	}
+	// 优雅退出，保存最终的续传信息
+	checkpointer.Stop(*wsClient.Session())
 }
@@ -0,0 +1,143 @@
+package local
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/sessions/store"
+	"github.com/tencent-connect/botgo/websocket/client"
+	"github.com/tencent-connect/botgo/websocket/websockettest"
+)
+
+func TestChanManager_Reconnect(t *testing.T) {
+	client.Setup()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+	ready := make(chan string, 10)
+	d := event.NewDispatcher()
+	d.RegisterHandlers(event.ReadyHandler(func(_ *dto.WSPayload, data *dto.WSReadyData) { ready <- data.SessionID }))
+
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token", TokenType: "QQBot"})
+	intents := dto.IntentGuildAtMessage
+	m := New(WithDispatcher(d))
+	go func() {
+		_ = m.Start(gw.AP(1), tokenSource, &intents)
+	}()
+	defer func() { _ = m.Shutdown(context.Background()) }()
+	var sessionID string
+	select {
+	case sessionID = <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ready not received")
+	}
+	require.Eventually(t, func() bool { return m.Status().Ready }, 5*time.Second, 10*time.Millisecond)
+
+	// 收到 reconnect 之后，使用原 session 进行 resume
+	gw.Reconnect()
+	resume, err := gw.WaitFor(dto.WSResume, 0, 5*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(resume.RawMessage), sessionID)
+
+	// session 失效之后，重新进行 identify
+	gw.InvalidSession()
+	_, err = gw.WaitFor(dto.WSIdentity, 1, 5*time.Second)
+	assert.NoError(t, err)
+	status := m.Status()
+	require.Len(t, status.Shards, 1)
+	assert.Equal(t, 2, status.Shards[0].Reconnects)
+}
+
+func TestChanManager_Shutdown(t *testing.T) {
+	client.Setup()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	d := event.NewDispatcher()
+	d.RegisterHandlers(event.ATMessageEventHandler(func(_ *dto.WSPayload, _ *dto.WSATMessageData) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		close(finished)
+		return nil
+	}))
+
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token", TokenType: "QQBot"})
+	intents := dto.IntentGuildAtMessage
+	m := New(WithDispatcher(d))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- m.StartWithContext(ctx, gw.AP(1), tokenSource, &intents)
+	}()
+	require.NoError(t, gw.WaitReady(1, 5*time.Second))
+	gw.Dispatch(dto.EventAtMessageCreate, &dto.WSATMessageData{Content: "slow"})
+	<-started
+
+	// 等待处理中的事件完成后，关闭连接，不再重连
+	require.NoError(t, m.Shutdown(context.Background()))
+	select {
+	case <-finished:
+	default:
+		t.Fatal("shutdown returned before handler finished")
+	}
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("start not returned")
+	}
+	require.Eventually(t, func() bool { return len(gw.Conns()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	_, err := gw.WaitFor(dto.WSIdentity, 1, 100*time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestChanManager_SessionStore(t *testing.T) {
+	client.Setup()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token", TokenType: "QQBot"})
+	intents := dto.IntentGuildAtMessage
+	s := store.NewFileStore(filepath.Join(t.TempDir(), "sessions.json"))
+	start := func() *ChanManager {
+		m := New(WithDispatcher(event.NewDispatcher()), WithSessionStore(s))
+		go func() {
+			_ = m.Start(gw.AP(1), tokenSource, &intents)
+		}()
+		require.Eventually(t, func() bool { return m.Status().Ready }, 5*time.Second, 10*time.Millisecond)
+		return m
+	}
+
+	// 第一次启动进行 identify，退出时保存续传信息
+	m := start()
+	sessionID := m.Status().Shards[0].SessionID
+	gw.Dispatch(dto.EventAtMessageCreate, &dto.WSATMessageData{Content: "hello"})
+	require.NoError(t, m.Shutdown(context.Background()))
+	cp, err := s.Load(context.Background(), dto.ShardConfig{ShardID: 0, ShardCount: 1})
+	require.NoError(t, err)
+	require.NotNil(t, cp)
+	assert.Equal(t, sessionID, cp.SessionID)
+
+	// 重启后使用保存的 session 进行 resume
+	m = start()
+	resume, err := gw.WaitFor(dto.WSResume, 0, 5*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(resume.RawMessage), sessionID)
+	require.NoError(t, m.Shutdown(context.Background()))
+
+	// 网关侧 session 已经失效时，重新 identify
+	gw.DropSession(sessionID)
+	m = start()
+	_, err = gw.WaitFor(dto.WSIdentity, 1, 5*time.Second)
+	require.NoError(t, err)
+	assert.NotEqual(t, sessionID, m.Status().Shards[0].SessionID)
+	require.NoError(t, m.Shutdown(context.Background()))
+}
@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/log"
+	"github.com/tencent-connect/botgo/websocket"
+)
+
+// Connections 记录 session manager 正在运行的连接以及各个分片的状态，用于优雅退出与健康检查，零值可以直接使用
+type Connections struct {
+	mu      sync.Mutex
+	closing chan struct{} // 开始退出时关闭
+	done    chan struct{} // 所有连接协程退出后关闭
+	clients map[websocket.WebSocket]uint32
+	shards  map[uint32]*shardRecord
+	wg      sync.WaitGroup
+}
+
+type shardRecord struct {
+	status   ShardStatus
+	expected bool // 当前进程需要维持连接的分片
+	connects int  // 建立连接的次数
+}
+
+func (c *Connections) init() {
+	if c.closing == nil {
+		c.closing = make(chan struct{})
+		c.done = make(chan struct{})
+		c.clients = map[websocket.WebSocket]uint32{}
+		c.shards = map[uint32]*shardRecord{}
+	}
+}
+
+func (c *Connections) shard(shards dto.ShardConfig) *shardRecord {
+	rec, ok := c.shards[shards.ShardID]
+	if !ok {
+		rec = &shardRecord{}
+		rec.status.ShardID = shards.ShardID
+		rec.status.ShardCount = shards.ShardCount
+		c.shards[shards.ShardID] = rec
+	}
+	return rec
+}
+
+// Expect 声明当前进程需要维持连接的分片，这些分片没有连接时就绪检查不通过
+func (c *Connections) Expect(shards dto.ShardConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.shard(shards).expected = true
+}
+
+// Closing 返回开始退出时关闭的 chan
+func (c *Connections) Closing() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	return c.closing
+}
+
+// IsClosing 是否已经开始退出
+func (c *Connections) IsClosing() bool {
+	select {
+	case <-c.Closing():
+		return true
+	default:
+		return false
+	}
+}
+
+// Add 启动连接协程之前调用，已经开始退出时返回 false，返回 true 时连接协程退出前需要调用 Done
+func (c *Connections) Add() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	if c.isClosingLocked() {
+		return false
+	}
+	c.wg.Add(1)
+	return true
+}
+
+// Done 连接协程退出
+func (c *Connections) Done() {
+	c.wg.Done()
+}
+
+// Track 记录正在监听的连接，已经开始退出时返回 false，此时不应该再进行监听
+func (c *Connections) Track(ws websocket.WebSocket) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	if c.isClosingLocked() {
+		return false
+	}
+	shards := ws.Session().Shards
+	rec := c.shard(shards)
+	rec.connects++
+	rec.status.Reconnects = rec.connects - 1
+	rec.status.Connected = true
+	c.clients[ws] = shards.ShardID
+	return true
+}
+
+// Untrack 连接不再监听，记录连接最后的状态
+func (c *Connections) Untrack(ws websocket.WebSocket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shardID, ok := c.clients[ws]
+	if !ok {
+		return
+	}
+	delete(c.clients, ws)
+	rec := c.shards[shardID]
+	rec.status.Status = websocket.StatusOf(ws)
+	rec.status.Connected = false
+	rec.status.ClosedAt = time.Now()
+}
+
+// Status 返回各个分片的状态
+func (c *Connections) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	st := Status{Closing: c.isClosingLocked(), Ready: !c.isClosingLocked()}
+	live := map[uint32]websocket.WebSocket{}
+	for ws, shardID := range c.clients {
+		live[shardID] = ws
+	}
+	for shardID, rec := range c.shards {
+		shard := rec.status
+		if ws, ok := live[shardID]; ok {
+			shard.Status = websocket.StatusOf(ws)
+		}
+		if (rec.expected || shard.Connected) && !(shard.Connected && shard.Ready) {
+			st.Ready = false
+		}
+		st.Shards = append(st.Shards, shard)
+	}
+	sort.Slice(st.Shards, func(i, j int) bool {
+		return st.Shards[i].ShardID < st.Shards[j].ShardID
+	})
+	return st
+}
+
+// Shutdown 开始退出，优雅关闭所有正在监听的连接，并等待所有连接协程退出，ctx 结束时不再等待
+func (c *Connections) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.init()
+	if !c.isClosingLocked() {
+		close(c.closing)
+		go func() {
+			c.wg.Wait()
+			close(c.done)
+		}()
+		for ws := range c.clients {
+			go func(ws websocket.WebSocket) {
+				if err := websocket.Shutdown(ctx, ws); err != nil {
+					log.Errorf("%s shutdown failed, %v", ws.Session(), err)
+				}
+			}(ws)
+		}
+	}
+	done := c.done
+	c.mu.Unlock()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Connections) isClosingLocked() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tencent-connect/botgo/websocket"
+)
+
+// DefaultHeartbeatTimeout 存活检查允许的最长心跳 ack 间隔
+const DefaultHeartbeatTimeout = 3 * time.Minute
+
+// ShardStatus 分片的运行状态，没有连接时为最后一个连接关闭时的状态
+type ShardStatus struct {
+	websocket.Status
+	Connected  bool      `json:"connected"`  // 当前是否有连接在监听
+	Reconnects int       `json:"reconnects"` // 重连次数
+	ClosedAt   time.Time `json:"closed_at"`  // 最近一次连接关闭的时间
+}
+
+// Status session manager 的运行状态
+type Status struct {
+	Closing bool          `json:"closing"` // 正在退出
+	Ready   bool          `json:"ready"`   // 没有在退出，并且所有负责的分片都已经 ready
+	Shards  []ShardStatus `json:"shards"`  // 当前进程负责过的分片，按照 shard id 排序
+}
+
+// Alive 是否所有已经 ready 的连接都在 timeout 内收到过心跳 ack，用于发现已经断开但是没有感知到的连接
+func (s Status) Alive(timeout time.Duration) bool {
+	now := time.Now()
+	for _, shard := range s.Shards {
+		if !shard.Connected || !shard.Ready {
+			continue
+		}
+		last := shard.LastHeartbeatAck
+		if last.Before(shard.ReadyAt) {
+			last = shard.ReadyAt
+		}
+		if now.Sub(last) > timeout {
+			return false
+		}
+	}
+	return true
+}
+
+// StatusReporter 可以查询运行状态的 session manager，sessions/local 与 sessions/remote 都已经实现
+type StatusReporter interface {
+	Status() Status
+}
+
+// LivenessHandler 存活检查，有连接超过 timeout 没有收到心跳 ack 时返回 503，timeout 小于等于 0 时使用 DefaultHeartbeatTimeout
+// 响应内容为 json 格式的 Status
+func LivenessHandler(r StatusReporter, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		timeout = DefaultHeartbeatTimeout
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		st := r.Status()
+		writeStatus(w, st, st.Alive(timeout))
+	})
+}
+
+// ReadinessHandler 就绪检查，正在退出或者有负责的分片没有 ready 时返回 503，响应内容为 json 格式的 Status
+func ReadinessHandler(r StatusReporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		st := r.Status()
+		writeStatus(w, st, st.Ready)
+	})
+}
+
+func writeStatus(w http.ResponseWriter, st Status, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(st)
+}
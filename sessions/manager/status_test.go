@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/websocket"
+)
+
+type staticReporter Status
+
+func (r staticReporter) Status() Status {
+	return Status(r)
+}
+
+func TestHealthHandlers(t *testing.T) {
+	now := time.Now()
+	ready := ShardStatus{
+		Status:    websocket.Status{ShardID: 0, Ready: true, ReadyAt: now.Add(-time.Hour), LastHeartbeatAck: now},
+		Connected: true,
+	}
+	zombie := ready
+	zombie.LastHeartbeatAck = now.Add(-10 * time.Minute)
+	tests := []struct {
+		name      string
+		status    Status
+		liveness  int
+		readiness int
+	}{
+		{"ready", Status{Ready: true, Shards: []ShardStatus{ready}}, http.StatusOK, http.StatusOK},
+		{"connecting", Status{Shards: []ShardStatus{{Connected: true}}}, http.StatusOK, http.StatusServiceUnavailable},
+		{"zombie", Status{Ready: true, Shards: []ShardStatus{zombie}}, http.StatusServiceUnavailable, http.StatusOK},
+		{"closing", Status{Closing: true, Shards: []ShardStatus{ready}}, http.StatusOK, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := staticReporter(tt.status)
+			w := httptest.NewRecorder()
+			LivenessHandler(r, 0).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, tt.liveness, w.Code)
+
+			w = httptest.NewRecorder()
+			ReadinessHandler(r).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.readiness, w.Code)
+			got := Status{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, len(tt.status.Shards), len(got.Shards))
+		})
+	}
+}
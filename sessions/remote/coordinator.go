@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/tencent-connect/botgo/sessions/remote/lock"
+)
+
+// Coordinator 分布式 session manager 依赖的协调存储，提供分布式锁与 session 队列
+// 默认基于 redis 实现，接入其他存储时实现该接口，并通过 NewWithCoordinator 创建 manager
+type Coordinator interface {
+	// NewLock 创建一个锁，value 用于区分持有者
+	NewLock(key, value string) lock.Locker
+	// Push 把数据放入队列 key
+	Push(ctx context.Context, key string, data []byte) error
+	// Pop 按放入顺序从队列 key 中取出数据，队列为空时最多等待 timeout，超时返回 nil
+	Pop(ctx context.Context, key string, timeout time.Duration) ([]byte, error)
+	// Clear 清空队列 key
+	Clear(ctx context.Context, key string) error
+}
+
+// redisCoordinator 基于 redis list 与 redis 锁的协调存储
+type redisCoordinator struct {
+	client redis.UniversalClient
+}
+
+// NewRedisCoordinator 创建基于 redis 的协调存储，支持单机、集群以及哨兵模式
+func NewRedisCoordinator(client redis.UniversalClient) Coordinator {
+	return &redisCoordinator{client: client}
+}
+
+// NewLock 创建一个 redis 锁
+func (c *redisCoordinator) NewLock(key, value string) lock.Locker {
+	return lock.New(key, value, c.client)
+}
+
+// Push 从左侧放入 list
+func (c *redisCoordinator) Push(ctx context.Context, key string, data []byte) error {
+	return c.client.LPush(ctx, key, data).Err()
+}
+
+// Pop 从右侧阻塞取出
+func (c *redisCoordinator) Pop(ctx context.Context, key string, timeout time.Duration) ([]byte, error) {
+	// brpop 返回 key value
+	data, err := c.client.BRPop(ctx, timeout, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, ErrInvalidQueueData
+	}
+	return []byte(data[1]), nil
+}
+
+// Clear 删除 list
+func (c *redisCoordinator) Clear(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
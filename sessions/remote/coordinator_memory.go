@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tencent-connect/botgo/sessions/remote/lock"
+)
+
+// MemoryCoordinator 基于内存的协调存储，只能在同一个进程内协调，用于测试以及单进程运行多个 manager
+type MemoryCoordinator struct {
+	mu     sync.Mutex
+	locks  map[string]memoryLease
+	queues map[string][][]byte
+	notify chan struct{} // 有数据放入时关闭并替换，唤醒等待中的 Pop
+}
+
+type memoryLease struct {
+	value    string
+	expireAt time.Time
+}
+
+// NewMemoryCoordinator 创建基于内存的协调存储
+func NewMemoryCoordinator() *MemoryCoordinator {
+	return &MemoryCoordinator{
+		locks:  map[string]memoryLease{},
+		queues: map[string][][]byte{},
+		notify: make(chan struct{}),
+	}
+}
+
+// NewLock 创建一个内存锁
+func (c *MemoryCoordinator) NewLock(key, value string) lock.Locker {
+	return &memoryLock{c: c, key: key, value: value}
+}
+
+// Push 放入队列
+func (c *MemoryCoordinator) Push(_ context.Context, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queues[key] = append(c.queues[key], append([]byte(nil), data...))
+	close(c.notify)
+	c.notify = make(chan struct{})
+	return nil
+}
+
+// Pop 从队列取出，队列为空时等待
+func (c *MemoryCoordinator) Pop(ctx context.Context, key string, timeout time.Duration) ([]byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		if queue := c.queues[key]; len(queue) > 0 {
+			c.queues[key] = queue[1:]
+			c.mu.Unlock()
+			return queue[0], nil
+		}
+		notify := c.notify
+		c.mu.Unlock()
+		select {
+		case <-notify:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Clear 清空队列
+func (c *MemoryCoordinator) Clear(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.queues, key)
+	return nil
+}
+
+// Len 队列中的数据数量
+func (c *MemoryCoordinator) Len(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queues[key])
+}
+
+// memoryLock 内存锁，过期后其他持有者可以重新加锁
+type memoryLock struct {
+	c     *MemoryCoordinator
+	key   string
+	value string
+}
+
+// Lock 加锁
+func (l *memoryLock) Lock(_ context.Context, expire time.Duration) error {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	if lease, ok := l.c.locks[l.key]; ok && time.Now().Before(lease.expireAt) {
+		return lock.ErrorNotOk
+	}
+	l.c.locks[l.key] = memoryLease{value: l.value, expireAt: time.Now().Add(expire)}
+	return nil
+}
+
+// Renew 续期
+func (l *memoryLock) Renew(_ context.Context, expire time.Duration) error {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	if lease, ok := l.c.locks[l.key]; ok && lease.value == l.value {
+		l.c.locks[l.key] = memoryLease{value: l.value, expireAt: time.Now().Add(expire)}
+	}
+	return nil
+}
+
+// Release 释放锁
+func (l *memoryLock) Release(_ context.Context) error {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	if lease, ok := l.c.locks[l.key]; ok && lease.value == l.value {
+		delete(l.c.locks, l.key)
+	}
+	return nil
+}
@@ -13,4 +13,6 @@ var (
 	ErrProduceFailed = errors.New("produce session failed")
 	// ErrorNotOk redis 写失败
 	ErrorNotOk = errors.New("redis write not ok")
+	// ErrInvalidQueueData 从 session 队列读取的数据格式不正确
+	ErrInvalidQueueData = errors.New("session queue data is not valid")
 )
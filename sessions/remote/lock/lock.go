@@ -1,4 +1,4 @@
-// Package lock 一个基于 redis 的分布式锁实现。
+// Package lock 分布式锁的接口，以及一个基于 redis 的实现。
 package lock
 
 import (
@@ -13,17 +13,48 @@ import (
 // ErrorNotOk redis 写失败
 var ErrorNotOk = errors.New("redis write not ok")
 
-// Lock 一个基于redis的锁实现
+// Locker 带有过期时间的分布式锁，持有者需要在过期前续期
+type Locker interface {
+	// Lock 加锁，锁已经被持有时返回 ErrorNotOk
+	Lock(ctx context.Context, expire time.Duration) error
+	// Renew 续期，只有持有者可以续期
+	Renew(ctx context.Context, expire time.Duration) error
+	// Release 释放锁，只有持有者可以释放
+	Release(ctx context.Context) error
+}
+
+// KeepAlive 每 1/3 过期时间续期一次，直到 ctx 结束，需要放到 goroutine 中执行
+func KeepAlive(ctx context.Context, l Locker, expire time.Duration) {
+	if expire == 0 {
+		return
+	}
+	ticker := time.NewTicker(expire / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Renew(ctx, expire); err != nil {
+				log.Errorf("[lock] renew lock failed, lock: %+v, err: %v", l, err)
+				continue
+			}
+			log.Debugf("[lock] renew lock ok, lock: %+v", l)
+		}
+	}
+}
+
+// Lock 一个基于redis的锁实现，支持单机、集群以及哨兵模式
 type Lock struct {
 	lockKey       string
 	lockValue     string
-	client        *redis.Client
+	client        redis.UniversalClient
 	renewTicker   *time.Ticker // 用于续期的ticker，默认为超时时间的 1/3
 	stopRenewChan chan bool    // 用于停止 renew
 }
 
 // New 创建一个锁
-func New(key, value string, client *redis.Client) *Lock {
+func New(key, value string, client redis.UniversalClient) *Lock {
 	return &Lock{
 		lockKey:   key,
 		lockValue: value,
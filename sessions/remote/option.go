@@ -1,5 +1,10 @@
 package remote
 
+import (
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/sessions/store"
+)
+
 // Option is a function that configures a Remote.
 type Option func(manager *RedisManager)
 
@@ -9,3 +14,17 @@ func WithClusterKey(key string) Option {
 		m.clusterKey = key
 	}
 }
+
+// WithDispatcher 指定连接使用的事件分发器，默认使用 event.DefaultDispatcher
+func WithDispatcher(d *event.Dispatcher) Option {
+	return func(m *RedisManager) {
+		m.dispatcher = d
+	}
+}
+
+// WithSessionStore 持久化 session 的续传信息，集群重新分发 session 时使用保存的 session 进行 resume
+func WithSessionStore(s store.Store) Option {
+	return func(m *RedisManager) {
+		m.store = s
+	}
+}
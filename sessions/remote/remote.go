@@ -1,4 +1,4 @@
-// Package remote 基于 redis list 实现的分布式 session manager。
+// Package remote 分布式 session manager，默认基于 redis list 与 redis 锁实现，也可以接入其他协调存储。
 package remote
 
 import (
@@ -10,9 +10,11 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
 	"github.com/tencent-connect/botgo/log"
 	"github.com/tencent-connect/botgo/sessions/manager"
 	"github.com/tencent-connect/botgo/sessions/remote/lock"
+	"github.com/tencent-connect/botgo/sessions/store"
 	"github.com/tencent-connect/botgo/token"
 	"github.com/tencent-connect/botgo/websocket"
 	"golang.org/x/oauth2"
@@ -29,20 +31,29 @@ const (
 	shardLockExpireTime = 30 * time.Second
 )
 
-// RedisManager 基于 redis 的 session 管理器，实现分布式 websocket 监听
+// RedisManager 分布式 session 管理器，实现分布式 websocket 监听，默认基于 redis
 type RedisManager struct {
 	clusterKey         string
 	sessionQueueKey    string
-	client             *redis.Client
-	sessionProduceChan chan dto.Session // 抢到锁的服务，用于持续生产session到redis list的本地chan
+	coordinator        Coordinator
+	tokenSource        oauth2.TokenSource // session 在队列中不包含 token，取出后使用当前进程的 token
+	sessionProduceChan chan dto.Session   // 抢到锁的服务，用于持续生产session到redis list的本地chan
+	dispatcher         *event.Dispatcher
+	conns              manager.Connections
+	store              store.Store
 }
 
-// New 创建一个新的基于 redis 的 session 管理器
+// New 创建一个新的基于 redis 的 session 管理器，支持 redis.NewClient、NewClusterClient、NewFailoverClient 等创建的客户端
 // 使用 go-redis 调用 redis，超时时间请在 NewClient 时候设置
-func New(client *redis.Client, opts ...Option) *RedisManager {
+func New(client redis.UniversalClient, opts ...Option) *RedisManager {
+	return NewWithCoordinator(NewRedisCoordinator(client), opts...)
+}
+
+// NewWithCoordinator 创建一个基于指定协调存储的 session 管理器
+func NewWithCoordinator(c Coordinator, opts ...Option) *RedisManager {
 	r := &RedisManager{
-		clusterKey: defaultClusterKey,
-		client:     client,
+		clusterKey:  defaultClusterKey,
+		coordinator: c,
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -52,8 +63,15 @@ func New(client *redis.Client, opts ...Option) *RedisManager {
 	return r
 }
 
-// Start 启动 redis 的 session 管理器
+// Start 启动 redis 的 session 管理器，会一直阻塞
 func (r *RedisManager) Start(apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSource, intents *dto.Intent) error {
+	return r.StartWithContext(context.Background(), apInfo, tokenSource, intents)
+}
+
+// StartWithContext 启动 redis 的 session 管理器，阻塞直到 ctx 结束或者调用 Shutdown
+// 退出时等待所有连接上已经接收的事件处理完成，释放持有的分片锁，并把 session 放回 redis，由其他实例立即 resume
+func (r *RedisManager) StartWithContext(ctx context.Context, apInfo *dto.WebsocketAP,
+	tokenSource oauth2.TokenSource, intents *dto.Intent) error {
 	defer log.Sync()
 	if err := manager.CheckSessionLimit(apInfo); err != nil {
 		log.Errorf("[ws/session/redis] session limited apInfo: %+v", apInfo)
@@ -63,21 +81,33 @@ func (r *RedisManager) Start(apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSo
 	log.Infof("[ws/session/redis] will start %d sessions and per session start interval is %s",
 		apInfo.Shards, startInterval)
 
+	r.tokenSource = tokenSource
 	// session 生产队列
 	r.sessionProduceChan = make(chan dto.Session, apInfo.Shards)
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.conns.Closing():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	// 进行初始的session分发，抢锁，分发
 	// 锁60s，抢到锁的进程，需要每30s续期一次，只要自己还存活，就不能够让另外的进程抢到锁重新进行shards分发
-	ctx := context.Background()
-	distributeLock := lock.New(r.clusterKey, uuid.New().String(), r.client)
+	distributeLock := r.coordinator.NewLock(r.clusterKey, uuid.New().String())
 	if err := distributeLock.Lock(ctx, distributeLockExpireTime); err == nil {
 		log.Infof("[ws/session/redis] got distribute lock! i will do distributeSession, key: %s", r.clusterKey)
 		// 抢到锁的进行初次分发
-		if err = r.distributeSession(apInfo, tokenSource, intents); err != nil {
+		if err = r.distributeSession(ctx, apInfo, tokenSource, intents); err != nil {
 			log.Errorf("[ws/session/redis] distribute sessions failed: %v", err)
 			return err
 		}
-		go distributeLock.StartRenew(ctx, distributeLockExpireTime)
+		// 退出时不释放分发锁，等待过期；滚动发布时新启动的实例如果立即重新分发，
+		// 会清空其他实例刚放回的 session，并为仍在运行的分片重复生产 session
+		go lock.KeepAlive(ctx, distributeLock, distributeLockExpireTime)
 	} else {
 		log.Errorf("got lock failed, err: %v", err)
 	}
@@ -85,37 +115,74 @@ func (r *RedisManager) Start(apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSo
 	// 持续 produce session，遇到网络问题在 chan 中重试
 	// 对于抢到了锁的服务，生产第一批session到redis list
 	// 对于没有抢到锁的服务，当ws异常，把session放回到 redis list 中，重新分发
-	go r.sessionProducer(startInterval)
+	go r.sessionProducer(ctx, startInterval)
 
-	return r.consume(startInterval)
+	r.consume(ctx, startInterval)
+	err := r.conns.Shutdown(context.Background())
+	r.flushSessions()
+	log.Infof("[ws/session/redis] session manager stopped")
+	return err
 }
 
-func (r *RedisManager) consume(startInterval time.Duration) error {
+// Shutdown 优雅退出，不再消费新的 session，所有连接停止接收新的事件，等待已经接收的事件处理完成后关闭连接
+// 连接关闭后释放 shard 锁，并把 session 放回 redis；ctx 结束时不再等待，返回 ctx.Err()
+func (r *RedisManager) Shutdown(ctx context.Context) error {
+	return r.conns.Shutdown(ctx)
+}
+
+// Status 返回当前进程持有过的分片的运行状态，其他进程持有的分片不包含在内
+func (r *RedisManager) Status() manager.Status {
+	return r.conns.Status()
+}
+
+func (r *RedisManager) consume(ctx context.Context, startInterval time.Duration) {
 	log.Debug("[ws/session/redis] start consume for session")
-	for {
-		// brpop 返回 key value
-		data, err := r.client.BRPop(context.Background(), startInterval*2, r.sessionQueueKey).Result()
+	for ctx.Err() == nil {
+		data, err := r.coordinator.Pop(ctx, r.sessionQueueKey, startInterval*2)
 		if err != nil {
-			if err != redis.Nil {
-				log.Errorf("[ws/session/redis] rpop failed, err: %v", err)
+			if ctx.Err() == nil {
+				log.Errorf("[ws/session/redis] pop session failed, err: %v", err)
 			}
 			continue
 		}
-		if len(data) < 2 {
-			log.Errorf("[ws/session/redis] data is not valid, data: %+v", data)
+		if data == nil {
 			continue
 		}
 		log.Debugf("[ws/session/redis] consume data: %s", data)
 
 		session := &dto.Session{}
-		if err := json.Unmarshal([]byte(data[1]), session); err != nil {
+		if err := json.Unmarshal(data, session); err != nil {
 			// 解析出错，不放回去，直接丢弃
 			log.Errorf("[ws/session/redis] unmarshal session failed, err: %v", err)
 			continue
 		}
-
+		session.TokenSource = r.tokenSource
+		if !r.conns.Add() {
+			// 已经开始退出，放回去由其他实例消费
+			r.handOver(*session)
+			return
+		}
 		go r.newConnect(*session)
-		time.Sleep(startInterval) // 启动一个连接后，等待一下，避免触发服务端的并发控制
+		// 启动一个连接后，等待一下，避免触发服务端的并发控制
+		select {
+		case <-time.After(startInterval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// handOver 退出时直接把 session 放回 redis，由其他实例立即接管，失败时交给 sessionProducer 重试
+func (r *RedisManager) handOver(session dto.Session) {
+	if err := r.produce(session); err != nil {
+		log.Errorf("[ws/session/redis] hand over session failed: %v", err)
+		r.sessionProduceChan <- session
+	}
+}
+
+// releaseLock 释放锁，需要先通过 ctx 停止续期
+func releaseLock(l lock.Locker) {
+	if err := l.Release(context.Background()); err != nil {
+		log.Errorf("[ws/session/redis] release lock failed, err: %s", err)
 	}
 }
 
@@ -130,28 +197,41 @@ func (r *RedisManager) getShardLockKey(session dto.Session) string {
 // 如果不能，则清理掉 sessionID，将 session 放入 sessionChan 中
 // session 的启动，交给 start 中的 for 循环执行，session 不自己递归进行重连，避免递归深度过深
 func (r *RedisManager) newConnect(session dto.Session) {
+	defer r.conns.Done()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// 锁 shard，避免针对相同 shard 消费重复了
-	shardLock := lock.New(r.getShardLockKey(session), uuid.NewString(), r.client)
+	shardLock := r.coordinator.NewLock(r.getShardLockKey(session), uuid.NewString())
 	if err := shardLock.Lock(ctx, shardLockExpireTime); err != nil {
 		// shard 抢锁失败，把 session 放回去，避免上一个 session 的锁释放失败，导致下一个 session 无法启动
 		r.sessionProduceChan <- session
 		return
 	}
-	go shardLock.StartRenew(ctx, shardLockExpireTime)
+	go lock.KeepAlive(ctx, shardLock, shardLockExpireTime)
 	// token初始化失败，重新放回去
 	if err := token.StartRefreshAccessToken(ctx, session.TokenSource); err != nil {
 		r.sessionProduceChan <- session
 		return
 	}
-	wsClient := websocket.ClientImpl.New(session)
+	// 优雅退出时，停止续期并释放 shard 锁，session 放回去由其他实例立即 resume
+	handOver := func(session dto.Session) {
+		cancel()
+		releaseLock(shardLock)
+		r.handOver(session)
+	}
+	wsClient := websocket.New(session, r.dispatcher)
 	if err := wsClient.Connect(); err != nil {
 		log.Error(err)
 		r.sessionProduceChan <- session // 连接失败，丢回去队列排队重连
 		return
 	}
+	if !r.conns.Track(wsClient) {
+		wsClient.Close()
+		handOver(session)
+		return
+	}
+	defer r.conns.Untrack(wsClient)
 	var err error
 	// 如果 session id 不为空，则执行的是 resume 操作，如果为空，则执行的是 identify 操作
 	if session.ID != "" {
@@ -164,6 +244,7 @@ func (r *RedisManager) newConnect(session dto.Session) {
 		log.Errorf("[ws/session/remote] Identify/Resume err %+v", err)
 		return
 	}
+	checkpointer := store.Start(r.store, wsClient, 0)
 	if err = wsClient.Listening(); err != nil {
 		log.Errorf("[ws/session/remote] Listening err %+v", err)
 		currentSession := wsClient.Session()
@@ -172,6 +253,7 @@ func (r *RedisManager) newConnect(session dto.Session) {
 			currentSession.ID = ""
 			currentSession.LastSeq = 0
 		}
+		checkpointer.Stop(*currentSession)
 		// 一些错误不能够鉴权，比如机器人被封禁，这里就直接退出了
 		if manager.CanNotIdentify(err) {
 			msg := fmt.Sprintf("can not identify because server return %+v, so process exit", err)
@@ -179,11 +261,11 @@ func (r *RedisManager) newConnect(session dto.Session) {
 			panic(msg) // 当机器人被下架，或者封禁，将不能再连接，所以 panic
 		}
 		// 将 session 放到 session chan 中，用于启动新的连接，释放锁，当前连接退出
-		shardLock.StopRenew()
-		if err = shardLock.Release(ctx); err != nil {
-			log.Errorf("[ws/session/remote] release shardLock failed, err: %s", err)
-		}
+		cancel()
+		releaseLock(shardLock)
 		r.sessionProduceChan <- *currentSession
 		return
 	}
+	checkpointer.Stop(*wsClient.Session())
+	handOver(*wsClient.Session())
 }
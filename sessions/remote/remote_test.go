@@ -0,0 +1,102 @@
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/sessions/remote/lock"
+	"github.com/tencent-connect/botgo/websocket/client"
+	"github.com/tencent-connect/botgo/websocket/websockettest"
+)
+
+func TestMemoryCoordinator(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCoordinator()
+
+	a, b := c.NewLock("key", "a"), c.NewLock("key", "b")
+	require.NoError(t, a.Lock(ctx, time.Minute))
+	assert.Equal(t, lock.ErrorNotOk, b.Lock(ctx, time.Minute))
+	// 只有持有者可以释放
+	require.NoError(t, b.Release(ctx))
+	assert.Equal(t, lock.ErrorNotOk, b.Lock(ctx, time.Minute))
+	require.NoError(t, a.Release(ctx))
+	require.NoError(t, b.Lock(ctx, time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, a.Lock(ctx, time.Minute), "expired lock")
+
+	require.NoError(t, c.Push(ctx, "q", []byte("1")))
+	require.NoError(t, c.Push(ctx, "q", []byte("2")))
+	data, err := c.Pop(ctx, "q", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(data))
+	require.NoError(t, c.Clear(ctx, "q"))
+	data, err = c.Pop(ctx, "q", 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = c.Push(ctx, "q", []byte("3"))
+	}()
+	data, err = c.Pop(ctx, "q", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(data))
+}
+
+func TestRedisManager_HandOver(t *testing.T) {
+	client.Setup()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+	ap := gw.AP(1)
+	ap.SessionStartLimit.MaxConcurrency = 5
+	tokenSource := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: "token", TokenType: "QQBot", ExpiresIn: 7200})
+	intents := dto.IntentGuildAtMessage
+	coordinator := NewMemoryCoordinator()
+	ready := func(m *RedisManager) func() bool {
+		return func() bool {
+			st := m.Status()
+			return st.Ready && len(st.Shards) == 1
+		}
+	}
+	start := func() (*RedisManager, <-chan error) {
+		m := NewWithCoordinator(coordinator, WithDispatcher(event.NewDispatcher()))
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- m.Start(ap, tokenSource, &intents)
+		}()
+		return m, stopped
+	}
+
+	// 第一个实例抢到锁进行分发，并消费唯一的分片
+	m1, stopped := start()
+	require.Eventually(t, ready(m1), 5*time.Second, 10*time.Millisecond)
+	sessionID := m1.Status().Shards[0].SessionID
+	m2, _ := start()
+	defer func() {
+		_ = m2.Shutdown(context.Background())
+	}()
+
+	// 第一个实例退出后，分片交给第二个实例 resume
+	require.NoError(t, m1.Shutdown(context.Background()))
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("start not returned")
+	}
+	// 分发锁等待过期，之后启动的实例不会重新分发，清空放回的 session
+	assert.Error(t, coordinator.NewLock(m1.clusterKey, "other").Lock(context.Background(), time.Second))
+	resume, err := gw.WaitFor(dto.WSResume, 0, 5*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(resume.RawMessage), sessionID)
+	require.Eventually(t, ready(m2), 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, sessionID, m2.Status().Shards[0].SessionID)
+}
@@ -7,14 +7,15 @@ import (
 
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/log"
+	"github.com/tencent-connect/botgo/sessions/store"
 	"golang.org/x/oauth2"
 )
 
 // distributeSession 根据 shards 生产初始化的 session，这里需要抢一个分布式锁，抢到锁的服务器，负责把session都生产到 redis 中
-func (r *RedisManager) distributeSession(
+func (r *RedisManager) distributeSession(ctx context.Context,
 	apInfo *dto.WebsocketAP, tokenSource oauth2.TokenSource, intents *dto.Intent) error {
 	// clear，报错也不影响
-	if err := r.client.Del(context.Background(), r.sessionQueueKey); err != nil {
+	if err := r.coordinator.Clear(context.Background(), r.sessionQueueKey); err != nil {
 		log.Errorf("[ws/session/redis] clear session list failed: %v", err)
 	}
 	for i := uint32(0); i < apInfo.Shards; i++ {
@@ -28,28 +29,57 @@ func (r *RedisManager) distributeSession(
 				ShardCount: apInfo.Shards,
 			},
 		}
+		if r.store != nil {
+			store.Restore(ctx, r.store, &session)
+		}
 		r.sessionProduceChan <- session
 	}
 
 	return nil
 }
 
-// sessionProducer 从 chan 取到session，push 到 redis，push 失败放回 chan
-func (r *RedisManager) sessionProducer(startInterval time.Duration) {
-	for session := range r.sessionProduceChan {
-		time.Sleep(startInterval) // 每次生产需要等待一个间隔，控制消费者连接并发
-		if err := r.produce(session); err != nil {
-			log.Errorf("[ws/session/redis] produce session failed: %v", err)
-			r.sessionProduceChan <- session // 放回去重试
+// sessionProducer 从 chan 取到session，push 到 redis，push 失败放回 chan，ctx 结束时退出
+func (r *RedisManager) sessionProducer(ctx context.Context, startInterval time.Duration) {
+	for {
+		select {
+		case session := <-r.sessionProduceChan:
+			// 每次生产需要等待一个间隔，控制消费者连接并发，退出时不再等待
+			select {
+			case <-time.After(startInterval):
+			case <-ctx.Done():
+			}
+			if err := r.produce(session); err != nil {
+				log.Errorf("[ws/session/redis] produce session failed: %v", err)
+				r.sessionProduceChan <- session // 放回去重试
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flushSessions 退出前把 chan 中剩余的 session 放回 redis，避免其他实例无法接管
+func (r *RedisManager) flushSessions() {
+	for {
+		select {
+		case session := <-r.sessionProduceChan:
+			if err := r.produce(session); err != nil {
+				log.Errorf("[ws/session/redis] produce session failed, shard %d lost until redistributed: %v",
+					session.Shards.ShardID, err)
+			}
+		default:
+			return
 		}
 	}
 }
 
 func (r *RedisManager) produce(session dto.Session) error {
+	// token 不能序列化，由取出 session 的进程填充
+	session.TokenSource = nil
 	data, err := json.Marshal(session)
 	log.Debugf("[ws][session/redis] produce session data is %s", string(data))
 	if err != nil {
 		return ErrSessionMarshalFailed
 	}
-	return r.client.LPush(context.Background(), r.sessionQueueKey, data).Err()
+	return r.coordinator.Push(context.Background(), r.sessionQueueKey, data)
 }
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// FileStore 基于本地文件的续传信息存储，所有分片保存在一个 json 文件中，适用于单实例部署
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore 创建基于本地文件的续传信息存储，文件不存在时会在第一次保存时创建
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load 读取分片的续传信息
+func (s *FileStore) Load(_ context.Context, shard dto.ShardConfig) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return all[key(shard)], nil
+}
+
+// Save 保存分片的续传信息
+func (s *FileStore) Save(_ context.Context, shard dto.ShardConfig, cp *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	all[key(shard)] = cp
+	return s.write(all)
+}
+
+// Delete 删除分片的续传信息
+func (s *FileStore) Delete(_ context.Context, shard dto.ShardConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[key(shard)]; !ok {
+		return nil
+	}
+	delete(all, key(shard))
+	return s.write(all)
+}
+
+func (s *FileStore) read() (map[string]*Checkpoint, error) {
+	all := map[string]*Checkpoint{}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return all, nil
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// write 先写入临时文件再重命名，避免进程退出时写入一半导致文件损坏
+func (s *FileStore) write(all map[string]*Checkpoint) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
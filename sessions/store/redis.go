@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// defaultRedisKeyPrefix redis 中续传信息 key 的默认前缀
+const defaultRedisKeyPrefix = "botgo:session:"
+
+// RedisOption redis 存储的配置项
+type RedisOption func(s *RedisStore)
+
+// WithKeyPrefix 自定义 redis key 的前缀，多个机器人共用 redis 时需要区分
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
+}
+
+// RedisStore 基于 redis 的续传信息存储，适用于容器等没有持久化磁盘的部署
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore 创建基于 redis 的续传信息存储，超时时间请在 NewClient 时候设置
+func NewRedisStore(client redis.UniversalClient, opts ...RedisOption) *RedisStore {
+	s := &RedisStore{client: client, prefix: defaultRedisKeyPrefix}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load 读取分片的续传信息
+func (s *RedisStore) Load(ctx context.Context, shard dto.ShardConfig) (*Checkpoint, error) {
+	data, err := s.client.Get(ctx, s.prefix+key(shard)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// Save 保存分片的续传信息
+func (s *RedisStore) Save(ctx context.Context, shard dto.ShardConfig, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key(shard), data, 0).Err()
+}
+
+// Delete 删除分片的续传信息
+func (s *RedisStore) Delete(ctx context.Context, shard dto.ShardConfig) error {
+	return s.client.Del(ctx, s.prefix+key(shard)).Err()
+}
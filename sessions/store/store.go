@@ -0,0 +1,133 @@
+// Package store 持久化 websocket session 的续传信息（session id 与 seq），用于进程重启后 resume 连接。
+//
+// session manager 启动时使用保存的 session id 与 seq 进行 resume，网关返回 invalid session 时会重新 identify。
+// 续传信息定时保存，只保存到 handler 已经处理完成的 seq，进程崩溃时未处理的事件会在 resume 后重新下发。
+// resume 后网关可能重新下发少量已经处理过的事件，可以配合 event/dedup 去重。
+//
+//	s := store.NewFileStore("/data/botgo-sessions.json")
+//	_ = local.New(local.WithSessionStore(s)).Start(apInfo, tokenSource, &intent)
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/log"
+	"github.com/tencent-connect/botgo/websocket"
+)
+
+// DefaultInterval 默认保存续传信息的间隔
+const DefaultInterval = 5 * time.Second
+
+// Checkpoint session 的续传信息
+type Checkpoint struct {
+	SessionID string    `json:"session_id"`
+	LastSeq   uint32    `json:"last_seq"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store 续传信息存储，按照分片保存，不存在时 Load 返回 nil, nil
+type Store interface {
+	Load(ctx context.Context, shard dto.ShardConfig) (*Checkpoint, error)
+	Save(ctx context.Context, shard dto.ShardConfig, cp *Checkpoint) error
+	Delete(ctx context.Context, shard dto.ShardConfig) error
+}
+
+// key 分片数量变化后，之前的 session 不能再使用
+func key(shard dto.ShardConfig) string {
+	return fmt.Sprintf("%d_%d", shard.ShardID, shard.ShardCount)
+}
+
+// Restore 使用保存的续传信息填充 session，读取失败时忽略，重新 identify
+func Restore(ctx context.Context, s Store, session *dto.Session) {
+	cp, err := s.Load(ctx, session.Shards)
+	if err != nil {
+		log.Errorf("%s load session checkpoint failed, %v", session, err)
+		return
+	}
+	if cp == nil || cp.SessionID == "" {
+		return
+	}
+	session.ID = cp.SessionID
+	session.LastSeq = cp.LastSeq
+	log.Infof("%s restored from checkpoint, seq %d, updated at %s", session, cp.LastSeq, cp.UpdatedAt)
+}
+
+// Save 保存 session 的续传信息，session id 为空时删除
+func Save(ctx context.Context, s Store, session dto.Session) {
+	var err error
+	if session.ID == "" {
+		err = s.Delete(ctx, session.Shards)
+	} else {
+		err = s.Save(ctx, session.Shards, &Checkpoint{
+			SessionID: session.ID,
+			LastSeq:   session.LastSeq,
+			UpdatedAt: time.Now(),
+		})
+	}
+	if err != nil {
+		log.Errorf("%s save session checkpoint failed, %v", &session, err)
+	}
+}
+
+// Checkpointer 定时保存一个连接的续传信息，只在 session id 或 seq 变化时写入
+type Checkpointer struct {
+	store Store
+	ws    websocket.WebSocket
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Start 开始定时保存 ws 的续传信息，interval 小于等于 0 时使用 DefaultInterval，s 为空时返回 nil
+func Start(s Store, ws websocket.WebSocket, interval time.Duration) *Checkpointer {
+	if s == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	c := &Checkpointer{store: s, ws: ws, stop: make(chan struct{}), done: make(chan struct{})}
+	go c.run(interval)
+	return c
+}
+
+// Stop 停止定时保存，并保存连接最终的 session，session id 为空时删除续传信息
+// 连接上还有事件没有处理完成时，只保存到已经处理完成的 seq
+func (c *Checkpointer) Stop(session dto.Session) {
+	if c == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	if st := websocket.StatusOf(c.ws); session.ID == st.SessionID && st.HandledSeq < session.LastSeq {
+		session.LastSeq = st.HandledSeq
+	}
+	Save(context.Background(), c.store, session)
+}
+
+func (c *Checkpointer) run(interval time.Duration) {
+	defer close(c.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last websocket.Status
+	for {
+		select {
+		case <-ticker.C:
+			st := websocket.StatusOf(c.ws)
+			if st.SessionID == "" || (st.SessionID == last.SessionID && st.HandledSeq == last.HandledSeq) {
+				continue
+			}
+			// 只使用 Status 中的信息，连接的 session 会被其他协程修改
+			Save(context.Background(), c.store, dto.Session{
+				ID:      st.SessionID,
+				LastSeq: st.HandledSeq,
+				Shards:  dto.ShardConfig{ShardID: st.ShardID, ShardCount: st.ShardCount},
+			})
+			last = st
+		case <-c.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	s := NewFileStore(path)
+	shard := dto.ShardConfig{ShardID: 1, ShardCount: 2}
+
+	cp, err := s.Load(ctx, shard)
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+
+	Save(ctx, s, dto.Session{ID: "s1", LastSeq: 10, Shards: shard})
+	Save(ctx, s, dto.Session{ID: "s0", LastSeq: 3, Shards: dto.ShardConfig{ShardID: 0, ShardCount: 2}})
+	// 重新打开文件，模拟进程重启
+	session := dto.Session{Shards: shard}
+	Restore(ctx, NewFileStore(path), &session)
+	assert.Equal(t, "s1", session.ID)
+	assert.Equal(t, uint32(10), session.LastSeq)
+
+	// 分片数量变化后不能使用之前的 session
+	session = dto.Session{Shards: dto.ShardConfig{ShardID: 1, ShardCount: 4}}
+	Restore(ctx, s, &session)
+	assert.Empty(t, session.ID)
+
+	// session id 为空时删除续传信息
+	Save(ctx, s, dto.Session{Shards: shard})
+	cp, err = s.Load(ctx, shard)
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+	cp, err = s.Load(ctx, dto.ShardConfig{ShardID: 0, ShardCount: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "s0", cp.SessionID)
+}
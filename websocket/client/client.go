@@ -2,10 +2,14 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/errs"
 	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/event/record"
 	"github.com/tencent-connect/botgo/log"
 	"github.com/tencent-connect/botgo/websocket"
 )
@@ -20,19 +25,45 @@ import (
 // DefaultQueueSize 监听队列的缓冲长度
 const DefaultQueueSize = 10000
 
-// Setup 依赖注册
-func Setup() {
-	websocket.Register(&Client{})
+// DefaultHeartbeatMissLimit 默认连续 2 次心跳没有收到 ack 时，认为连接已经断开
+const DefaultHeartbeatMissLimit = 2
+
+// Setup 依赖注册，opts 对之后创建的所有连接生效
+func Setup(opts ...Option) {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	websocket.Register(c)
 }
 
 // New 新建一个连接对象
 func (c *Client) New(session dto.Session) websocket.WebSocket {
-	return &Client{
-		messageQueue:    make(messageChan, DefaultQueueSize),
+	queueSize := c.opts.queueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	client := &Client{
+		messageQueue:    make(messageChan, queueSize),
 		session:         &session,
 		closeChan:       make(closeErrorChan, 10),
 		heartBeatTicker: time.NewTicker(60 * time.Second), // 先给一个默认 ticker，在收到 hello 包之后，会 reset
+		dispatcher:      event.DefaultDispatcher,
+		opts:            c.opts,
+		shutdown:        make(chan struct{}),
+		stopped:         make(chan struct{}),
+		inflight:        map[uint32]struct{}{},
+	}
+	client.pool = newWorkerPool(c.opts.workers, c.opts.workerQueueSize, c.opts.keyFunc, client.handle)
+	if c.opts.backpressure == BackpressureSpill {
+		client.spill = newSpillQueue(c.opts.spillDir)
 	}
+	return client
+}
+
+// SetDispatcher 指定事件分发器，需要在 Listening 之前调用
+func (c *Client) SetDispatcher(d *event.Dispatcher) {
+	c.dispatcher = d
 }
 
 // Client websocket 连接客户端
@@ -43,7 +74,20 @@ type Client struct {
 	session         *dto.Session
 	user            *dto.WSUser
 	closeChan       closeErrorChan
-	heartBeatTicker *time.Ticker // 用于维持定时心跳
+	heartBeatTicker *time.Ticker      // 用于维持定时心跳
+	dispatcher      *event.Dispatcher // 事件分发器
+	opts            options
+	pool            *workerPool   // 并发处理事件的 worker 池
+	shutdown        chan struct{} // 调用 Shutdown 后关闭，不再接收新的事件
+	shutdownOnce    sync.Once
+	stopped         chan struct{} // 已接收的事件全部处理完成后关闭
+	mu              sync.Mutex    // 保护 session 以及 status，其他协程通过 sessionSnapshot 读取 session
+	status          websocket.Status
+	inflight        map[uint32]struct{} // 已经投递给 worker 还没有处理完成的事件 seq
+	heartbeatSentAt time.Time           // 最近一次发送心跳的时间，收到 ack 后清空
+	spill           *spillQueue         // BackpressureSpill 策略下接收队列已满时写入的磁盘队列
+	dropped         int64               // 接收队列已满而丢弃的事件数，原子操作
+	stalled         int32               // BackpressureBlock 策略下读取协程等待队列空位，原子操作
 }
 
 type messageChan chan *dto.WSPayload
@@ -56,13 +100,18 @@ func (c *Client) Connect() error {
 	}
 
 	var err error
-	c.conn, _, err = wss.DefaultDialer.Dial(c.session.URL, nil)
+	c.conn, _, err = c.opts.newDialer().Dial(c.session.URL, c.opts.header)
 	if err != nil {
-		log.Errorf("%s, connect err: %v", c.session, err)
+		log.Errorf("%s, connect err: %v", c.sessionSnapshot(), err)
 		return err
 	}
-	log.Infof("%s, url %s, connected", c.session, c.session.URL)
-
+	if c.opts.readLimit > 0 {
+		c.conn.SetReadLimit(c.opts.readLimit)
+	}
+	log.Infof("%s, url %s, connected", c.sessionSnapshot(), c.session.URL)
+	c.mu.Lock()
+	c.status.ConnectedAt = time.Now()
+	c.mu.Unlock()
 	return nil
 }
 
@@ -70,6 +119,10 @@ func (c *Client) Connect() error {
 // 定时心跳也在这里维护
 func (c *Client) Listening() error {
 	defer c.Close()
+	if c.isShuttingDown() {
+		close(c.stopped)
+		return nil
+	}
 	// reading message
 	go c.readMessageToQueue()
 	// read message from queue and handle,in goroutine to avoid business logic block closeChan and heartBeatTicker
@@ -85,11 +138,18 @@ func (c *Client) Listening() error {
 	for {
 		select {
 		case <-resumeSignal: // 使用信号量控制连接立即重连
-			log.Infof("%s, received resumeSignal signal", c.session)
+			log.Infof("%s, received resumeSignal signal", c.sessionSnapshot())
 			return errs.ErrNeedReConnect
 		case err := <-c.closeChan:
+			c.setCloseError(err)
+			if c.isShuttingDown() {
+				// 等待已经接收的事件处理完成
+				<-c.stopped
+				log.Infof("%s Listening stop by shutdown, close reason: %v", c.sessionSnapshot(), err)
+				return nil
+			}
 			// 关闭连接的错误码 https://bot.q.qq.com/wiki/develop/api/gateway/error/error.html
-			log.Errorf("%s Listening stop. err is %v", c.session, err)
+			log.Errorf("%s Listening stop. err is %v", c.sessionSnapshot(), err)
 			// 不能够 identify 的错误
 			if wss.IsCloseError(err, errs.WSCodeBackendBotOffline, errs.WSCodeBackendBotBanned) {
 				err = errs.New(errs.CodeConnCloseCantIdentify, err.Error())
@@ -103,18 +163,24 @@ func (c *Client) Listening() error {
 			if wss.IsUnexpectedCloseError(err, errs.WSCodeBackendSessionTimeOut) {
 				err = errs.New(errs.CodeConnCloseCantResume, err.Error())
 			}
-			if event.DefaultHandlers.ErrorNotify != nil {
+			if h := c.dispatcher.Handlers(); h.ErrorNotify != nil {
 				// 通知到使用方错误
-				event.DefaultHandlers.ErrorNotify(err)
+				h.ErrorNotify(err)
 			}
 			return err
 		case <-c.heartBeatTicker.C:
-			log.Debugf("%s listened heartBeat", c.session)
+			log.Debugf("%s listened heartBeat", c.sessionSnapshot())
+			if c.heartbeatTimeout() {
+				// 连接可能已经半开，主动关闭，交给 closeChan 统一处理
+				log.Errorf("%s heartbeat ack timeout, close and resume", c.sessionSnapshot())
+				c.closeChan <- errs.ErrHeartbeatTimeout
+				continue
+			}
 			heartBeatEvent := &dto.WSPayload{
 				WSPayloadBase: dto.WSPayloadBase{
 					OPCode: dto.WSHeartbeat,
 				},
-				Data: c.session.LastSeq,
+				Data: c.lastSeq(),
 			}
 			// 不处理错误，Write 内部会处理，如果发生发包异常，会通知主协程退出
 			_ = c.Write(heartBeatEvent)
@@ -125,10 +191,10 @@ func (c *Client) Listening() error {
 // Write 往 ws 写入数据
 func (c *Client) Write(message *dto.WSPayload) error {
 	m, _ := json.Marshal(message)
-	log.Infof("%s write %s message, %v", c.session, dto.OPMeans(message.OPCode), string(m))
+	log.Infof("%s write %s message, %v", c.sessionSnapshot(), dto.OPMeans(message.OPCode), string(m))
 
 	if err := c.conn.WriteMessage(wss.TextMessage, m); err != nil {
-		log.Errorf("%s WriteMessage failed, %v", c.session, err)
+		log.Errorf("%s WriteMessage failed, %v", c.sessionSnapshot(), err)
 		c.closeChan <- err
 		return err
 	}
@@ -142,11 +208,12 @@ func (c *Client) Resume() error {
 		log.Errorf("[resume] get access token failed:%s", err)
 		return err
 	}
+	session := c.sessionSnapshot()
 	payload := &dto.WSPayload{
 		Data: &dto.WSResumeData{
 			Token:     token.AccessToken,
-			SessionID: c.session.ID,
-			Seq:       c.session.LastSeq,
+			SessionID: session.ID,
+			Seq:       session.LastSeq,
 		},
 	}
 	payload.OPCode = dto.WSResume // 内嵌结构体字段，单独赋值
@@ -156,10 +223,13 @@ func (c *Client) Resume() error {
 // Identify 对一个连接进行鉴权，并声明监听的 shard 信息
 func (c *Client) Identify() error {
 	// 避免传错 intent
+	c.mu.Lock()
 	if c.session.Intent == 0 {
 		c.session.Intent = dto.IntentGuilds
 	}
-	tk, err := c.session.TokenSource.Token()
+	c.mu.Unlock()
+	session := c.sessionSnapshot()
+	tk, err := session.TokenSource.Token()
 	if err != nil {
 		log.Errorf("[resume] get access token failed:%s", err)
 		return err
@@ -167,10 +237,10 @@ func (c *Client) Identify() error {
 	payload := &dto.WSPayload{
 		Data: &dto.WSIdentityData{
 			Token:   fmt.Sprintf("%s %s", tk.TokenType, tk.AccessToken),
-			Intents: c.session.Intent,
+			Intents: session.Intent,
 			Shard: []uint32{
-				c.session.Shards.ShardID,
-				c.session.Shards.ShardCount,
+				session.Shards.ShardID,
+				session.Shards.ShardCount,
 			},
 		},
 	}
@@ -181,21 +251,151 @@ func (c *Client) Identify() error {
 // Close 关闭连接
 func (c *Client) Close() {
 	if err := c.conn.Close(); err != nil {
-		log.Errorf("%s, close conn err: %v", c.session, err)
+		log.Errorf("%s, close conn err: %v", c.sessionSnapshot(), err)
 	}
 	c.heartBeatTicker.Stop()
 }
 
-// Session 获取client的session信息
+// Shutdown 优雅关闭连接，不再接收新的事件，等待已经接收的事件处理完成，之后 Listening 返回 nil
+// 未处理的事件不会更新 LastSeq，使用 session 进行 resume 时会重新下发；ctx 结束时直接关闭连接
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() {
+		close(c.shutdown)
+		if c.conn == nil {
+			return
+		}
+		// 发送正常关闭帧，网关回复关闭帧后，读取协程退出，处理完队列中的事件后停止
+		msg := wss.FormatCloseMessage(wss.CloseNormalClosure, "shutdown")
+		if err := c.conn.WriteControl(wss.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+			log.Errorf("%s write close message failed, %v", c.sessionSnapshot(), err)
+			_ = c.conn.Close()
+		}
+	})
+	select {
+	case <-c.stopped:
+		return nil
+	case <-ctx.Done():
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+		return ctx.Err()
+	}
+}
+
+func (c *Client) isShuttingDown() bool {
+	select {
+	case <-c.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Status 返回连接的运行状态，可以在其他协程中调用
+func (c *Client) Status() websocket.Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.status
+	st.SessionID = c.session.ID
+	st.ShardID = c.session.Shards.ShardID
+	st.ShardCount = c.session.Shards.ShardCount
+	st.LastSeq = c.session.LastSeq
+	st.HandledSeq = c.handledSeq()
+	st.QueueDepth = c.QueueDepth()
+	st.SpilledEvents = c.spill.len()
+	st.DroppedEvents = atomic.LoadInt64(&c.dropped)
+	return st
+}
+
+func (c *Client) lastSeq() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session.LastSeq
+}
+
+// heartbeatTimeout 发送心跳前检查上一次心跳是否收到 ack，连续没有收到 ack 的次数达到上限时返回 true
+func (c *Client) heartbeatTimeout() bool {
+	limit := c.opts.heartbeatMisses
+	if limit == 0 {
+		limit = DefaultHeartbeatMissLimit
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 读取协程等待队列空位时无法读取 ack，不计入超时，避免重连健康的连接
+	if !c.heartbeatSentAt.IsZero() && atomic.LoadInt32(&c.stalled) == 0 {
+		c.status.MissedHeartbeats++
+	}
+	c.heartbeatSentAt = time.Now()
+	return limit > 0 && c.status.MissedHeartbeats >= limit
+}
+
+// heartbeatAck 记录心跳 ack，并回调 HeartbeatHandler
+func (c *Client) heartbeatAck() {
+	now := time.Now()
+	c.mu.Lock()
+	var latency time.Duration
+	if !c.heartbeatSentAt.IsZero() {
+		latency = now.Sub(c.heartbeatSentAt)
+	}
+	c.heartbeatSentAt = time.Time{}
+	c.status.LastHeartbeatAck = now
+	c.status.HeartbeatLatency = latency
+	c.status.MissedHeartbeats = 0
+	c.mu.Unlock()
+	log.Debugf("%s heartbeat ack, latency %s", c.sessionSnapshot(), latency)
+	if h := c.dispatcher.Handlers(); h.Heartbeat != nil {
+		h.Heartbeat(c.sessionSnapshot(), latency)
+	}
+}
+
+// setCloseError 记录连接关闭的原因，优先使用 websocket close code
+func (c *Client) setCloseError(err error) {
+	code := errs.Error(err).Code()
+	var closeErr *wss.CloseError
+	if errors.As(err, &closeErr) {
+		code = closeErr.Code
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.Ready = false
+	c.status.CloseCode = code
+	c.status.CloseError = err.Error()
+}
+
+func (c *Client) setReady() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.Ready = true
+	c.status.ReadyAt = time.Now()
+}
+
+// QueueDepth 等待处理的事件数，包括接收队列、磁盘队列以及各个 worker 队列中的事件
+func (c *Client) QueueDepth() int {
+	n := len(c.messageQueue) + c.spill.len()
+	if c.pool != nil {
+		n += c.pool.depth()
+	}
+	return n
+}
+
+// Session 获取client的session信息，返回的是副本，修改不会影响连接
 func (c *Client) Session() *dto.Session {
-	return c.session
+	return c.sessionSnapshot()
+}
+
+// sessionSnapshot 在锁内复制 session，用于日志以及交给其他协程使用
+func (c *Client) sessionSnapshot() *dto.Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	session := *c.session
+	return &session
 }
 
 func (c *Client) readMessageToQueue() {
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
-			log.Errorf("%s read message failed, %v, message %s", c.session, err, string(message))
+			log.Errorf("%s read message failed, %v, message %s", c.sessionSnapshot(), err, string(message))
 			close(c.messageQueue)
 			// accessToken过期
 			if wss.IsCloseError(err, errs.WSCodeBackendAuthenticationFail) {
@@ -204,49 +404,213 @@ func (c *Client) readMessageToQueue() {
 			c.closeChan <- err
 			return
 		}
+		c.record(message)
 		payload := &dto.WSPayload{}
 		if err := json.Unmarshal(message, payload); err != nil {
-			log.Errorf("%s json failed, %v", c.session, err)
+			log.Errorf("%s json failed, %v", c.sessionSnapshot(), err)
 			continue
 		}
 		payload.RawMessage = message
-		payload.Session = c.session
-		log.Infof("%s receive %s message, %s", c.session, dto.OPMeans(payload.OPCode), string(message))
+		payload.Session = c.sessionSnapshot()
+		log.Infof("%s receive %s message, %s", c.sessionSnapshot(), dto.OPMeans(payload.OPCode), string(message))
 		// 处理内置的一些事件，如果处理成功，则这个事件不再投递给业务
 		if c.isHandleBuildIn(payload) {
 			continue
 		}
-		c.messageQueue <- payload
+		if c.isShuttingDown() {
+			log.Infof("%s is shutting down, drop %s message, seq %d", c.sessionSnapshot(), payload.Type, payload.Seq)
+			continue
+		}
+		c.enqueue(payload)
+	}
+}
+
+// record 录制收到的原始数据
+func (c *Client) record(message []byte) {
+	if c.opts.recorder == nil {
+		return
+	}
+	rec := record.New(record.SourceWebsocket, c.sessionSnapshot(), message)
+	if err := c.opts.recorder.Record(rec); err != nil {
+		log.Errorf("%s record message failed, %v", c.sessionSnapshot(), err)
+	}
+}
+
+// enqueue 按照 backpressure 策略把事件放入接收队列
+func (c *Client) enqueue(payload *dto.WSPayload) {
+	switch c.opts.backpressure {
+	case BackpressureDropOldest:
+		for {
+			select {
+			case c.messageQueue <- payload:
+				return
+			default:
+			}
+			select {
+			case old := <-c.messageQueue:
+				atomic.AddInt64(&c.dropped, 1)
+				log.Warnf("%s message queue is full, drop %s message, seq %d", c.sessionSnapshot(), old.Type, old.Seq)
+			default:
+			}
+		}
+	case BackpressureSpill:
+		// 磁盘队列中还有事件时，新的事件也需要写入磁盘队列，保证顺序
+		if c.spill.len() == 0 {
+			select {
+			case c.messageQueue <- payload:
+				return
+			default:
+			}
+		}
+		if err := c.spill.push(payload.RawMessage); err != nil {
+			log.Errorf("%s spill message failed, %v, wait for queue", c.sessionSnapshot(), err)
+			c.wait(payload)
+		}
+	default:
+		c.wait(payload)
+	}
+}
+
+// wait 等待接收队列有空位，等待期间暂停心跳超时检查
+func (c *Client) wait(payload *dto.WSPayload) {
+	select {
+	case c.messageQueue <- payload:
+		return
+	default:
+	}
+	atomic.StoreInt32(&c.stalled, 1)
+	defer atomic.StoreInt32(&c.stalled, 0)
+	c.messageQueue <- payload
+}
+
+// next 取出下一个需要处理的事件，接收队列中的事件总是早于磁盘队列中的事件，接收队列关闭并且全部处理完成后返回 false
+func (c *Client) next() (*dto.WSPayload, bool) {
+	for {
+		select {
+		case payload, ok := <-c.messageQueue:
+			if ok {
+				return payload, true
+			}
+			return c.unspill()
+		default:
+		}
+		if payload, ok := c.unspill(); ok {
+			return payload, true
+		}
+		select {
+		case payload, ok := <-c.messageQueue:
+			if ok {
+				return payload, true
+			}
+			return c.unspill()
+		case <-c.spill.wait():
+		}
+	}
+}
+
+// unspill 从磁盘队列读回一个事件
+func (c *Client) unspill() (*dto.WSPayload, bool) {
+	for {
+		data, err := c.spill.pop()
+		if err != nil {
+			lost := c.spill.close()
+			atomic.AddInt64(&c.dropped, int64(lost))
+			log.Errorf("%s read spilled message failed, %v, %d messages lost", c.sessionSnapshot(), err, lost)
+			return nil, false
+		}
+		if data == nil {
+			return nil, false
+		}
+		payload := &dto.WSPayload{}
+		if err := json.Unmarshal(data, payload); err != nil {
+			log.Errorf("%s json failed, %v", c.sessionSnapshot(), err)
+			continue
+		}
+		payload.RawMessage = data
+		payload.Session = c.sessionSnapshot()
+		return payload, true
 	}
 }
 
 func (c *Client) listenMessageAndHandle() {
+	// 按 key 分发到 worker 并发处理，避免单个耗时的 handler 阻塞整个分片；seq 与 ready 事件仍在这里按顺序处理
+	defer close(c.stopped)
+	c.pool.start()
+	defer c.pool.stop()
 	defer func() {
-		// panic，一般是由于业务自己实现的 handle 不完善导致
-		// 打印日志后，关闭这个连接，进入重连流程
+		// ready 回调中的 panic，打印日志后，关闭这个连接，进入重连流程
 		if err := recover(); err != nil {
-			websocket.PanicHandler(err, c.session)
+			websocket.PanicHandler(err, c.sessionSnapshot())
 			c.closeChan <- fmt.Errorf("panic: %v", err)
 		}
 	}()
-	for payload := range c.messageQueue {
+	defer func() {
+		if lost := c.spill.close(); lost > 0 {
+			atomic.AddInt64(&c.dropped, int64(lost))
+		}
+	}()
+	for {
+		payload, ok := c.next()
+		if !ok {
+			break
+		}
 		c.saveSeq(payload.Seq)
 		// ready 事件需要特殊处理
 		if payload.Type == "READY" {
 			c.readyHandler(payload)
+			c.setReady()
 			continue
 		}
-		// 解析具体事件，并投递给业务注册的 handler
-		if err := event.ParseAndHandle(payload); err != nil {
-			log.Errorf("%s parseAndHandle failed, %v", c.session, err)
+		if payload.Type == "RESUMED" {
+			c.setReady()
+		}
+		c.track(payload.Seq)
+		c.pool.dispatch(payload)
+	}
+	log.Infof("%s message queue is closed", c.sessionSnapshot())
+}
+
+// handle 解析具体事件，并投递给业务注册的 handler
+func (c *Client) handle(payload *dto.WSPayload) {
+	defer c.untrack(payload.Seq)
+	if err := c.dispatcher.ParseAndHandle(payload); err != nil {
+		log.Errorf("%s parseAndHandle failed, %v", c.sessionSnapshot(), err)
+	}
+}
+
+// track 记录投递给 worker 的事件，handler 返回之前续传信息不会越过这个 seq
+func (c *Client) track(seq uint32) {
+	if seq > 0 {
+		c.mu.Lock()
+		c.inflight[seq] = struct{}{}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Client) untrack(seq uint32) {
+	if seq > 0 {
+		c.mu.Lock()
+		delete(c.inflight, seq)
+		c.mu.Unlock()
+	}
+}
+
+// handledSeq 返回该 seq 及之前的事件都已经处理完成的 seq，需要持有 c.mu
+func (c *Client) handledSeq() uint32 {
+	seq := c.session.LastSeq
+	for s := range c.inflight {
+		if s-1 < seq {
+			seq = s - 1
 		}
 	}
-	log.Infof("%s message queue is closed", c.session)
+	return seq
 }
 
 func (c *Client) saveSeq(seq uint32) {
 	if seq > 0 {
+		c.mu.Lock()
 		c.session.LastSeq = seq
+		c.mu.Unlock()
 	}
 }
 
@@ -256,7 +620,8 @@ func (c *Client) isHandleBuildIn(payload *dto.WSPayload) bool {
 	switch payload.OPCode {
 	case dto.WSHello: // 接收到 hello 后需要开始发心跳
 		c.startHeartBeatTicker(payload.RawMessage)
-	case dto.WSHeartbeatAck: // 心跳 ack 不需要业务处理
+	case dto.WSHeartbeatAck: // 心跳 ack 不需要业务处理，只记录心跳耗时
+		c.heartbeatAck()
 	case dto.WSReconnect: // 达到连接时长，需要重新连接，此时可以通过 resume 续传原连接上的事件
 		c.closeChan <- errs.ErrNeedReConnect
 	case dto.WSInvalidSession: // 无效的 sessionLog，需要重新鉴权
@@ -271,7 +636,7 @@ func (c *Client) isHandleBuildIn(payload *dto.WSPayload) bool {
 func (c *Client) startHeartBeatTicker(message []byte) {
 	helloData := &dto.WSHelloData{}
 	if err := event.ParseData(message, helloData); err != nil {
-		log.Errorf("%s hello data parse failed, %v, message %v", c.session, err, message)
+		log.Errorf("%s hello data parse failed, %v, message %v", c.sessionSnapshot(), err, message)
 	}
 	// 根据 hello 的回包，重新设置心跳的定时器时间
 	c.heartBeatTicker.Reset(time.Duration(helloData.HeartbeatInterval) * time.Millisecond)
@@ -281,20 +646,22 @@ func (c *Client) startHeartBeatTicker(message []byte) {
 func (c *Client) readyHandler(payload *dto.WSPayload) {
 	readyData := &dto.WSReadyData{}
 	if err := event.ParseData(payload.RawMessage, readyData); err != nil {
-		log.Errorf("%s parseReadyData failed, %v, message %v", c.session, err, payload.RawMessage)
+		log.Errorf("%s parseReadyData failed, %v, message %v", c.sessionSnapshot(), err, payload.RawMessage)
 	}
 	c.version = readyData.Version
 	// 基于 ready 事件，更新 session 信息
+	c.mu.Lock()
 	c.session.ID = readyData.SessionID
 	c.session.Shards.ShardID = readyData.Shard[0]
 	c.session.Shards.ShardCount = readyData.Shard[1]
+	c.mu.Unlock()
 	c.user = &dto.WSUser{
 		ID:       readyData.User.ID,
 		Username: readyData.User.Username,
 		Bot:      readyData.User.Bot,
 	}
 	// 调用自定义的 ready 回调
-	if event.DefaultHandlers.Ready != nil {
-		event.DefaultHandlers.Ready(payload, readyData)
+	if h := c.dispatcher.Handlers(); h.Ready != nil {
+		h.Ready(payload, readyData)
 	}
 }
@@ -0,0 +1,433 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/errs"
+	"github.com/tencent-connect/botgo/event"
+	"github.com/tencent-connect/botgo/event/record"
+	"github.com/tencent-connect/botgo/sessions/manager"
+	"github.com/tencent-connect/botgo/websocket/websockettest"
+)
+
+const waitTimeout = 3 * time.Second
+
+// listen 使用 d 分发事件，建立连接并完成鉴权或者 resume，返回 Listening 的结果
+// 结果在已接收的事件全部处理完成后返回，避免测试结束后仍有 handler 在运行
+func listen(t *testing.T, d *event.Dispatcher, session dto.Session, opts ...Option) (*Client, <-chan error) {
+	proto := &Client{}
+	for _, opt := range opts {
+		opt(&proto.opts)
+	}
+	c := proto.New(session).(*Client)
+	c.SetDispatcher(d)
+	require.NoError(t, c.Connect())
+	if session.ID != "" {
+		require.NoError(t, c.Resume())
+	} else {
+		require.NoError(t, c.Identify())
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := c.Listening()
+		<-c.stopped
+		done <- err
+	}()
+	return c, done
+}
+
+func newSession(gw *websockettest.Gateway) dto.Session {
+	return dto.Session{
+		URL:         gw.URL,
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token", TokenType: "QQBot"}),
+		Intent:      dto.IntentGuildAtMessage,
+		Shards:      dto.ShardConfig{ShardID: 0, ShardCount: 1},
+	}
+}
+
+func waitErr(t *testing.T, done <-chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(waitTimeout):
+		t.Fatal("listening not stopped")
+	}
+	return nil
+}
+
+func TestClient_ReadyAndDispatch(t *testing.T) {
+	d := event.NewDispatcher()
+	gw := websockettest.NewGateway(websockettest.WithHeartbeatInterval(50 * time.Millisecond))
+	defer gw.Close()
+
+	ready := make(chan *dto.WSReadyData, 1)
+	messages := make(chan *dto.WSATMessageData, 1)
+	d.RegisterHandlers(
+		event.ReadyHandler(func(_ *dto.WSPayload, data *dto.WSReadyData) { ready <- data }),
+		event.ATMessageEventHandler(func(_ *dto.WSPayload, data *dto.WSATMessageData) error {
+			messages <- data
+			return nil
+		}),
+	)
+	c, done := listen(t, d, newSession(gw))
+
+	identify, err := gw.WaitFor(dto.WSIdentity, 0, waitTimeout)
+	require.NoError(t, err)
+	assert.Contains(t, string(identify.RawMessage), "QQBot token")
+
+	var readyData *dto.WSReadyData
+	select {
+	case readyData = <-ready:
+	case <-time.After(waitTimeout):
+		t.Fatal("ready not received")
+	}
+	assert.Equal(t, gw.Conns()[0].SessionID(), readyData.SessionID)
+	assert.Equal(t, []uint32{0, 1}, readyData.Shard)
+	assert.Equal(t, dto.IntentGuildAtMessage, gw.Conns()[0].Intents())
+
+	assert.Equal(t, 1, gw.Dispatch(dto.EventAtMessageCreate, &dto.WSATMessageData{Content: "hello"}))
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "hello", msg.Content)
+	case <-time.After(waitTimeout):
+		t.Fatal("message not received")
+	}
+
+	_, err = gw.WaitFor(dto.WSHeartbeat, 1, waitTimeout)
+	assert.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return !c.Status().LastHeartbeatAck.IsZero()
+	}, waitTimeout, 10*time.Millisecond)
+	status := c.Status()
+	assert.True(t, status.Ready)
+	assert.Equal(t, readyData.SessionID, status.SessionID)
+	assert.Equal(t, uint32(1), status.ShardCount)
+	assert.NotZero(t, status.LastSeq)
+
+	gw.Reconnect()
+	assert.Equal(t, errs.ErrNeedReConnect, waitErr(t, done))
+	status = c.Status()
+	assert.False(t, status.Ready)
+	assert.Equal(t, errs.CodeNeedReConnect, status.CloseCode)
+}
+
+func TestClient_CloseErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		close          func(gw *websockettest.Gateway)
+		canNotResume   bool
+		canNotIdentify bool
+	}{
+		{"reconnect", func(gw *websockettest.Gateway) { gw.Reconnect() }, false, false},
+		{"invalid session", func(gw *websockettest.Gateway) { gw.InvalidSession() }, true, false},
+		{"session timeout", func(gw *websockettest.Gateway) {
+			gw.CloseWith(errs.WSCodeBackendSessionTimeOut, "timeout")
+		}, false, false},
+		{"session no longer valid", func(gw *websockettest.Gateway) {
+			gw.CloseWith(errs.WSCodeBackendSessionNoLongerValid, "invalid")
+		}, true, false},
+		{"bot banned", func(gw *websockettest.Gateway) {
+			gw.CloseWith(errs.WSCodeBackendBotBanned, "banned")
+		}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := event.NewDispatcher()
+			gw := websockettest.NewGateway()
+			defer gw.Close()
+			_, done := listen(t, d, newSession(gw))
+			require.NoError(t, gw.WaitReady(1, waitTimeout))
+
+			tt.close(gw)
+			err := waitErr(t, done)
+			require.Error(t, err)
+			assert.Equal(t, tt.canNotResume, manager.CanNotResume(err))
+			assert.Equal(t, tt.canNotIdentify, manager.CanNotIdentify(err))
+		})
+	}
+}
+
+func TestClient_Resume(t *testing.T) {
+	d := event.NewDispatcher()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+
+	messages := make(chan *dto.WSPayload, 10)
+	d.RegisterHandlers(event.ATMessageEventHandler(func(p *dto.WSPayload, _ *dto.WSATMessageData) error {
+		messages <- p
+		return nil
+	}))
+	c, done := listen(t, d, newSession(gw))
+	require.NoError(t, gw.WaitReady(1, waitTimeout))
+	gw.Dispatch(dto.EventAtMessageCreate, &dto.WSATMessageData{Content: "first"})
+	first := <-messages
+	gw.Reconnect()
+	assert.Equal(t, errs.ErrNeedReConnect, waitErr(t, done))
+	session := *c.Session()
+	assert.Equal(t, first.Seq, session.LastSeq)
+	require.NotEmpty(t, session.ID)
+
+	// 客户端离线期间的事件，需要在 resume 之后补发
+	require.Eventually(t, func() bool { return len(gw.Conns()) == 0 }, waitTimeout, 10*time.Millisecond)
+	require.NoError(t, gw.DispatchTo(session.ID, dto.EventAtMessageCreate, &dto.WSATMessageData{Content: "missed"}))
+	_, done = listen(t, d, session)
+	select {
+	case p := <-messages:
+		assert.Equal(t, first.Seq+1, p.Seq)
+		assert.Contains(t, string(p.RawMessage), "missed")
+	case <-time.After(waitTimeout):
+		t.Fatal("missed message not replayed")
+	}
+	_, err := gw.WaitFor(dto.WSResume, 0, waitTimeout)
+	require.NoError(t, err)
+
+	// 网关侧 session 失效后，resume 会收到 invalid session，不能再 resume
+	gw.DropSession(session.ID)
+	gw.Reconnect()
+	assert.Equal(t, errs.ErrNeedReConnect, waitErr(t, done))
+	_, done = listen(t, d, session)
+	assert.True(t, manager.CanNotResume(waitErr(t, done)))
+}
+
+func TestClient_Workers(t *testing.T) {
+	d := event.NewDispatcher()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+
+	block := make(chan struct{})
+	handled := make(chan string, 10)
+	d.RegisterHandlers(event.GroupATMessageEventHandler(
+		func(_ *dto.WSPayload, data *dto.WSGroupATMessageData) error {
+			switch data.Content {
+			case "slow":
+				<-block
+			case "panic":
+				panic("boom")
+			}
+			handled <- data.GroupID + ":" + data.Content
+			return nil
+		}))
+	keyFunc := func(p *dto.WSPayload) string {
+		// 固定分配到不同的 worker，避免 hash 碰撞导致测试不稳定
+		if DefaultKey(p) == "g1" {
+			return "a"
+		}
+		return "b"
+	}
+	c, done := listen(t, d, newSession(gw), WithWorkers(2), WithKeyFunc(keyFunc))
+	require.NoError(t, gw.WaitReady(1, waitTimeout))
+
+	gw.Dispatch(dto.EventGroupAtMessageCreate, &dto.WSGroupATMessageData{GroupID: "g1", Content: "slow"})
+	gw.Dispatch(dto.EventGroupAtMessageCreate, &dto.WSGroupATMessageData{GroupID: "g1", Content: "next"})
+	gw.Dispatch(dto.EventGroupAtMessageCreate, &dto.WSGroupATMessageData{GroupID: "g2", Content: "panic"})
+	gw.Dispatch(dto.EventGroupAtMessageCreate, &dto.WSGroupATMessageData{GroupID: "g2", Content: "fast"})
+
+	// g1 被阻塞时不影响 g2，g2 中的 panic 也不会断开连接
+	select {
+	case got := <-handled:
+		assert.Equal(t, "g2:fast", got)
+	case <-time.After(waitTimeout):
+		t.Fatal("g2 blocked by g1")
+	}
+	require.Eventually(t, func() bool { return c.QueueDepth() == 1 }, waitTimeout, 10*time.Millisecond)
+	// 阻塞中的事件没有处理完成，续传信息不能越过它
+	status := c.Status()
+	assert.Equal(t, status.LastSeq-4, status.HandledSeq)
+
+	// 相同 key 的事件保持顺序
+	close(block)
+	assert.Equal(t, "g1:slow", <-handled)
+	assert.Equal(t, "g1:next", <-handled)
+	assert.Equal(t, 0, c.QueueDepth())
+	require.Eventually(t, func() bool {
+		status := c.Status()
+		return status.HandledSeq == status.LastSeq
+	}, waitTimeout, 10*time.Millisecond)
+	assert.Equal(t, 1, len(gw.Conns()))
+
+	gw.Reconnect()
+	assert.Equal(t, errs.ErrNeedReConnect, waitErr(t, done))
+}
+
+func TestClient_Heartbeat(t *testing.T) {
+	d := event.NewDispatcher()
+	gw := websockettest.NewGateway(websockettest.WithHeartbeatInterval(20 * time.Millisecond))
+	defer gw.Close()
+	latencies := make(chan time.Duration, 10)
+	d.RegisterHandlers(event.HeartbeatHandler(func(_ *dto.Session, latency time.Duration) {
+		select {
+		case latencies <- latency:
+		default:
+		}
+	}))
+	c, done := listen(t, d, newSession(gw))
+	select {
+	case latency := <-latencies:
+		assert.True(t, latency > 0)
+	case <-time.After(waitTimeout):
+		t.Fatal("heartbeat ack not received")
+	}
+	assert.Equal(t, 0, c.Status().MissedHeartbeats)
+	gw.Reconnect()
+	waitErr(t, done)
+
+	// 网关不再回复 ack，连接被认为已经断开，返回可以 resume 的错误
+	silent := websockettest.NewGateway(
+		websockettest.WithHeartbeatInterval(20*time.Millisecond), websockettest.WithoutHeartbeatAck())
+	defer silent.Close()
+	c, done = listen(t, d, newSession(silent), WithHeartbeatMissLimit(2))
+	err := waitErr(t, done)
+	assert.Equal(t, errs.ErrHeartbeatTimeout, err)
+	assert.False(t, manager.CanNotResume(err))
+	assert.Equal(t, 2, c.Status().MissedHeartbeats)
+	assert.Equal(t, errs.CodeHeartbeatTimeout, c.Status().CloseCode)
+}
+
+func TestClient_HeartbeatStalled(t *testing.T) {
+	gw := websockettest.NewGateway(websockettest.WithHeartbeatInterval(20 * time.Millisecond))
+	defer gw.Close()
+	d := event.NewDispatcher()
+	block := make(chan struct{})
+	d.RegisterHandlers(event.GroupATMessageEventHandler(func(_ *dto.WSPayload, _ *dto.WSGroupATMessageData) error {
+		<-block
+		return nil
+	}))
+	c, done := listen(t, d, newSession(gw), WithQueueSize(1), WithWorkerQueueSize(1), WithHeartbeatMissLimit(1))
+	require.NoError(t, gw.WaitReady(1, waitTimeout))
+	for i := 0; i < 6; i++ {
+		gw.Dispatch(dto.EventGroupAtMessageCreate, &dto.WSGroupATMessageData{GroupID: "g1"})
+	}
+
+	// 读取协程等待队列空位期间无法读取心跳 ack，不会被当作心跳超时断开
+	time.Sleep(200 * time.Millisecond)
+	close(block)
+	require.Eventually(t, func() bool { return c.QueueDepth() == 0 }, waitTimeout, 10*time.Millisecond)
+	status := c.Status()
+	assert.True(t, status.Ready)
+	assert.Zero(t, status.CloseCode)
+	gw.Reconnect()
+	assert.Equal(t, errs.ErrNeedReConnect, waitErr(t, done))
+
+	// 没有建立连接时也可以调用 Shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, (&Client{}).New(newSession(gw)).(*Client).Shutdown(ctx))
+}
+
+func TestClient_Backpressure(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		handled []string
+		dropped int64
+		spilled int
+	}{
+		{name: "block", handled: []string{"0", "1", "2", "3", "4", "5"}},
+		{
+			name:    "drop oldest",
+			opts:    []Option{WithBackpressure(BackpressureDropOldest)},
+			handled: []string{"0", "1", "2", "5"},
+			dropped: 2,
+		},
+		{
+			name:    "spill",
+			opts:    []Option{WithBackpressure(BackpressureSpill), WithSpillDir(t.TempDir())},
+			handled: []string{"0", "1", "2", "3", "4", "5"},
+			spilled: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := event.NewDispatcher()
+			gw := websockettest.NewGateway()
+			defer gw.Close()
+
+			block := make(chan struct{})
+			handled := make(chan string, 10)
+			d.RegisterHandlers(event.GroupATMessageEventHandler(
+				func(_ *dto.WSPayload, data *dto.WSGroupATMessageData) error {
+					if data.Content == "0" {
+						<-block
+					}
+					handled <- data.Content
+					return nil
+				}))
+			// 处理中、worker 队列、等待分发、接收队列各容纳一个事件
+			opts := append([]Option{WithQueueSize(1), WithWorkerQueueSize(1)}, tt.opts...)
+			c, done := listen(t, d, newSession(gw), opts...)
+			require.NoError(t, gw.WaitReady(1, waitTimeout))
+			require.Eventually(t, func() bool { return c.Status().Ready }, waitTimeout, time.Millisecond)
+			seq := c.Status().LastSeq
+			for i := 0; i < 6; i++ {
+				gw.Dispatch(dto.EventGroupAtMessageCreate,
+					&dto.WSGroupATMessageData{GroupID: "g1", Content: strconv.Itoa(i)})
+				if i < 3 {
+					// 等待事件离开接收队列，之后的事件才会触发 backpressure
+					seq++
+					require.Eventually(t, func() bool { return c.Status().LastSeq == seq },
+						waitTimeout, time.Millisecond)
+				}
+			}
+			require.Eventually(t, func() bool {
+				st := c.Status()
+				if tt.dropped > 0 || tt.spilled > 0 {
+					return st.DroppedEvents == tt.dropped && st.SpilledEvents == tt.spilled
+				}
+				return st.QueueDepth == 2
+			}, waitTimeout, 10*time.Millisecond)
+
+			close(block)
+			for _, want := range tt.handled {
+				select {
+				case got := <-handled:
+					assert.Equal(t, want, got)
+				case <-time.After(waitTimeout):
+					t.Fatalf("event %s not handled", want)
+				}
+			}
+			assert.Equal(t, 0, c.QueueDepth())
+			assert.Equal(t, tt.dropped, c.Status().DroppedEvents)
+
+			gw.Reconnect()
+			assert.Equal(t, errs.ErrNeedReConnect, waitErr(t, done))
+		})
+	}
+}
+
+func TestClient_Record(t *testing.T) {
+	d := event.NewDispatcher()
+	gw := websockettest.NewGateway()
+	defer gw.Close()
+	var buf bytes.Buffer
+	rec := record.NewRecorder(&buf)
+	c, done := listen(t, d, newSession(gw), WithRecorder(rec))
+	require.NoError(t, gw.WaitReady(1, waitTimeout))
+	gw.Dispatch(dto.EventGroupAtMessageCreate, &dto.WSGroupATMessageData{GroupID: "g1", Content: "hello"})
+	require.Eventually(t, func() bool { return c.Status().LastSeq == 2 }, waitTimeout, 10*time.Millisecond)
+	gw.Reconnect()
+	waitErr(t, done)
+	require.NoError(t, rec.Close())
+
+	// 录制的事件可以在本地重放
+	handled := make(chan string, 1)
+	replay := event.NewDispatcher()
+	replay.RegisterHandlers(event.GroupATMessageEventHandler(
+		func(p *dto.WSPayload, data *dto.WSGroupATMessageData) error {
+			assert.Equal(t, uint32(1), p.Session.Shards.ShardCount)
+			handled <- data.Content
+			return nil
+		}))
+	n, err := record.Replay(context.Background(), &buf, replay, record.WithSpeed(0))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n) // READY 与消息事件
+	assert.Equal(t, "hello", <-handled)
+}
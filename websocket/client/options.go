@@ -0,0 +1,165 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+
+	wss "github.com/gorilla/websocket"
+
+	"github.com/tencent-connect/botgo/event/record"
+)
+
+// Backpressure 接收队列已满时的处理策略
+type Backpressure int
+
+// 接收队列已满时的处理策略
+const (
+	// BackpressureBlock 暂停读取连接，直到队列有空位，默认策略
+	// 暂停期间无法读取心跳 ack，不进行心跳超时检查
+	BackpressureBlock Backpressure = iota
+	// BackpressureDropOldest 丢弃队列中最早的事件，丢弃的数量记录在 Status().DroppedEvents
+	BackpressureDropOldest
+	// BackpressureSpill 写入磁盘上的临时文件，队列有空位后按顺序读回，不丢失事件
+	BackpressureSpill
+)
+
+// Option websocket client 的配置项，通过 Setup 设置，对之后创建的所有连接生效
+type Option func(o *options)
+
+type options struct {
+	workers         int
+	workerQueueSize int
+	keyFunc         KeyFunc
+	heartbeatMisses int
+
+	dialer           *wss.Dialer
+	proxy            func(*http.Request) (*url.URL, error)
+	tlsConfig        *tls.Config
+	header           http.Header
+	handshakeTimeout time.Duration
+	readLimit        int64
+	queueSize        int
+	backpressure     Backpressure
+	spillDir         string
+	recorder         *record.Recorder
+}
+
+// newDialer 基于 WithDialer 或者 websocket.DefaultDialer，应用其他连接相关的配置
+func (o *options) newDialer() *wss.Dialer {
+	d := *wss.DefaultDialer
+	if o.dialer != nil {
+		d = *o.dialer
+	}
+	if o.proxy != nil {
+		d.Proxy = o.proxy
+	}
+	if o.tlsConfig != nil {
+		d.TLSClientConfig = o.tlsConfig
+	}
+	if o.handshakeTimeout > 0 {
+		d.HandshakeTimeout = o.handshakeTimeout
+	}
+	return &d
+}
+
+// WithWorkers 设置并发处理事件的 worker 数量，默认为 1，即所有事件串行处理
+// 事件按照 key 分配到 worker，相同 key 的事件保持顺序，不同 key 的事件并发处理
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithWorkerQueueSize 设置每个 worker 的队列缓冲长度，队列满时会阻塞后续事件的分发
+func WithWorkerQueueSize(size int) Option {
+	return func(o *options) {
+		o.workerQueueSize = size
+	}
+}
+
+// WithKeyFunc 设置事件分片 key 的计算方式，默认为 DefaultKey
+func WithKeyFunc(f KeyFunc) Option {
+	return func(o *options) {
+		o.keyFunc = f
+	}
+}
+
+// WithHeartbeatMissLimit 连续 n 次心跳没有收到 ack 时认为连接已经断开，默认为 DefaultHeartbeatMissLimit，小于 0 表示不检查
+// 断开时主动关闭连接，Listening 返回 errs.ErrHeartbeatTimeout，由 session manager 进行 resume
+func WithHeartbeatMissLimit(n int) Option {
+	return func(o *options) {
+		o.heartbeatMisses = n
+	}
+}
+
+// WithDialer 设置建立连接使用的 dialer，默认为 websocket.DefaultDialer，其他连接相关的配置会覆盖 dialer 中对应的字段
+func WithDialer(d *wss.Dialer) Option {
+	return func(o *options) {
+		o.dialer = d
+	}
+}
+
+// WithProxy 设置代理，比如 http.ProxyURL(u)，默认使用环境变量 HTTP_PROXY、HTTPS_PROXY 中的代理
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithTLSConfig 设置 tls 配置，比如通过 RootCAs 信任代理使用的自签名 CA
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = c
+	}
+}
+
+// WithHeader 设置握手请求中额外的 http header
+func WithHeader(header http.Header) Option {
+	return func(o *options) {
+		o.header = header
+	}
+}
+
+// WithHandshakeTimeout 设置握手超时时间
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.handshakeTimeout = d
+	}
+}
+
+// WithReadLimit 设置单个消息的最大字节数，超过时连接会被关闭，默认不限制
+func WithReadLimit(n int64) Option {
+	return func(o *options) {
+		o.readLimit = n
+	}
+}
+
+// WithQueueSize 设置接收队列的缓冲长度，默认为 DefaultQueueSize
+func WithQueueSize(size int) Option {
+	return func(o *options) {
+		o.queueSize = size
+	}
+}
+
+// WithBackpressure 设置接收队列已满时的处理策略，默认为 BackpressureBlock
+func WithBackpressure(b Backpressure) Option {
+	return func(o *options) {
+		o.backpressure = b
+	}
+}
+
+// WithSpillDir 设置 BackpressureSpill 策略写入临时文件的目录，默认为 os.TempDir()
+func WithSpillDir(dir string) Option {
+	return func(o *options) {
+		o.spillDir = dir
+	}
+}
+
+// WithRecorder 录制所有连接收到的原始数据，用于通过 record.Replay 在本地复现问题
+func WithRecorder(r *record.Recorder) Option {
+	return func(o *options) {
+		o.recorder = r
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+)
+
+// spillQueue 接收队列已满时，按顺序把事件的原始数据写入磁盘上的临时文件，等待读回
+// 文件在第一次写入时创建，全部读回后清空，连接结束时删除
+type spillQueue struct {
+	dir string
+
+	mu       sync.Mutex
+	file     *os.File
+	writeOff int64
+	readOff  int64
+	n        int           // 等待读回的事件数
+	notify   chan struct{} // 有新的事件写入时通知
+}
+
+func newSpillQueue(dir string) *spillQueue {
+	return &spillQueue{dir: dir, notify: make(chan struct{}, 1)}
+}
+
+// len 等待读回的事件数，q 为空时返回 0
+func (q *spillQueue) len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// push 写入一个事件，每条记录为 4 字节的长度加上原始数据
+func (q *spillQueue) push(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		f, err := os.CreateTemp(q.dir, "botgo-spill-*")
+		if err != nil {
+			return err
+		}
+		q.file = f
+	}
+	record := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[4:], data)
+	if _, err := q.file.WriteAt(record, q.writeOff); err != nil {
+		return err
+	}
+	q.writeOff += int64(len(record))
+	q.n++
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pop 按写入顺序读回一个事件，没有事件时返回 nil；q 为空时返回 nil
+func (q *spillQueue) pop() ([]byte, error) {
+	if q == nil {
+		return nil, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.n == 0 {
+		return nil, nil
+	}
+	var size [4]byte
+	if _, err := q.file.ReadAt(size[:], q.readOff); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := q.file.ReadAt(data, q.readOff+4); err != nil {
+		return nil, err
+	}
+	q.readOff += int64(4 + len(data))
+	q.n--
+	if q.n == 0 {
+		// 全部读回之后清空文件，避免文件一直增长
+		q.readOff, q.writeOff = 0, 0
+		if err := q.file.Truncate(0); err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// wait 返回有新的事件写入时通知的 chan，q 为空时返回 nil，永远不会通知
+func (q *spillQueue) wait() <-chan struct{} {
+	if q == nil {
+		return nil
+	}
+	return q.notify
+}
+
+// close 删除临时文件，返回未读回而丢失的事件数，之后再写入时会重新创建文件
+func (q *spillQueue) close() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return 0
+	}
+	name := q.file.Name()
+	_ = q.file.Close()
+	_ = os.Remove(name)
+	lost := q.n
+	q.file = nil
+	q.n, q.readOff, q.writeOff = 0, 0, 0
+	return lost
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillQueue(t *testing.T) {
+	q := newSpillQueue(t.TempDir())
+	for _, data := range []string{"a", "bb", "ccc"} {
+		require.NoError(t, q.push([]byte(data)))
+	}
+	assert.Equal(t, 3, q.len())
+	for _, want := range []string{"a", "bb"} {
+		data, err := q.pop()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+	// 读回过程中继续写入，保持顺序
+	require.NoError(t, q.push([]byte("dddd")))
+	for _, want := range []string{"ccc", "dddd"} {
+		data, err := q.pop()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+	data, err := q.pop()
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	require.NoError(t, q.push([]byte("e")))
+	assert.Equal(t, 1, q.close())
+	assert.Equal(t, 0, q.len())
+
+	var empty *spillQueue
+	assert.Equal(t, 0, empty.len())
+	assert.Equal(t, 0, empty.close())
+}
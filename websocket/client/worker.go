@@ -0,0 +1,121 @@
+package client
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/websocket"
+)
+
+// DefaultWorkerQueueSize 每个 worker 的队列缓冲长度
+const DefaultWorkerQueueSize = 1000
+
+// KeyFunc 返回事件的分片 key，相同 key 的事件由同一个 worker 按顺序处理
+type KeyFunc func(payload *dto.WSPayload) string
+
+// keyPaths 默认按照 群 -> 子频道 -> 用户 -> 频道 的顺序提取分片 key
+var keyPaths = []string{
+	"d.group_openid",
+	"d.group_id",
+	"d.channel_id",
+	"d.user_openid",
+	"d.openid",
+	"d.author.id",
+	"d.guild_id",
+}
+
+// DefaultKey 默认的分片 key，同一个群、子频道或者用户的事件保持顺序，都不存在时使用事件类型
+func DefaultKey(payload *dto.WSPayload) string {
+	for _, path := range keyPaths {
+		if v := gjson.GetBytes(payload.RawMessage, path); v.String() != "" {
+			return v.String()
+		}
+	}
+	return string(payload.Type)
+}
+
+// workerPool 按 key 分片的 worker 池，每个 worker 串行处理分配给自己的事件
+type workerPool struct {
+	queues  []chan *dto.WSPayload
+	keyFunc KeyFunc
+	handle  func(payload *dto.WSPayload)
+	wg      sync.WaitGroup
+}
+
+func newWorkerPool(workers, queueSize int, keyFunc KeyFunc, handle func(payload *dto.WSPayload)) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultWorkerQueueSize
+	}
+	if keyFunc == nil {
+		keyFunc = DefaultKey
+	}
+	p := &workerPool{
+		queues:  make([]chan *dto.WSPayload, workers),
+		keyFunc: keyFunc,
+		handle:  handle,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan *dto.WSPayload, queueSize)
+	}
+	return p
+}
+
+// start 启动所有 worker
+func (p *workerPool) start() {
+	for _, queue := range p.queues {
+		p.wg.Add(1)
+		go p.work(queue)
+	}
+}
+
+// dispatch 投递事件到 key 对应的 worker，worker 队列已满时阻塞
+func (p *workerPool) dispatch(payload *dto.WSPayload) {
+	i := 0
+	if len(p.queues) > 1 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(p.keyFunc(payload)))
+		i = int(h.Sum32() % uint32(len(p.queues)))
+	}
+	p.queues[i] <- payload
+}
+
+// stop 不再接收新的事件，等待已经投递的事件处理完成
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// depth 所有 worker 队列中等待处理的事件数
+func (p *workerPool) depth() int {
+	n := 0
+	for _, queue := range p.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+func (p *workerPool) work(queue chan *dto.WSPayload) {
+	defer p.wg.Done()
+	for payload := range queue {
+		p.safeHandle(payload)
+	}
+}
+
+// safeHandle 处理单个事件，handler 的 panic 只影响当前事件，不会断开连接
+func (p *workerPool) safeHandle(payload *dto.WSPayload) {
+	defer func() {
+		if err := recover(); err != nil {
+			websocket.PanicHandler(fmt.Sprintf("%v, event %s seq %d", err, payload.Type, payload.Seq), payload.Session)
+		}
+	}()
+	p.handle(payload)
+}
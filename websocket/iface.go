@@ -1,7 +1,10 @@
 package websocket
 
 import (
+	"context"
+
 	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/event"
 )
 
 // WebSocket 需要实现的接口
@@ -23,3 +26,14 @@ type WebSocket interface {
 	// Close 关闭连接
 	Close()
 }
+
+// DispatcherSetter 支持指定事件分发器的 websocket 实现，未实现时使用 event.DefaultDispatcher
+type DispatcherSetter interface {
+	SetDispatcher(d *event.Dispatcher)
+}
+
+// Shutdowner 支持优雅关闭的 websocket 实现，未实现时直接调用 Close 关闭连接
+type Shutdowner interface {
+	// Shutdown 停止接收新的事件，等待已经接收的事件处理完成后关闭连接，ctx 结束时不再等待
+	Shutdown(ctx context.Context) error
+}
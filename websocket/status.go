@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// Status 连接的运行状态，用于健康检查与问题排查
+type Status struct {
+	SessionID        string        `json:"session_id"`
+	ShardID          uint32        `json:"shard_id"`
+	ShardCount       uint32        `json:"shard_count"`
+	LastSeq          uint32        `json:"last_seq"`
+	HandledSeq       uint32        `json:"handled_seq"` // 该 seq 及之前的事件都已经处理完成，重启后从这里 resume 不会丢失事件
+	Ready            bool          `json:"ready"`       // 已经收到 READY 或者 RESUMED 事件
+	ConnectedAt      time.Time     `json:"connected_at"`
+	ReadyAt          time.Time     `json:"ready_at"`
+	LastHeartbeatAck time.Time     `json:"last_heartbeat_ack"`   // 最近一次收到心跳 ack 的时间
+	HeartbeatLatency time.Duration `json:"heartbeat_latency"`    // 最近一次心跳的往返耗时
+	MissedHeartbeats int           `json:"missed_heartbeats"`    // 连续没有收到 ack 的心跳次数
+	QueueDepth       int           `json:"queue_depth"`          // 等待处理的事件数
+	SpilledEvents    int           `json:"spilled_events"`       // 写入磁盘等待处理的事件数，包含在 QueueDepth 中
+	DroppedEvents    int64         `json:"dropped_events"`       // 接收队列已满而丢弃的事件数
+	CloseCode        int           `json:"close_code,omitempty"` // 连接关闭的错误码，websocket close code 或者 sdk 错误码
+	CloseError       string        `json:"close_error,omitempty"`
+}
+
+// StatusReporter 可以查询运行状态的 websocket 实现
+type StatusReporter interface {
+	Status() Status
+}
+
+// StatusOf 返回 ws 的运行状态，ws 未实现 StatusReporter 时只包含 session 中的信息
+func StatusOf(ws WebSocket) Status {
+	if r, ok := ws.(StatusReporter); ok {
+		return r.Status()
+	}
+	return sessionStatus(ws.Session())
+}
+
+func sessionStatus(session *dto.Session) Status {
+	return Status{
+		SessionID:  session.ID,
+		ShardID:    session.Shards.ShardID,
+		ShardCount: session.Shards.ShardCount,
+		LastSeq:    session.LastSeq,
+		HandledSeq: session.LastSeq,
+	}
+}
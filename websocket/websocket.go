@@ -2,6 +2,7 @@
 package websocket
 
 import (
+	"context"
 	"runtime"
 	"syscall"
 
@@ -22,6 +23,29 @@ func Register(ws WebSocket) {
 	ClientImpl = ws
 }
 
+// New 使用 ClientImpl 创建一个新的 ws 实例，d 不为空时使用 d 分发事件
+func New(session dto.Session, d *event.Dispatcher) WebSocket {
+	ws := ClientImpl.New(session)
+	if d == nil {
+		return ws
+	}
+	if setter, ok := ws.(DispatcherSetter); ok {
+		setter.SetDispatcher(d)
+	} else {
+		log.Warnf("%s websocket client %T does not support custom dispatcher", &session, ws)
+	}
+	return ws
+}
+
+// Shutdown 优雅关闭 ws 连接，ws 未实现 Shutdowner 时直接关闭
+func Shutdown(ctx context.Context, ws WebSocket) error {
+	if s, ok := ws.(Shutdowner); ok {
+		return s.Shutdown(ctx)
+	}
+	ws.Close()
+	return nil
+}
+
 // RegisterResumeSignal 注册用于通知 client 将连接进行 resume 的信号
 func RegisterResumeSignal(signal syscall.Signal) {
 	ResumeSignal = signal
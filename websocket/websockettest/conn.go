@@ -0,0 +1,142 @@
+package websockettest
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	wss "github.com/gorilla/websocket"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// Conn 模拟网关上的一个客户端连接
+type Conn struct {
+	gateway *Gateway
+	ws      *wss.Conn
+
+	writeMu sync.Mutex // gorilla websocket 不支持并发写
+
+	mu      sync.Mutex
+	session *session
+	closed  bool
+}
+
+// SessionID 连接绑定的 session id，未完成 Identify 或者 Resume 时为空
+func (c *Conn) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return ""
+	}
+	return c.session.id
+}
+
+// Shard 连接对应的分片信息，格式为 [shard_id, num_shards]
+func (c *Conn) Shard() []uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return nil
+	}
+	return c.session.shard
+}
+
+// Intents 连接 Identify 时声明的 intents
+func (c *Conn) Intents() dto.Intent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return 0
+	}
+	return c.session.intents
+}
+
+// Dispatch 在当前连接上下发一个事件，seq 由网关按 session 自动递增，下发的事件会被记录用于 resume 补发
+func (c *Conn) Dispatch(eventType dto.EventType, data interface{}) error {
+	c.mu.Lock()
+	s := c.session
+	c.mu.Unlock()
+	payload := &dto.WSPayload{
+		WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: eventType},
+		Data:          data,
+	}
+	if s != nil {
+		c.gateway.record(s, payload)
+	}
+	return c.write(payload)
+}
+
+// Reconnect 下发 opcode 7，要求客户端重连
+func (c *Conn) Reconnect() error {
+	return c.write(&dto.WSPayload{WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSReconnect}})
+}
+
+// InvalidSession 下发 opcode 9，通知客户端 session 已失效
+func (c *Conn) InvalidSession() error {
+	return c.write(&dto.WSPayload{
+		WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSInvalidSession},
+		Data:          false,
+	})
+}
+
+// CloseWith 以指定的错误码关闭连接，错误码参考 errs 中的 WSCode 定义
+func (c *Conn) CloseWith(code int, text string) error {
+	c.writeMu.Lock()
+	err := c.ws.WriteControl(wss.CloseMessage, wss.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	c.close()
+	return err
+}
+
+func (c *Conn) bind(s *session) {
+	c.mu.Lock()
+	c.session = s
+	c.mu.Unlock()
+
+	c.gateway.mu.Lock()
+	c.gateway.notifyLocked()
+	c.gateway.mu.Unlock()
+}
+
+func (c *Conn) write(payload *dto.WSPayload) error {
+	m, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(wss.TextMessage, m)
+}
+
+func (c *Conn) readLoop() {
+	defer c.close()
+	for {
+		_, message, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.gateway.receive(c, message)
+	}
+}
+
+func (c *Conn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *Conn) close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+	_ = c.ws.Close()
+
+	c.gateway.mu.Lock()
+	c.gateway.notifyLocked()
+	c.gateway.mu.Unlock()
+}
@@ -0,0 +1,395 @@
+// Package websockettest 提供一个本地的 websocket 事件网关模拟服务，用于在没有真实服务的情况下测试连接、重连与事件分发逻辑。
+//
+// 模拟网关实现了 dto/websocket_opcode.go 中定义的 Hello/Identify/Ready/Heartbeat/HeartbeatAck/Resume/Reconnect/InvalidSession
+// 协议，并允许测试用例主动下发 DISPATCH 事件以及 errs 中定义的关闭错误码。
+//
+//	gw := websockettest.NewGateway()
+//	defer gw.Close()
+//	session := dto.Session{URL: gw.URL, TokenSource: ts, Shards: dto.ShardConfig{ShardCount: 1}}
+package websockettest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	wss "github.com/gorilla/websocket"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// 模拟网关下发的内置事件类型
+const (
+	EventReady   dto.EventType = "READY"
+	EventResumed dto.EventType = "RESUMED"
+)
+
+// DefaultHeartbeatInterval 默认的心跳间隔
+const DefaultHeartbeatInterval = 45 * time.Second
+
+// ErrTimeout 等待超时
+var ErrTimeout = errors.New("websockettest: wait timeout")
+
+// Option 模拟网关的配置项
+type Option func(g *Gateway)
+
+// WithHeartbeatInterval 设置 Hello 中下发的心跳间隔
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(g *Gateway) {
+		g.heartbeatInterval = d
+	}
+}
+
+// WithoutHeartbeatAck 收到心跳后不回复 ack，用于模拟半开连接
+func WithoutHeartbeatAck() Option {
+	return func(g *Gateway) {
+		g.noHeartbeatAck = true
+	}
+}
+
+// WithToken 指定合法的 token，Identify 与 Resume 中携带的 token 不匹配时，会以 4004 关闭连接
+func WithToken(token string) Option {
+	return func(g *Gateway) {
+		g.token = token
+	}
+}
+
+// Gateway 模拟的 websocket 事件网关
+type Gateway struct {
+	// URL 网关地址，形如 ws://127.0.0.1:port
+	URL string
+
+	heartbeatInterval time.Duration
+	noHeartbeatAck    bool
+	token             string
+
+	srv      *httptest.Server
+	upgrader wss.Upgrader
+
+	mu       sync.Mutex
+	changed  chan struct{} // 有新的连接或者收到新的数据时关闭并重建，用于唤醒等待者
+	conns    []*Conn
+	sessions map[string]*session
+	received []*dto.WSPayload
+	nextID   int
+}
+
+// session 网关侧维护的 session 状态，用于 resume 的时候补发事件
+type session struct {
+	id      string
+	seq     uint32
+	shard   []uint32
+	intents dto.Intent
+	history []*dto.WSPayload
+}
+
+// NewGateway 创建并启动一个模拟网关，使用完毕后需要调用 Close
+func NewGateway(opts ...Option) *Gateway {
+	g := &Gateway{
+		heartbeatInterval: DefaultHeartbeatInterval,
+		changed:           make(chan struct{}),
+		sessions:          map[string]*session{},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.srv = httptest.NewServer(http.HandlerFunc(g.serveWS))
+	g.URL = "ws" + strings.TrimPrefix(g.srv.URL, "http")
+	return g
+}
+
+// AP 返回指向模拟网关的接入点信息，可以直接交给 session manager 使用
+func (g *Gateway) AP(shards uint32) *dto.WebsocketAP {
+	return &dto.WebsocketAP{
+		URL:    g.URL,
+		Shards: shards,
+		SessionStartLimit: dto.SessionStartLimit{
+			Total: 1000, Remaining: 1000, MaxConcurrency: shards,
+		},
+	}
+}
+
+// Close 关闭所有连接以及模拟网关
+func (g *Gateway) Close() {
+	for _, c := range g.Conns() {
+		c.close()
+	}
+	g.srv.Close()
+}
+
+// Conns 返回当前所有存活的连接
+func (g *Gateway) Conns() []*Conn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	conns := make([]*Conn, 0, len(g.conns))
+	for _, c := range g.conns {
+		if !c.isClosed() {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// Received 返回网关从客户端收到的所有数据包
+func (g *Gateway) Received() []*dto.WSPayload {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*dto.WSPayload(nil), g.received...)
+}
+
+// WaitFor 等待客户端发送第 skip+1 个指定 opcode 的数据包，计数包含调用之前已经收到的数据包
+// 例如 WaitFor(dto.WSIdentity, 0, time.Second) 表示等待第一个 Identify
+func (g *Gateway) WaitFor(op dto.OPCode, skip int, timeout time.Duration) (*dto.WSPayload, error) {
+	deadline := time.After(timeout)
+	for {
+		g.mu.Lock()
+		n := 0
+		for _, p := range g.received {
+			if p.OPCode != op {
+				continue
+			}
+			if n == skip {
+				g.mu.Unlock()
+				return p, nil
+			}
+			n++
+		}
+		changed := g.changed
+		g.mu.Unlock()
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// WaitReady 等待至少 n 个连接在网关侧完成 Identify 或者 Resume，此时客户端可能尚未处理完 READY 事件
+func (g *Gateway) WaitReady(n int, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		g.mu.Lock()
+		ready := 0
+		for _, c := range g.conns {
+			if !c.isClosed() && c.SessionID() != "" {
+				ready++
+			}
+		}
+		changed := g.changed
+		g.mu.Unlock()
+		if ready >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return ErrTimeout
+		}
+	}
+}
+
+// Dispatch 向所有已经鉴权的连接下发一个事件，返回成功下发的连接数
+func (g *Gateway) Dispatch(eventType dto.EventType, data interface{}) int {
+	n := 0
+	for _, c := range g.Conns() {
+		if c.SessionID() == "" {
+			continue
+		}
+		if err := c.Dispatch(eventType, data); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// DispatchTo 向指定 session 下发一个事件，如果该 session 当前没有存活的连接，事件只会被记录，
+// 在客户端 resume 时补发，用于模拟客户端断线期间错过的事件
+func (g *Gateway) DispatchTo(sessionID string, eventType dto.EventType, data interface{}) error {
+	for _, c := range g.Conns() {
+		if c.SessionID() == sessionID {
+			return c.Dispatch(eventType, data)
+		}
+	}
+	g.mu.Lock()
+	s, ok := g.sessions[sessionID]
+	g.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("websockettest: session %s not found", sessionID)
+	}
+	g.record(s, &dto.WSPayload{
+		WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSDispatchEvent, Type: eventType},
+		Data:          data,
+	})
+	return nil
+}
+
+// Reconnect 通知所有连接需要重连
+func (g *Gateway) Reconnect() {
+	for _, c := range g.Conns() {
+		_ = c.Reconnect()
+	}
+}
+
+// InvalidSession 通知所有连接 session 已失效
+func (g *Gateway) InvalidSession() {
+	for _, c := range g.Conns() {
+		_ = c.InvalidSession()
+	}
+}
+
+// CloseWith 以指定的错误码关闭所有连接，错误码参考 errs 中的 WSCode 定义
+func (g *Gateway) CloseWith(code int, text string) {
+	for _, c := range g.Conns() {
+		_ = c.CloseWith(code, text)
+	}
+}
+
+// DropSession 删除网关侧保存的 session，之后使用该 session 进行 resume 会收到 InvalidSession
+func (g *Gateway) DropSession(sessionID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.sessions, sessionID)
+}
+
+// record 为事件分配 seq 与 event id，并记录到 session 的历史中
+func (g *Gateway) record(s *session, payload *dto.WSPayload) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s.seq++
+	payload.Seq = s.seq
+	payload.EventID = fmt.Sprintf("%s:%d", s.id, s.seq)
+	if payload.Type != EventReady && payload.Type != EventResumed {
+		s.history = append(s.history, payload)
+	}
+}
+
+func (g *Gateway) notifyLocked() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+func (g *Gateway) serveWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &Conn{gateway: g, ws: ws}
+	g.mu.Lock()
+	g.conns = append(g.conns, c)
+	g.notifyLocked()
+	g.mu.Unlock()
+
+	hello := &dto.WSPayload{
+		WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSHello},
+		Data:          &dto.WSHelloData{HeartbeatInterval: int(g.heartbeatInterval / time.Millisecond)},
+	}
+	if err := c.write(hello); err != nil {
+		c.close()
+		return
+	}
+	c.readLoop()
+}
+
+// receive 处理客户端发来的数据包
+func (g *Gateway) receive(c *Conn, message []byte) {
+	payload := &dto.WSPayload{}
+	if err := json.Unmarshal(message, payload); err != nil {
+		_ = c.CloseWith(4002, "decode error")
+		return
+	}
+	payload.RawMessage = message
+	g.mu.Lock()
+	g.received = append(g.received, payload)
+	g.notifyLocked()
+	g.mu.Unlock()
+
+	switch payload.OPCode {
+	case dto.WSHeartbeat:
+		if !g.noHeartbeatAck {
+			_ = c.write(&dto.WSPayload{WSPayloadBase: dto.WSPayloadBase{OPCode: dto.WSHeartbeatAck}})
+		}
+	case dto.WSIdentity:
+		g.identify(c, message)
+	case dto.WSResume:
+		g.resume(c, message)
+	default:
+		_ = c.CloseWith(4001, "unknown opcode")
+	}
+}
+
+func (g *Gateway) identify(c *Conn, message []byte) {
+	data := &dto.WSIdentityData{}
+	if err := parseData(message, data); err != nil {
+		_ = c.CloseWith(4002, "decode error")
+		return
+	}
+	if g.token != "" && !strings.HasSuffix(data.Token, g.token) {
+		_ = c.CloseWith(4004, "authentication fail")
+		return
+	}
+	shard := data.Shard
+	if len(shard) != 2 {
+		shard = []uint32{0, 1}
+	}
+	g.mu.Lock()
+	g.nextID++
+	s := &session{id: fmt.Sprintf("session-%d", g.nextID), shard: shard, intents: data.Intents}
+	g.sessions[s.id] = s
+	g.mu.Unlock()
+
+	ready := &dto.WSReadyData{Version: 1, SessionID: s.id, Shard: shard}
+	ready.User.ID = "10000"
+	ready.User.Username = "fake-bot"
+	ready.User.Bot = true
+	c.bind(s)
+	_ = c.Dispatch(EventReady, ready)
+}
+
+func (g *Gateway) resume(c *Conn, message []byte) {
+	data := &dto.WSResumeData{}
+	if err := parseData(message, data); err != nil {
+		_ = c.CloseWith(4002, "decode error")
+		return
+	}
+	if g.token != "" && !strings.HasSuffix(data.Token, g.token) {
+		_ = c.CloseWith(4004, "authentication fail")
+		return
+	}
+	g.mu.Lock()
+	s, ok := g.sessions[data.SessionID]
+	var missed []*dto.WSPayload
+	if ok {
+		for _, p := range s.history {
+			if p.Seq > data.Seq {
+				missed = append(missed, p)
+			}
+		}
+	}
+	g.mu.Unlock()
+	if !ok {
+		_ = c.InvalidSession()
+		return
+	}
+	c.bind(s)
+	for _, p := range missed {
+		if err := c.write(p); err != nil {
+			return
+		}
+	}
+	_ = c.Dispatch(EventResumed, "")
+}
+
+func parseData(message []byte, target interface{}) error {
+	raw := struct {
+		Data json.RawMessage `json:"d"`
+	}{}
+	if err := json.Unmarshal(message, &raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw.Data, target)
+}